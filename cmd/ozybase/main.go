@@ -114,10 +114,20 @@ func run() error {
 
 	// Initialize Server Components
 	h := api.NewHandler(db, broker, dispatcher, mailSvc, storageSvc, ps, migrator, applier)
+	if cfg.MaxPageSize > 0 {
+		h.MaxPageSize = cfg.MaxPageSize
+	}
+	h.Cron = cronMgr
 
 	// Start Log Export Worker
 	go h.StartLogExporter(context.Background())
 
+	// Start Trash Retention Worker
+	go h.StartRetentionWorker(context.Background())
+
+	// Start Materialized View Refresher (on_change policy)
+	go h.StartViewRefresher(context.Background())
+
 	e := setupEcho(h, cfg, cronMgr)
 
 	// 📊 Register Prometheus
@@ -265,8 +275,9 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 	e.Use(h.FirewallMiddleware()) // 🛡️ IP Firewall (Whitelist/Blacklist) - Very First Defense
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: cfg.AllowedOrigins,
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowOrigins:  cfg.AllowedOrigins,
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "Prefer", "If-Match", "If-None-Match"},
+		ExposeHeaders: []string{"Content-Range", "X-Next-Cursor", "ETag", echo.HeaderContentDisposition},
 	}))
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStoreWithConfig(
 		middleware.RateLimiterMemoryStoreConfig{
@@ -276,7 +287,13 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 		},
 	)))
 	e.Use(api.SecurityHeadersDefault())
-	e.Use(middleware.BodyLimit(cfg.BodyLimit))
+	e.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Limit: cfg.BodyLimit,
+		Skipper: func(c echo.Context) bool {
+			// Imports stream large files and carry their own limit
+			return strings.HasSuffix(c.Path(), "/import")
+		},
+	}))
 	e.Use(api.PrometheusMiddleware()) // 📊 Stats
 	e.Use(api.RLSMiddleware(h.DB))    // 🛡️ RLS Context Injection
 	e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
@@ -301,7 +318,7 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 	authHandler := api.NewAuthHandler(authService)
 	twoFactorService := core.NewTwoFactorService(h.DB)
 	twoFactorHandler := api.NewTwoFactorHandler(twoFactorService)
-	realtimeHandler := api.NewRealtimeHandler(h.Broker)
+	realtimeHandler := api.NewRealtimeHandler(h.Broker, h.DB)
 	fileHandler := api.NewFileHandler(h.DB, "./data/storage")
 	functionsHandler := api.NewFunctionsHandler(h.DB, "./functions")
 	webhookHandler := api.NewWebhookHandler(h.DB)
@@ -314,13 +331,14 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 	accessCreate := api.AccessMiddleware(h.DB, "create")
 	accessUpdate := api.AccessMiddleware(h.DB, "update")
 	accessDelete := api.AccessMiddleware(h.DB, "delete")
+	writable := api.WritableMiddleware(h.DB)
 
 	apiGroup := e.Group("/api")
 	apiGroup.Use(api.MetricsMiddleware(h))
 	{
 		apiGroup.GET("/health", h.Health)
 		apiGroup.GET("/project/stats", h.GetStats, authRequired)
-		apiGroup.GET("/realtime", realtimeHandler.Stream)
+		apiGroup.GET("/realtime", realtimeHandler.Stream, authOptional)
 		apiGroup.GET("/webhooks", webhookHandler.List, authRequired)
 		apiGroup.POST("/webhooks", webhookHandler.Create, authRequired)
 		apiGroup.DELETE("/webhooks/:id", webhookHandler.Delete, authRequired)
@@ -371,15 +389,24 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 		collectionsGroup.POST("", h.CreateCollection)
 		collectionsGroup.GET("", h.ListCollections)
 		collectionsGroup.DELETE("/:name", h.DeleteCollection) // New
+		collectionsGroup.POST("/:name/rename", h.RenameCollection)
+		collectionsGroup.POST("/:name/clone", h.CloneCollection)
 		collectionsGroup.GET("/schemas", h.ListSchemas)
+		collectionsGroup.PUT("/schemas/:schema", h.UpdateSchemaRules)
 		collectionsGroup.GET("/visualize", h.GetVisualizeSchema)
 		collectionsGroup.PATCH("/rules", h.UpdateCollectionRules)
+		collectionsGroup.GET("/:name/refresh", h.GetRefreshStatus)
+		collectionsGroup.POST("/:name/refresh", h.RefreshView)
+		collectionsGroup.PUT("/:name/search", h.UpdateSearchConfig)
+		collectionsGroup.GET("/:name/vector-indexes", h.ListVectorIndexes)
+		collectionsGroup.PUT("/:name/vector-indexes", h.CreateVectorIndex)
+		collectionsGroup.DELETE("/:name/vector-indexes/:field", h.DropVectorIndex)
 
 		// Tables (Alias for Frontend compatibility)
 		tablesGroup := apiGroup.Group("/tables", authRequired)
 		tablesGroup.GET("/:name", h.ListRecords)
-		tablesGroup.POST("/:name", h.CreateRecord)
-		tablesGroup.DELETE("/:name/:id", h.DeleteRecord)
+		tablesGroup.POST("/:name", h.CreateRecord, writable)
+		tablesGroup.DELETE("/:name/:id", h.DeleteRecord, writable)
 		tablesGroup.GET("/:name/:id", h.GetRecord)
 
 		// Project Info
@@ -434,18 +461,42 @@ func setupEcho(h *api.Handler, cfg *config.Config, cronMgr *realtime.CronManager
 		// Records
 		apiGroup.POST("/collections/:name/records", h.CreateRecord, authOptional, accessCreate)
 		apiGroup.GET("/collections/:name/records", h.ListRecords, authOptional, accessList)
+		apiGroup.PATCH("/collections/:name/records", h.UpdateRecords, authOptional, accessUpdate)
+		apiGroup.DELETE("/collections/:name/records", h.DeleteRecords, authOptional, accessDelete)
+		apiGroup.GET("/collections/:name/aggregate", h.AggregateRecords, authOptional, accessList)
+		apiGroup.POST("/collections/:name/aggregate", h.AggregateRecords, authOptional, accessList)
+		apiGroup.GET("/collections/:name/search", h.SearchRecords, authOptional, accessList)
+		apiGroup.POST("/collections/:name/similar", h.SimilarRecords, authOptional, accessList)
 		apiGroup.GET("/collections/:name/records/:id", h.GetRecord, authOptional, accessList)
 		apiGroup.PATCH("/collections/:name/records/:id", h.UpdateRecord, authOptional, accessUpdate)
 		apiGroup.DELETE("/collections/:name/records/:id", h.DeleteRecord, authOptional, accessDelete)
+		apiGroup.POST("/collections/:name/records/:id/restore", h.RestoreRecord, authOptional, accessUpdate)
+		apiGroup.GET("/collections/:name/records/:id/history", h.RecordHistory, authOptional, accessList)
+		apiGroup.POST("/collections/:name/records/:id/revert", h.RevertRecord, authOptional, accessUpdate)
+		apiGroup.POST("/batch", h.Batch, authOptional)
+
+		// RPC. Route middleware runs after the global RLS injection, so the
+		// caller's claims are attached again once authOptional has set them.
+		apiGroup.POST("/rpc/:function", h.CallRPC, authOptional, api.RLSMiddleware(h.DB))
 
 		// Tables (Generic/Dashboard endpoints) - Now PROTECTED
 		apiGroup.GET("/tables/:name", h.ListRecords, authRequired)
-		apiGroup.POST("/tables/:name/rows", h.CreateRecord, authRequired)
-		apiGroup.PATCH("/tables/:name/rows/:id", h.UpdateRecord, authRequired)
-		apiGroup.DELETE("/tables/:name/rows/:id", h.DeleteRecord, authRequired)
-		apiGroup.POST("/tables/:name/import", h.ImportRecords, authRequired)
-		apiGroup.POST("/tables/:name/columns", h.AddColumn, authRequired)           // New
+		apiGroup.POST("/tables/:name/rows", h.CreateRecord, authRequired, writable)
+		apiGroup.GET("/tables/:name/rows/:id", h.GetRecord, authRequired)
+		apiGroup.PATCH("/tables/:name/rows/:id", h.UpdateRecord, authRequired, writable)
+		apiGroup.DELETE("/tables/:name/rows/:id", h.DeleteRecord, authRequired, writable)
+		apiGroup.POST("/tables/:name/import", h.ImportRecords, authRequired, writable, middleware.BodyLimit(cfg.ImportBodyLimit))
+		apiGroup.GET("/tables/:name/export", h.ExportRecords, authRequired)
+		apiGroup.POST("/tables/:name/columns", h.AddColumn, authRequired) // New
+		apiGroup.PATCH("/tables/:name/columns/:col", h.AlterColumn, authRequired)
 		apiGroup.DELETE("/tables/:name/columns/:col", h.DeleteColumn, authRequired) // New
+		apiGroup.GET("/tables/:name/indexes", h.ListIndexes, authRequired)
+		apiGroup.POST("/tables/:name/indexes", h.CreateIndex, authRequired)
+		apiGroup.DELETE("/tables/:name/indexes/:index", h.DropIndex, authRequired)
+		apiGroup.GET("/tables/:name/foreign-keys", h.ListForeignKeys, authRequired)
+		apiGroup.POST("/tables/:name/foreign-keys", h.CreateForeignKey, authRequired)
+		apiGroup.POST("/tables/:name/foreign-keys/:constraint/validate", h.ValidateForeignKey, authRequired)
+		apiGroup.DELETE("/tables/:name/foreign-keys/:constraint", h.DropForeignKey, authRequired)
 	}
 
 	// Create users table for demo if missing
@@ -500,7 +551,7 @@ func ensureUsersTable(db *data.DB) {
 	_, _ = db.Pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS posts (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			user_id UUID,
 			title TEXT NOT NULL,
 			content TEXT,
 			published BOOLEAN DEFAULT FALSE,
@@ -508,6 +559,12 @@ func ensureUsersTable(db *data.DB) {
 		)
 	`)
 
+	_, _, _, _ = db.CreateForeignKey(ctx, "posts", data.ForeignKeySpec{
+		Column:   "user_id",
+		Target:   "users",
+		OnDelete: data.OnDeleteCascade,
+	})
+
 	// Register 'posts' in metadata
 	_, _ = db.Pool.Exec(ctx, `
 		INSERT INTO _v_collections (name, schema_def, list_rule, create_rule)
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// aggregateMetrics are the aggregate functions, each read from its own query param
+var aggregateMetrics = []string{"count", "sum", "avg", "min", "max"}
+
+// AggregateRequest is the JSON body form of an aggregate query
+type AggregateRequest struct {
+	Group   []string          `json:"group"`
+	Count   []string          `json:"count"`
+	Sum     []string          `json:"sum"`
+	Avg     []string          `json:"avg"`
+	Min     []string          `json:"min"`
+	Max     []string          `json:"max"`
+	Filters map[string]string `json:"filters"` // same grammar as the list query params
+	Order   string            `json:"order"`
+	Limit   int               `json:"limit"`
+	Trashed string            `json:"trashed"`
+}
+
+// AggregateRecords handles GET and POST /api/collections/:name/aggregate
+//
+// GET takes the query as params, e.g. ?group=status,date_trunc(day,created_at)&sum=total&count=*
+// plus the usual list filters; POST takes the same query as an AggregateRequest.
+// Rows are grouped and aggregated in Postgres under the caller's RLS context.
+func (h *Handler) AggregateRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	if collectionName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name is required",
+		})
+	}
+
+	var opts data.AggregateOptions
+	if c.Request().Method == http.MethodPost {
+		var req AggregateRequest
+		if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid JSON body: " + err.Error(),
+			})
+		}
+		opts = req.options()
+	} else {
+		opts = aggregateQueryOptions(c.QueryParams())
+	}
+
+	opts.Role = callerRole(c)
+
+	if opts.Limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+	}
+	if opts.Limit == 0 || (h.MaxPageSize > 0 && opts.Limit > h.MaxPageSize) {
+		opts.Limit = h.MaxPageSize
+	}
+
+	// Restrict rows to the caller's own when RLS is enabled
+	opts.OwnerField, opts.OwnerID = h.extractRlsOwnerInfo(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	results, err := h.DB.AggregateRecords(ctx, collectionName, opts)
+	if err != nil {
+		return writeError(c, err, "Failed to aggregate records")
+	}
+	if results == nil {
+		results = []map[string]any{}
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+// aggregateQueryOptions reads an aggregate query from URL params. Everything
+// that is not part of the aggregation is passed on as a filter.
+func aggregateQueryOptions(params url.Values) data.AggregateOptions {
+	opts := data.AggregateOptions{
+		Group:   params.Get("group"),
+		Metrics: make(map[string]string),
+		Filters: make(map[string][]string, len(params)),
+		Order:   params.Get("order"),
+		Trashed: params.Get("trashed"),
+	}
+	for _, fn := range aggregateMetrics {
+		if v := params.Get(fn); v != "" {
+			opts.Metrics[fn] = v
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			limit = -1
+		}
+		opts.Limit = limit
+	}
+
+	for key, values := range params {
+		if key != "group" && !slices.Contains(aggregateMetrics, key) {
+			opts.Filters[key] = values
+		}
+	}
+	return opts
+}
+
+func (r AggregateRequest) options() data.AggregateOptions {
+	opts := data.AggregateOptions{
+		Group:   strings.Join(r.Group, ","),
+		Metrics: make(map[string]string),
+		Filters: make(map[string][]string, len(r.Filters)),
+		Order:   r.Order,
+		Limit:   r.Limit,
+		Trashed: r.Trashed,
+	}
+	for fn, list := range map[string][]string{"count": r.Count, "sum": r.Sum, "avg": r.Avg, "min": r.Min, "max": r.Max} {
+		if len(list) > 0 {
+			opts.Metrics[fn] = strings.Join(list, ",")
+		}
+	}
+	for key, value := range r.Filters {
+		opts.Filters[key] = []string{value}
+	}
+	return opts
+}
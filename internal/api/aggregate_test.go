@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateQueryOptions(t *testing.T) {
+	params, _ := url.ParseQuery("group=status,date_trunc(day,created_at)&sum=total&count=*&status=neq.void&order=sum_total.desc&limit=5")
+
+	opts := aggregateQueryOptions(params)
+	assert.Equal(t, "status,date_trunc(day,created_at)", opts.Group)
+	assert.Equal(t, map[string]string{"sum": "total", "count": "*"}, opts.Metrics)
+	assert.Equal(t, "sum_total.desc", opts.Order)
+	assert.Equal(t, 5, opts.Limit)
+
+	// Aggregation params are not filters; order and limit are skipped as reserved later
+	assert.Contains(t, opts.Filters, "status")
+	assert.NotContains(t, opts.Filters, "group")
+	assert.NotContains(t, opts.Filters, "sum")
+
+	params, _ = url.ParseQuery("count=*&limit=0")
+	assert.Equal(t, -1, aggregateQueryOptions(params).Limit)
+}
+
+func TestAggregateRequestOptions(t *testing.T) {
+	req := AggregateRequest{
+		Group:   []string{"country"},
+		Sum:     []string{"revenue:total"},
+		Count:   []string{"*"},
+		Filters: map[string]string{"status": "eq.paid"},
+		Limit:   20,
+	}
+
+	opts := req.options()
+	assert.Equal(t, "country", opts.Group)
+	assert.Equal(t, map[string]string{"sum": "revenue:total", "count": "*"}, opts.Metrics)
+	assert.Equal(t, map[string][]string{"status": {"eq.paid"}}, opts.Filters)
+	assert.Equal(t, 20, opts.Limit)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// maxBatchOperations caps how many operations a single batch may carry
+const maxBatchOperations = 100
+
+// BatchOperation is one write inside POST /api/batch
+type BatchOperation struct {
+	Ref              string         `json:"ref,omitempty"` // name later operations use in ${ref.id}
+	Method           string         `json:"method"`        // create, update, delete or upsert
+	Collection       string         `json:"collection"`
+	ID               string         `json:"id,omitempty"`
+	Data             map[string]any `json:"data,omitempty"`
+	OnConflict       []string       `json:"on_conflict,omitempty"`
+	IgnoreDuplicates bool           `json:"ignore_duplicates,omitempty"`
+}
+
+// BatchResult reports the outcome of one batch operation
+type BatchResult struct {
+	Ref        string `json:"ref,omitempty"`
+	Method     string `json:"method"`
+	Collection string `json:"collection"`
+	ID         string `json:"id,omitempty"`
+	Status     int    `json:"status"`
+}
+
+// batchError pins a failure to the operation that caused it
+type batchError struct {
+	index  int
+	status int
+	body   map[string]any
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("operation %d failed with status %d", e.index, e.status)
+}
+
+// refPattern matches a reference to an earlier operation's ID, by ref or index
+var refPattern = regexp.MustCompile(`^\$\{([A-Za-z0-9_]+)\.id\}$`)
+
+// Batch handles POST /api/batch
+//
+// Operations run in order inside one RLS-scoped transaction. String values of
+// the form ${ref.id} or ${0.id} are replaced with the ID produced by an earlier
+// operation. Any failure rolls the whole batch back.
+func (h *Handler) Batch(c echo.Context) error {
+	var req struct {
+		Operations []BatchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid JSON body: " + err.Error(),
+		})
+	}
+
+	if len(req.Operations) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "operations cannot be empty"})
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("a batch may contain at most %d operations", maxBatchOperations),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	// Check every ACL before touching any data
+	owners := make([]batchOwner, len(req.Operations))
+	for i, op := range req.Operations {
+		rules, status, msg := h.authorizeBatchOperation(ctx, c, op)
+		if status != 0 {
+			return c.JSON(status, map[string]any{"error": msg, "operation": i})
+		}
+		owners[i].field, owners[i].id = rlsOwner(c, rules.RlsEnabled, rules.RlsRule)
+	}
+
+	results := make([]BatchResult, 0, len(req.Operations))
+	err := h.DB.RunInTransaction(ctx, func(rt *data.RecordTx) error {
+		refs := make(map[string]string)
+		for i, op := range req.Operations {
+			res, err := h.runBatchOperation(c, rt, op, owners[i], refs)
+			if err != nil {
+				var be *batchError
+				if errors.As(err, &be) {
+					be.index = i
+					return be
+				}
+				status, body := recordErrorResponse(err, "Batch operation failed")
+				return &batchError{index: i, status: status, body: body}
+			}
+
+			refs[strconv.Itoa(i)] = res.ID
+			if op.Ref != "" {
+				refs[op.Ref] = res.ID
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+
+	if err != nil {
+		var be *batchError
+		if errors.As(err, &be) {
+			be.body["operation"] = be.index
+			return c.JSON(be.status, be.body)
+		}
+		return writeError(c, err, "Batch failed")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"results": results})
+}
+
+// batchOwner is the RLS owner the writes of an operation are restricted to
+type batchOwner struct {
+	field, id string
+}
+
+// authorizeBatchOperation applies the same collection rules as AccessMiddleware.
+// It returns a zero status along with the rules when the operation is allowed.
+func (h *Handler) authorizeBatchOperation(ctx context.Context, c echo.Context, op BatchOperation) (collectionRules, int, string) {
+	var requirements []string
+	switch op.Method {
+	case "create":
+		requirements = []string{"create"}
+	case "update":
+		requirements = []string{"update"}
+	case "delete":
+		requirements = []string{"delete"}
+	case "upsert":
+		// A merge may overwrite an existing row
+		requirements = []string{"create"}
+		if !op.IgnoreDuplicates {
+			requirements = append(requirements, "update")
+		}
+	default:
+		return collectionRules{}, http.StatusBadRequest, fmt.Sprintf("unknown method %q", op.Method)
+	}
+
+	if op.Collection == "" {
+		return collectionRules{}, http.StatusBadRequest, "collection is required"
+	}
+	if (op.Method == "update" || op.Method == "delete") && op.ID == "" {
+		return collectionRules{}, http.StatusBadRequest, "id is required for " + op.Method
+	}
+
+	rules, err := loadCollectionRules(ctx, h.DB.Pool, op.Collection)
+	if err != nil {
+		return rules, http.StatusNotFound, "collection not found"
+	}
+	for _, requirement := range requirements {
+		if denied := rules.check(c, requirement); denied != "" {
+			return rules, http.StatusForbidden, denied
+		}
+	}
+	if rules.ReadOnly {
+		return rules, http.StatusMethodNotAllowed, errReadOnlyCollection
+	}
+	return rules, 0, ""
+}
+
+func (h *Handler) runBatchOperation(c echo.Context, rt *data.RecordTx, op BatchOperation, owner batchOwner, refs map[string]string) (BatchResult, error) {
+	res := BatchResult{Ref: op.Ref, Method: op.Method, Collection: op.Collection}
+
+	id, err := resolveRef(op.ID, refs)
+	if err != nil {
+		return res, err
+	}
+	body := make(map[string]any, len(op.Data))
+	for key, val := range op.Data {
+		if s, ok := val.(string); ok {
+			if val, err = resolveRef(s, refs); err != nil {
+				return res, err
+			}
+		}
+		body[key] = val
+	}
+
+	if op.Method != "delete" {
+		var fieldErrs []ValidationError
+		body, fieldErrs, err = h.validatePayload(c, op.Collection, body, op.Method)
+		if err != nil {
+			return res, err
+		}
+		if len(fieldErrs) > 0 {
+			return res, &batchError{status: http.StatusBadRequest, body: validationBody(fieldErrs)}
+		}
+	}
+
+	switch op.Method {
+	case "create":
+		res.ID, err = rt.Insert(op.Collection, body)
+		res.Status = http.StatusCreated
+	case "update":
+		err = rt.Update(op.Collection, id, body, owner.field, owner.id, nil)
+		res.ID, res.Status = id, http.StatusOK
+	case "delete":
+		err = rt.Delete(op.Collection, id, owner.field, owner.id, nil)
+		res.ID, res.Status = id, http.StatusNoContent
+	case "upsert":
+		var up data.UpsertResult
+		up, err = rt.Upsert(op.Collection, body, op.OnConflict, op.IgnoreDuplicates, owner.field, owner.id, callerRole(c))
+		res.ID, res.Status = up.ID, http.StatusOK
+		switch {
+		case up.Ignored:
+			res.Status = http.StatusNoContent
+		case up.Inserted:
+			res.Status = http.StatusCreated
+		}
+	}
+	return res, err
+}
+
+// resolveRef substitutes a ${ref.id} placeholder with the ID it points to.
+// Other strings are returned unchanged.
+func resolveRef(s string, refs map[string]string) (string, error) {
+	m := refPattern.FindStringSubmatch(s)
+	if m == nil {
+		return s, nil
+	}
+	id, ok := refs[m[1]]
+	if !ok {
+		return "", &batchError{
+			status: http.StatusBadRequest,
+			body:   map[string]any{"error": fmt.Sprintf("unknown reference %q", m[1])},
+		}
+	}
+	return id, nil
+}
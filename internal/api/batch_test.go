@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveRef(t *testing.T) {
+	refs := map[string]string{"0": "id-0", "order": "id-0"}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"${order.id}", "id-0"},
+		{"${0.id}", "id-0"},
+		{"plain text", "plain text"},
+		{"prefix ${order.id}", "prefix ${order.id}"},
+	}
+	for _, tt := range tests {
+		got, err := resolveRef(tt.in, refs)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	_, err := resolveRef("${missing.id}", refs)
+	var be *batchError
+	assert.True(t, errors.As(err, &be))
+	assert.Equal(t, http.StatusBadRequest, be.status)
+}
+
+func TestBatchRejectsBadRequests(t *testing.T) {
+	e := echo.New()
+	h := &Handler{}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Invalid JSON", `{"operations":`},
+		{"Empty", `{"operations":[]}`},
+		{"Too many", `{"operations":[` + strings.TrimSuffix(strings.Repeat(`{"method":"create","collection":"a"},`, maxBatchOperations+1), ",") + `]}`},
+		{"Unknown method", `{"operations":[{"method":"merge","collection":"a"}]}`},
+		{"Missing id", `{"operations":[{"method":"update","collection":"a","data":{}}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			assert.NoError(t, h.Batch(e.NewContext(req, rec)))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
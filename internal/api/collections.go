@@ -1,8 +1,10 @@
 package api
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,30 +12,54 @@ import (
 	"time"
 
 	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
 // Collection represents a collection in the system
 type Collection struct {
-	ID         string             `json:"id"`
-	Name       string             `json:"name"`
-	Schema     []data.FieldSchema `json:"schema"`
-	ListRule   string             `json:"list_rule"`
-	CreateRule string             `json:"create_rule"`
-	RlsEnabled bool               `json:"rls_enabled"`
-	RlsRule    string             `json:"rls_rule"`
-	CreatedAt  time.Time          `json:"created_at"`
-	UpdatedAt  time.Time          `json:"updated_at"`
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	Schema          []data.FieldSchema `json:"schema"`
+	ListRule        string             `json:"list_rule"`
+	CreateRule      string             `json:"create_rule"`
+	RlsEnabled      bool               `json:"rls_enabled"`
+	RlsRule         string             `json:"rls_rule"`
+	UnknownFields   string             `json:"unknown_fields"`        // "strip" or "reject" keys missing from the schema
+	RetentionDays   *int               `json:"retention_days"`        // purge trashed rows after N days, nil keeps them
+	HistoryEnabled  bool               `json:"history_enabled"`       // record every change in _v_record_history
+	Type            string             `json:"type"`                  // "table", "view" or "materialized_view"
+	IDStrategy      string             `json:"id_strategy,omitempty"` // primary key strategy of a table, e.g. "uuidv7"
+	Query           string             `json:"query,omitempty"`       // SELECT backing a view collection
+	RefreshPolicy   string             `json:"refresh_policy,omitempty"`
+	RefreshSchedule string             `json:"refresh_schedule,omitempty"`
+	SearchFields    []string           `json:"search_fields,omitempty"`   // fields behind the full-text search column
+	SearchLanguage  string             `json:"search_language,omitempty"` // text search configuration, e.g. "english"
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
 }
 
 // CreateCollectionRequest represents the request to create a new collection
 type CreateCollectionRequest struct {
-	Name       string             `json:"name"`
-	Schema     []data.FieldSchema `json:"schema"`
-	ListRule   string             `json:"list_rule"`   // "public", "auth", "admin"
-	CreateRule string             `json:"create_rule"` // "auth", "admin"
-	RlsEnabled bool               `json:"rls_enabled"`
-	RlsRule    string             `json:"rls_rule"`
+	Name           string             `json:"name"`
+	Schema         []data.FieldSchema `json:"schema"`
+	ListRule       string             `json:"list_rule"`   // "public", "auth", "admin"
+	CreateRule     string             `json:"create_rule"` // "auth", "admin"
+	RlsEnabled     bool               `json:"rls_enabled"`
+	RlsRule        string             `json:"rls_rule"`
+	UnknownFields  string             `json:"unknown_fields"` // "strip" (default), "reject"
+	RetentionDays  *int               `json:"retention_days"`
+	HistoryEnabled bool               `json:"history_enabled"`
+	Type           string             `json:"type"` // "table" (default), "view" or "materialized_view"
+	// Tables only: "uuidv4" (default), "uuidv7", "ulid", "bigserial" or "custom"
+	IDStrategy string `json:"id_strategy"`
+	Query      string `json:"query"` // SELECT defining a view collection
+	// Materialized views only: "manual" (default), "cron" or "on_change"
+	RefreshPolicy   string `json:"refresh_policy"`
+	RefreshSchedule string `json:"refresh_schedule"` // cron expression for the cron policy
+	// Tables only: text fields searched by /search, most important first
+	SearchFields   []string `json:"search_fields"`
+	SearchLanguage string   `json:"search_language"` // text search configuration, "simple" by default
 }
 
 // CreateCollection handles POST /api/collections
@@ -52,17 +78,66 @@ func (h *Handler) CreateCollection(c echo.Context) error {
 		})
 	}
 
+	switch req.Type {
+	case "", data.CollectionTable:
+		req.Type = data.CollectionTable
+	case data.CollectionView, data.CollectionMaterializedView:
+		return h.createViewCollection(c, req)
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "type must be 'table', 'view' or 'materialized_view'",
+		})
+	}
+
 	if len(req.Schema) == 0 {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "Schema is required and must have at least one field",
 		})
 	}
 
+	if req.UnknownFields == "" {
+		req.UnknownFields = data.UnknownFieldsStrip
+	}
+	if !validUnknownFieldsPolicy(req.UnknownFields) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "unknown_fields must be 'strip' or 'reject'",
+		})
+	}
+
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "retention_days cannot be negative",
+		})
+	}
+
+	if req.IDStrategy == "" {
+		req.IDStrategy = data.IDUUIDv4
+	}
+	if !data.ValidIDStrategy(req.IDStrategy) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "id_strategy must be 'uuidv4', 'uuidv7', 'ulid', 'bigserial' or 'custom'",
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
+	// Vector fields are stored with pgvector when it is installed
+	if err := h.DB.ResolveVectorFields(ctx, req.Schema); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// Relations are typed after the ids of their target
+	if err := h.DB.ResolveRelationFields(ctx, req.Name, req.IDStrategy, req.Schema); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to resolve relation targets: " + err.Error(),
+		})
+	}
+
 	// Build the CREATE TABLE SQL
-	createSQL, err := data.BuildCreateTableSQL(req.Name, req.Schema)
+	createSQL, err := data.BuildCreateTableSQL(req.Name, req.IDStrategy, req.Schema)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
@@ -86,18 +161,24 @@ func (h *Handler) CreateCollection(c echo.Context) error {
 	}
 
 	// Attach Realtime Trigger
-	triggerSQL := fmt.Sprintf(`
-		CREATE TRIGGER tr_notify_%s
-		AFTER INSERT OR UPDATE OR DELETE ON %s
-		FOR EACH ROW EXECUTE FUNCTION notify_event();
-	`, req.Name, req.Name)
-
+	triggerSQL := data.NotifyTriggerSQL(req.Name)
 	if _, err := tx.Exec(ctx, triggerSQL); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to attach realtime trigger: " + err.Error(),
 		})
 	}
 
+	// Attach History Trigger
+	if req.HistoryEnabled {
+		historySQL := data.HistoryTriggerSQL(req.Name)
+		if _, err := tx.Exec(ctx, historySQL); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to attach history trigger: " + err.Error(),
+			})
+		}
+		triggerSQL += "\n" + historySQL
+	}
+
 	// Set defaults if empty
 	if req.ListRule == "" {
 		req.ListRule = "auth"
@@ -110,12 +191,12 @@ func (h *Handler) CreateCollection(c echo.Context) error {
 	schemaJSON, _ := json.Marshal(req.Schema)
 	var collection Collection
 	err = tx.QueryRow(ctx, `
-		INSERT INTO _v_collections (name, schema_def, list_rule, create_rule, rls_enabled, rls_rule)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, name, list_rule, create_rule, rls_enabled, rls_rule, created_at, updated_at
-	`, req.Name, schemaJSON, req.ListRule, req.CreateRule, req.RlsEnabled, req.RlsRule).Scan(
-		&collection.ID, &collection.Name, &collection.ListRule, &collection.CreateRule,
-		&collection.RlsEnabled, &collection.RlsRule, &collection.CreatedAt, &collection.UpdatedAt,
+		INSERT INTO _v_collections (name, schema_def, list_rule, create_rule, rls_enabled, rls_rule, unknown_fields, retention_days, history_enabled, id_strategy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10)
+		RETURNING id, name, list_rule, create_rule, rls_enabled, rls_rule, unknown_fields, retention_days, history_enabled, id_strategy, created_at, updated_at
+	`, req.Name, schemaJSON, req.ListRule, req.CreateRule, req.RlsEnabled, req.RlsRule, req.UnknownFields, req.RetentionDays, req.HistoryEnabled, req.IDStrategy).Scan(
+		&collection.ID, &collection.Name, &collection.ListRule, &collection.CreateRule, &collection.RlsEnabled,
+		&collection.RlsRule, &collection.UnknownFields, &collection.RetentionDays, &collection.HistoryEnabled, &collection.IDStrategy, &collection.CreatedAt, &collection.UpdatedAt,
 	)
 
 	if err != nil {
@@ -124,6 +205,17 @@ func (h *Handler) CreateCollection(c echo.Context) error {
 		})
 	}
 
+	// Add the full-text search column once the metadata it is stored in exists
+	var searchSQL string
+	if len(req.SearchFields) > 0 {
+		cfg := data.SearchConfig{Fields: req.SearchFields, Language: req.SearchLanguage}
+		if searchSQL, err = data.SetSearchConfig(ctx, tx, req.Name, cfg); err != nil {
+			return writeError(c, err, "Failed to enable search")
+		}
+		collection.SearchFields = req.SearchFields
+		collection.SearchLanguage = cmp.Or(req.SearchLanguage, data.DefaultSearchLanguage)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -133,12 +225,161 @@ func (h *Handler) CreateCollection(c echo.Context) error {
 
 	// 📜 Record Migration
 	fullMigrationSQL := fmt.Sprintf("%s\n\n%s", createSQL, triggerSQL)
+	if searchSQL != "" {
+		fullMigrationSQL += "\n\n" + searchSQL
+	}
 	description := fmt.Sprintf("create_collection_%s", req.Name)
 	if _, err := h.Migrations.CreateMigration(description, fullMigrationSQL); err != nil {
 		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
 	}
 
 	collection.Schema = req.Schema
+	collection.Type = data.CollectionTable
+	return c.JSON(http.StatusCreated, collection)
+}
+
+// createViewCollection creates a read-only collection backed by a view or a
+// materialized view over req.Query. Its schema is introspected from the view's columns.
+// Only admins may define views since the query can read any user table.
+func (h *Handler) createViewCollection(c echo.Context, req CreateCollectionRequest) error {
+	if role, _ := c.Get("role").(string); role != "admin" {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "admin access required to create view collections",
+		})
+	}
+	if req.HistoryEnabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "history cannot be enabled on a view collection",
+		})
+	}
+	if len(req.SearchFields) > 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "search is only supported on table collections",
+		})
+	}
+	if req.IDStrategy != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "id_strategy is only supported on table collections",
+		})
+	}
+
+	materialized := req.Type == data.CollectionMaterializedView
+	if materialized {
+		if req.RefreshPolicy == "" {
+			req.RefreshPolicy = data.RefreshManual
+		}
+		if err := data.ValidateRefreshPolicy(req.RefreshPolicy, req.RefreshSchedule); err != nil {
+			return writeError(c, err, "Invalid refresh policy")
+		}
+	}
+
+	createSQL, err := data.BuildCreateViewSQL(req.Name, req.Type, req.Query)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// Materializing runs the whole query once, which may take a while on large tables
+	timeout := 10 * time.Second
+	if materialized {
+		timeout = materializeTimeout
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+	defer cancel()
+
+	tx, err := h.DB.Pool.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to start transaction",
+		})
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// The extended protocol refuses multiple statements, so nothing can ride along with the SELECT
+	if _, err := tx.Exec(ctx, createSQL, pgx.QueryExecModeExec); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid view query: " + err.Error(),
+		})
+	}
+
+	if err := data.CheckViewSources(ctx, tx, req.Name); err != nil {
+		return writeError(c, err, "Failed to check view sources")
+	}
+
+	schema, err := data.ViewSchema(ctx, tx, req.Name)
+	if err != nil {
+		return writeError(c, err, "Failed to read view columns")
+	}
+
+	var extraSQL string
+	if materialized {
+		// Materialized views can't be written to at all, but need a unique id
+		extraSQL = data.UniqueIDIndexSQL(req.Name)
+		if _, err := tx.Exec(ctx, extraSQL); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "The id column of a materialized view must be unique: " + err.Error(),
+			})
+		}
+	} else {
+		// Reject writes at the database so every write path fails the same way
+		extraSQL = data.ViewTriggerSQL(req.Name)
+		if _, err := tx.Exec(ctx, extraSQL); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to attach read-only trigger: " + err.Error(),
+			})
+		}
+	}
+
+	if req.ListRule == "" {
+		req.ListRule = "auth"
+	}
+
+	schemaJSON, _ := json.Marshal(schema)
+	var collection Collection
+	err = tx.QueryRow(ctx, `
+		INSERT INTO _v_collections (name, schema_def, list_rule, create_rule, rls_enabled, rls_rule, kind, view_query)
+		VALUES ($1, $2, $3, 'admin', $4, $5, $6, $7)
+		RETURNING id, name, list_rule, create_rule, rls_enabled, rls_rule, created_at, updated_at
+	`, req.Name, schemaJSON, req.ListRule, req.RlsEnabled, req.RlsRule, req.Type, req.Query).Scan(
+		&collection.ID, &collection.Name, &collection.ListRule, &collection.CreateRule, &collection.RlsEnabled,
+		&collection.RlsRule, &collection.CreatedAt, &collection.UpdatedAt,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to save collection metadata: " + err.Error(),
+		})
+	}
+
+	if materialized {
+		if err := data.SetRefreshPolicy(ctx, tx, req.Name, req.RefreshPolicy, req.RefreshSchedule); err != nil {
+			return writeError(c, err, "Failed to set refresh policy")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to commit transaction",
+		})
+	}
+	if materialized && req.RefreshPolicy == data.RefreshCron && h.Cron != nil {
+		h.Cron.Refresh()
+	}
+
+	// 📜 Record Migration
+	fullMigrationSQL := fmt.Sprintf("%s;\n\n%s;", createSQL, strings.TrimSuffix(extraSQL, ";"))
+	description := fmt.Sprintf("create_view_%s", req.Name)
+	if _, err := h.Migrations.CreateMigration(description, fullMigrationSQL); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	collection.Schema = schema
+	collection.Type = req.Type
+	collection.Query = req.Query
+	if materialized {
+		collection.RefreshPolicy = req.RefreshPolicy
+		collection.RefreshSchedule = req.RefreshSchedule
+	}
 	return c.JSON(http.StatusCreated, collection)
 }
 
@@ -149,13 +390,15 @@ func (h *Handler) DeleteCollection(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name is required"})
 	}
 
-	if !data.IsValidIdentifier(name) {
+	if !data.ValidCollectionName(name) {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid collection name"})
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
+	dropSQL := data.DropCollectionSQL(name, h.DB.CollectionKind(ctx, name))
+
 	// Start transaction
 	tx, err := h.DB.Pool.Begin(ctx)
 	if err != nil {
@@ -163,8 +406,15 @@ func (h *Handler) DeleteCollection(c echo.Context) error {
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	// 1. Drop table
-	if _, err := tx.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", name)); err != nil {
+	// 1. Drop table or view, along with the join tables of its link fields
+	joinTables, err := data.JoinTables(ctx, tx, name)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	for _, join := range joinTables {
+		dropSQL += fmt.Sprintf(";\nDROP TABLE IF EXISTS %s", data.QuoteTable(join))
+	}
+	if _, err := tx.Exec(ctx, dropSQL); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -173,34 +423,139 @@ func (h *Handler) DeleteCollection(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
+	// 3. Remove recorded history
+	if _, err := tx.Exec(ctx, "DELETE FROM _v_record_history WHERE collection = $1", name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 4. Unschedule materialized view refreshes
+	tag, err := tx.Exec(ctx, "DELETE FROM _v_cron_jobs WHERE name = $1", data.RefreshJobName(name))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
+	if tag.RowsAffected() > 0 && h.Cron != nil {
+		h.Cron.Refresh()
+	}
 
 	// 📜 Record Migration
-	dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;", name)
 	description := fmt.Sprintf("delete_collection_%s", name)
-	if _, err := h.Migrations.CreateMigration(description, dropSQL); err != nil {
+	if _, err := h.Migrations.CreateMigration(description, dropSQL+";"); err != nil {
 		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
+// RenameCollection handles POST /api/collections/:name/rename
+//
+// The table, its join tables, triggers, indexes and metadata are renamed in
+// one transaction, and relations pointing at the collection follow it.
+func (h *Handler) RenameCollection(c echo.Context) error {
+	name := c.Param("name")
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "New name is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	sql, err := h.DB.RenameCollection(ctx, name, req.Name)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidSchema) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to rename collection")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("rename_collection_%s_to_%s", name, req.Name)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"name": req.Name})
+}
+
+// CloneCollection handles POST /api/collections/:name/clone
+//
+// It copies the structure, rules and triggers of a collection into a new one,
+// and its records too when data is set.
+func (h *Handler) CloneCollection(c echo.Context) error {
+	name := c.Param("name")
+	var req struct {
+		Name string `json:"name"`
+		Data bool   `json:"data"`
+	}
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "New name is required"})
+	}
+
+	// Copying the records of a large collection takes a while
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Minute)
+	defer cancel()
+
+	sql, err := h.DB.CloneCollection(ctx, name, req.Name, req.Data)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidSchema) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to clone collection")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("clone_collection_%s_to_%s", name, req.Name)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]string{"name": req.Name})
+}
+
 // UpdateCollectionRules handles PATCH /api/collections/rules
 func (h *Handler) UpdateCollectionRules(c echo.Context) error {
 	var req struct {
-		Name       string  `json:"name"`
-		ListRule   *string `json:"list_rule,omitempty"`
-		CreateRule *string `json:"create_rule,omitempty"`
-		UpdateRule *string `json:"update_rule,omitempty"`
-		DeleteRule *string `json:"delete_rule,omitempty"`
+		Name           string  `json:"name"`
+		ListRule       *string `json:"list_rule,omitempty"`
+		CreateRule     *string `json:"create_rule,omitempty"`
+		UpdateRule     *string `json:"update_rule,omitempty"`
+		DeleteRule     *string `json:"delete_rule,omitempty"`
+		UnknownFields  *string `json:"unknown_fields,omitempty"`
+		RetentionDays  *int    `json:"retention_days,omitempty"` // 0 disables purging
+		HistoryEnabled *bool   `json:"history_enabled,omitempty"`
+		// Materialized views only; refresh_schedule applies to the cron policy
+		RefreshPolicy   *string `json:"refresh_policy,omitempty"`
+		RefreshSchedule string  `json:"refresh_schedule,omitempty"`
+		// Replaces hidden_from and write of the named fields
+		FieldAccess map[string]data.FieldAccess `json:"field_access,omitempty"`
 	}
 
 	if err := c.Bind(&req); err != nil {
 		return err
 	}
 
+	if req.UnknownFields != nil && !validUnknownFieldsPolicy(*req.UnknownFields) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "unknown_fields must be 'strip' or 'reject'",
+		})
+	}
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "retention_days cannot be negative",
+		})
+	}
+	if req.HistoryEnabled != nil && *req.HistoryEnabled && h.DB.CollectionKind(c.Request().Context(), req.Name) != data.CollectionTable {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "history cannot be enabled on a view collection",
+		})
+	}
+
 	query := "UPDATE _v_collections SET updated_at = NOW()"
 	args := []any{req.Name}
 	argIdx := 2
@@ -223,6 +578,16 @@ func (h *Handler) UpdateCollectionRules(c echo.Context) error {
 	if req.DeleteRule != nil {
 		query += fmt.Sprintf(", delete_rule = $%d", argIdx)
 		args = append(args, *req.DeleteRule)
+		argIdx++
+	}
+	if req.UnknownFields != nil {
+		query += fmt.Sprintf(", unknown_fields = $%d", argIdx)
+		args = append(args, *req.UnknownFields)
+		argIdx++
+	}
+	if req.RetentionDays != nil {
+		query += fmt.Sprintf(", retention_days = NULLIF($%d, 0)", argIdx)
+		args = append(args, *req.RetentionDays)
 	}
 
 	query += " WHERE name = $1"
@@ -232,9 +597,37 @@ func (h *Handler) UpdateCollectionRules(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
+	if req.HistoryEnabled != nil {
+		if err := h.DB.SetHistoryEnabled(c.Request().Context(), req.Name, *req.HistoryEnabled); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	if len(req.FieldAccess) > 0 {
+		if err := h.DB.UpdateFieldAccess(c.Request().Context(), req.Name, req.FieldAccess); err != nil {
+			if errors.Is(err, data.ErrInvalidSchema) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	if req.RefreshPolicy != nil {
+		if err := h.DB.UpdateRefreshPolicy(c.Request().Context(), req.Name, *req.RefreshPolicy, req.RefreshSchedule); err != nil {
+			return writeError(c, err, "Failed to update refresh policy")
+		}
+		if h.Cron != nil {
+			h.Cron.Refresh()
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"status": "updated"})
 }
 
+func validUnknownFieldsPolicy(policy string) bool {
+	return policy == data.UnknownFieldsStrip || policy == data.UnknownFieldsReject
+}
+
 // ListCollections handles GET /api/collections
 func (h *Handler) ListCollections(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
@@ -252,7 +645,10 @@ func (h *Handler) ListCollections(c echo.Context) error {
 
 	// Fetch metadata from _v_collections to match details
 	rows, err := h.DB.Pool.Query(ctx, `
-		SELECT name, schema_def, list_rule, create_rule, created_at, updated_at
+		SELECT name, schema_def, list_rule, create_rule, COALESCE(kind, 'table'), COALESCE(view_query, ''),
+			COALESCE(refresh_policy, ''), COALESCE(refresh_schedule, ''),
+			COALESCE(search_fields, '[]'::jsonb), COALESCE(search_language, ''),
+			CASE WHEN COALESCE(kind, 'table') = 'table' THEN COALESCE(id_strategy, '') ELSE '' END, created_at, updated_at
 		FROM _v_collections
 	`)
 
@@ -262,7 +658,7 @@ func (h *Handler) ListCollections(c echo.Context) error {
 		for rows.Next() {
 			var col Collection
 			var schemaJSON []byte
-			if err := rows.Scan(&col.Name, &schemaJSON, &col.ListRule, &col.CreateRule, &col.CreatedAt, &col.UpdatedAt); err == nil {
+			if err := rows.Scan(&col.Name, &schemaJSON, &col.ListRule, &col.CreateRule, &col.Type, &col.Query, &col.RefreshPolicy, &col.RefreshSchedule, &col.SearchFields, &col.SearchLanguage, &col.IDStrategy, &col.CreatedAt, &col.UpdatedAt); err == nil {
 				if err := json.Unmarshal(schemaJSON, &col.Schema); err == nil {
 					metaMap[col.Name] = col
 				}
@@ -284,6 +680,7 @@ func (h *Handler) ListCollections(c echo.Context) error {
 				Name:       tableName,
 				ListRule:   "public",
 				CreateRule: "admin",
+				Type:       data.CollectionTable,
 				Schema:     []data.FieldSchema{}, // Will be filled by dynamic introspection on select
 			})
 		}
@@ -318,7 +715,7 @@ func (h *Handler) GetTableSchema(c echo.Context) error {
 	return c.JSON(http.StatusOK, schema)
 }
 
-// ListSchemas handles GET /api/schemas
+// ListSchemas handles GET /api/collections/schemas
 func (h *Handler) ListSchemas(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
@@ -333,6 +730,40 @@ func (h *Handler) ListSchemas(c echo.Context) error {
 	return c.JSON(http.StatusOK, schemas)
 }
 
+// UpdateSchemaRules handles PUT /api/collections/schemas/:schema
+//
+// It sets the access rules shared by the collections of a Postgres schema,
+// creating the schema if it doesn't exist yet.
+func (h *Handler) UpdateSchemaRules(c echo.Context) error {
+	schema := c.Param("schema")
+
+	var req data.SchemaRulesUpdate
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	rules, sql, err := h.DB.UpdateSchemaRules(ctx, schema, req)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidSchema) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to update schema rules")
+	}
+
+	// 📜 Record Migration
+	if sql != "" {
+		description := fmt.Sprintf("create_schema_%s", schema)
+		if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+			log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
 // AddColumn handles POST /api/tables/:name/columns
 func (h *Handler) AddColumn(c echo.Context) error {
 	tableName := c.Param("name")
@@ -346,6 +777,9 @@ func (h *Handler) AddColumn(c echo.Context) error {
 
 	sql, err := h.DB.AddColumn(ctx, tableName, field)
 	if err != nil {
+		if errors.Is(err, data.ErrInvalidSchema) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -358,6 +792,44 @@ func (h *Handler) AddColumn(c echo.Context) error {
 	return c.JSON(http.StatusCreated, field)
 }
 
+// AlterColumn handles PATCH /api/tables/:name/columns/:col
+//
+// It renames a column or changes its type (with an optional USING cast),
+// nullability or default. Besides the migration, a .down.sql file undoing
+// the change is recorded.
+func (h *Handler) AlterColumn(c echo.Context) error {
+	tableName := c.Param("name")
+	columnName := c.Param("col")
+	var change data.ColumnChange
+	if err := c.Bind(&change); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid body"})
+	}
+
+	// Type changes rewrite the table
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Minute)
+	defer cancel()
+
+	up, down, err := h.DB.AlterColumn(ctx, tableName, columnName, change)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidSchema) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to alter column")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("alter_column_%s_on_%s", columnName, tableName)
+	if _, err := h.Migrations.CreateReversibleMigration(description, up, down); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	name := columnName
+	if change.Name != "" {
+		name = change.Name
+	}
+	return c.JSON(http.StatusOK, map[string]string{"column": name, "migration": up})
+}
+
 // DeleteColumn handles DELETE /api/tables/:name/columns/:col
 func (h *Handler) DeleteColumn(c echo.Context) error {
 	tableName := c.Param("name")
@@ -719,7 +1191,7 @@ func (h *Handler) FixHealthIssues(c echo.Context) error {
 		}
 		tableName := parts[1]
 
-		if !data.IsValidIdentifier(tableName) {
+		if !data.ValidCollectionName(tableName) {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid table name"})
 		}
 
@@ -731,7 +1203,7 @@ func (h *Handler) FixHealthIssues(c echo.Context) error {
 		defer func() { _ = tx.Rollback(ctx) }()
 
 		// 1. Primary PG RLS (Native)
-		sql := fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", tableName)
+		sql := fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", data.QuoteTable(tableName))
 		if _, err := tx.Exec(ctx, sql); err != nil {
 			log.Printf("Warning: Failed to enable native RLS (might not have permission): %v", err)
 		}
@@ -775,13 +1247,13 @@ func (h *Handler) FixHealthIssues(c echo.Context) error {
 		colName := parts[1]
 		tableName := parts[3]
 
-		if !data.IsValidIdentifier(tableName) || !data.IsValidIdentifier(colName) {
+		if !data.ValidCollectionName(tableName) || !data.IsValidIdentifier(colName) {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid identifiers"})
 		}
 
-		// Create index
-		indexName := fmt.Sprintf("idx_%s_%s", tableName, colName)
-		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", indexName, tableName, colName)
+		// Create index; it lands in the schema of its table
+		indexName := fmt.Sprintf("idx_%s_%s", strings.ReplaceAll(tableName, ".", "_"), colName)
+		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", indexName, data.QuoteTable(tableName), colName)
 		if _, err := h.DB.Pool.Exec(ctx, sql); err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create index: " + err.Error()})
 		}
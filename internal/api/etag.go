@@ -0,0 +1,95 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// recordETag derives a strong ETag from a record's updated_at. The tag is the
+// quoted RFC 3339 timestamp, so clients holding a row from a list response can
+// build If-Match themselves. Records without updated_at get no ETag.
+func recordETag(record map[string]any) string {
+	t, ok := record["updated_at"].(time.Time)
+	if !ok {
+		return ""
+	}
+	return `"` + t.UTC().Format(time.RFC3339Nano) + `"`
+}
+
+// ifMatchVersions parses an If-Match header into the record versions it accepts.
+// A missing header or "*" imposes no version check.
+func ifMatchVersions(c echo.Context) ([]time.Time, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	var versions []time.Time
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so weak tags can never match
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, strings.Trim(tag, `"`))
+		if err != nil {
+			return nil, fmt.Errorf("invalid If-Match entity tag %s", tag)
+		}
+		versions = append(versions, t)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("If-Match requires a strong entity tag")
+	}
+	return versions, nil
+}
+
+// etagMatches applies the weak comparison used by If-None-Match
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header and reports whether the client's
+// If-None-Match already covers it, in which case a 304 should be sent
+func notModified(c echo.Context, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	c.Response().Header().Set("ETag", etag)
+	return etagMatches(c.Request().Header.Get("If-None-Match"), etag)
+}
+
+// pageETag is a weak validator for a list response body
+func pageETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// jsonWithETag writes v as JSON tagged with a weak ETag of the body, answering
+// 304 Not Modified when the client already holds the same representation
+func jsonWithETag(c echo.Context, status int, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if notModified(c, pageETag(body)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSONBlob(status, body)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordETag(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	updated := time.Date(2024, 5, 1, 5, 0, 0, 123456000, loc)
+
+	assert.Equal(t, `"2024-05-01T10:00:00.123456Z"`, recordETag(map[string]any{"updated_at": updated}))
+	assert.Equal(t, "", recordETag(map[string]any{"id": "1"}))
+}
+
+func TestIfMatchVersions(t *testing.T) {
+	e := echo.New()
+	newContext := func(header string) echo.Context {
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		if header != "" {
+			req.Header.Set("If-Match", header)
+		}
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	versions, err := ifMatchVersions(newContext(""))
+	assert.NoError(t, err)
+	assert.Nil(t, versions)
+
+	versions, err = ifMatchVersions(newContext("*"))
+	assert.NoError(t, err)
+	assert.Nil(t, versions)
+
+	// Offsets are compared as instants, so a JSON timestamp in local time still matches
+	versions, err = ifMatchVersions(newContext(`"2024-05-01T10:00:00.123456Z", "2024-05-01T07:00:00-03:00"`))
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.True(t, versions[1].Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+
+	_, err = ifMatchVersions(newContext(`W/"2024-05-01T10:00:00Z"`))
+	assert.Error(t, err)
+
+	_, err = ifMatchVersions(newContext(`"abc"`))
+	assert.Error(t, err)
+}
+
+func TestNotModified(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"No header", "", false},
+		{"Same tag", `"v1"`, true},
+		{"Weak comparison", `W/"v1"`, true},
+		{"Listed tag", `"v0", "v1"`, true},
+		{"Wildcard", "*", true},
+		{"Different tag", `"v2"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			assert.Equal(t, tt.want, notModified(e.NewContext(req, rec), `"v1"`))
+			assert.Equal(t, `"v1"`, rec.Header().Get("ETag"))
+		})
+	}
+}
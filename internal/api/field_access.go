@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/Xangel0s/OzyBase/internal/realtime"
+	"github.com/labstack/echo/v4"
+)
+
+// callerRole is the role field access rules are checked against
+func callerRole(c echo.Context) string {
+	if role, _ := c.Get("role").(string); role != "" {
+		return role
+	}
+	return data.RoleAnonymous
+}
+
+// ApplyFieldAccess drops the fields role may not write from a payload. op is
+// "create", "update" or "upsert"; an upsert may update an existing record, so
+// create-only fields are treated as on update. With the reject policy the
+// fields are reported instead, hidden ones as unknown so they stay secret.
+func ApplyFieldAccess(schema []data.FieldSchema, body map[string]any, role, op, unknownFields string) (map[string]any, []ValidationError) {
+	var errs []ValidationError
+	forbidden := make(map[string]bool)
+
+	for _, f := range schema {
+		if _, present := body[f.Name]; !present || f.WritableBy(role, op == "create") {
+			continue
+		}
+		forbidden[f.Name] = true
+
+		if unknownFields == data.UnknownFieldsReject {
+			message := "is read-only"
+			switch {
+			case f.HiddenFor(role):
+				message = "unknown field"
+			case f.Write == data.WriteOnCreate:
+				message = "can only be set on create"
+			}
+			errs = append(errs, ValidationError{Field: f.Name, Message: message})
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return data.StripHidden(body, forbidden), errs
+}
+
+// hiddenFieldCache holds the fields a realtime stream's role can't read, per
+// collection, until the broker reports a change to the collection's metadata
+type hiddenFieldCache struct {
+	db      *data.DB
+	broker  *realtime.Broker
+	role    string
+	entries map[string]hiddenFieldEntry
+}
+
+type hiddenFieldEntry struct {
+	generation uint64
+	hidden     map[string]bool
+}
+
+func newHiddenFieldCache(db *data.DB, broker *realtime.Broker, role string) *hiddenFieldCache {
+	return &hiddenFieldCache{db: db, broker: broker, role: role, entries: make(map[string]hiddenFieldEntry)}
+}
+
+// lookup returns the hidden fields of table, loading them on first use and
+// after the collection changed. Failures aren't cached.
+func (fc *hiddenFieldCache) lookup(ctx context.Context, table string) (map[string]bool, error) {
+	// Read the generation first so a change racing the load triggers a reload
+	generation := fc.broker.CollectionGeneration(table)
+	if entry, ok := fc.entries[table]; ok && entry.generation == generation {
+		return entry.hidden, nil
+	}
+	hidden, err := fc.db.HiddenFields(ctx, table, fc.role)
+	if err != nil {
+		return nil, err
+	}
+	fc.entries[table] = hiddenFieldEntry{generation: generation, hidden: hidden}
+	return hidden, nil
+}
+
+// filterEvent strips the fields the stream's role can't read from a realtime
+// event. The payload is either the record itself or the trigger's envelope
+// with record and old.
+func filterEvent(ctx context.Context, fields *hiddenFieldCache, event realtime.Event) realtime.Event {
+	payload, ok := event.Data.(map[string]any)
+	if !ok || fields.db == nil {
+		return event
+	}
+
+	table := event.Table
+	if name, ok := payload["table"].(string); ok {
+		table = name
+	}
+	hidden, err := fields.lookup(ctx, table)
+	if err != nil {
+		// Without the rules nothing of the record can be shown
+		event.Data = nil
+		return event
+	}
+	if len(hidden) == 0 {
+		return event
+	}
+
+	filtered := data.StripHidden(payload, hidden)
+	for _, key := range []string{"record", "old"} {
+		if record, ok := filtered[key].(map[string]any); ok {
+			filtered[key] = data.StripHidden(record, hidden)
+		}
+	}
+	event.Data = filtered
+	return event
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFieldAccess(t *testing.T) {
+	schema := []data.FieldSchema{
+		{Name: "name", Type: "text"},
+		{Name: "username", Type: "text", FieldAccess: data.FieldAccess{Write: data.WriteOnCreate}},
+		{Name: "password_reset_at", Type: "timestamptz", FieldAccess: data.FieldAccess{Write: data.WriteNever}},
+		{Name: "internal_notes", Type: "text", FieldAccess: data.FieldAccess{HiddenFrom: []string{data.HiddenFromAll}}},
+	}
+	body := map[string]any{
+		"name":              "Ada",
+		"username":          "ada",
+		"password_reset_at": "2024-05-01T10:00:00Z",
+		"internal_notes":    "vip",
+	}
+
+	t.Run("Strips forbidden fields", func(t *testing.T) {
+		out, errs := ApplyFieldAccess(schema, body, "user", "create", data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+		assert.Equal(t, map[string]any{"name": "Ada", "username": "ada"}, out)
+
+		out, errs = ApplyFieldAccess(schema, body, "user", "update", data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+		assert.Equal(t, map[string]any{"name": "Ada"}, out)
+	})
+
+	t.Run("Rejects forbidden fields", func(t *testing.T) {
+		_, errs := ApplyFieldAccess(schema, body, "user", "upsert", data.UnknownFieldsReject)
+		assert.Equal(t, []ValidationError{
+			{Field: "internal_notes", Message: "unknown field"},
+			{Field: "password_reset_at", Message: "is read-only"},
+			{Field: "username", Message: "can only be set on create"},
+		}, errs)
+	})
+
+	t.Run("Admins see hidden fields", func(t *testing.T) {
+		out, errs := ApplyFieldAccess(schema, body, "admin", "create", data.UnknownFieldsReject)
+		assert.Equal(t, []ValidationError{{Field: "password_reset_at", Message: "is read-only"}}, errs)
+		assert.Contains(t, out, "internal_notes")
+	})
+}
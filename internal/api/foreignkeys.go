@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// foreignKeyError maps invalid definitions to 400, unknown constraints to 404
+// and a taken name for the supporting index to 409
+func foreignKeyError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, data.ErrInvalidSchema):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, data.ErrForeignKeyNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, data.ErrIndexExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return writeError(c, err, fallback)
+}
+
+// ListForeignKeys handles GET /api/tables/:name/foreign-keys
+func (h *Handler) ListForeignKeys(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	keys, err := h.DB.ListForeignKeys(ctx, c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if keys == nil {
+		keys = []data.ForeignKeyInfo{}
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// CreateForeignKey handles POST /api/tables/:name/foreign-keys
+//
+// It references another collection from a column, indexing the column when
+// no index covers it. Existing records must reference existing ones unless
+// not_valid is set; such a key is checked later through ValidateForeignKey.
+func (h *Handler) CreateForeignKey(c echo.Context) error {
+	tableName := c.Param("name")
+	var spec data.ForeignKeySpec
+	if err := c.Bind(&spec); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid body"})
+	}
+
+	// Checking existing records scans the table
+	ctx, cancel := context.WithTimeout(c.Request().Context(), indexBuildTimeout)
+	defer cancel()
+
+	name, up, down, err := h.DB.CreateForeignKey(ctx, tableName, spec)
+	if err != nil {
+		return foreignKeyError(c, err, "Failed to create foreign key")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("create_foreign_key_%s_on_%s", name, tableName)
+	if _, err := h.Migrations.CreateReversibleMigration(description, up, down); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]string{"foreign_key": name, "migration": up})
+}
+
+// ValidateForeignKey handles POST /api/tables/:name/foreign-keys/:constraint/validate
+//
+// It checks the existing records of a key created with not_valid without
+// blocking writes to the table.
+func (h *Handler) ValidateForeignKey(c echo.Context) error {
+	tableName, name := c.Param("name"), c.Param("constraint")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), indexBuildTimeout)
+	defer cancel()
+
+	sql, err := h.DB.ValidateForeignKey(ctx, tableName, name)
+	if err != nil {
+		return foreignKeyError(c, err, "Failed to validate foreign key")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("validate_foreign_key_%s_on_%s", name, tableName)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"foreign_key": name})
+}
+
+// DropForeignKey handles DELETE /api/tables/:name/foreign-keys/:constraint
+//
+// The index supporting the key is kept; drop it through DropIndex if it's no
+// longer needed.
+func (h *Handler) DropForeignKey(c echo.Context) error {
+	tableName, name := c.Param("name"), c.Param("constraint")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	up, down, err := h.DB.DropForeignKey(ctx, tableName, name)
+	if err != nil {
+		return foreignKeyError(c, err, "Failed to drop foreign key")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("drop_foreign_key_%s_from_%s", name, tableName)
+	if _, err := h.Migrations.CreateReversibleMigration(description, up, down); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
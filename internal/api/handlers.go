@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"runtime"
 	"sync"
@@ -66,6 +67,8 @@ type Handler struct {
 	PubSub       realtime.PubSub
 	Migrations   *migrations.Generator
 	Applier      *migrations.Applier
+	Cron         *realtime.CronManager
+	MaxPageSize  int
 }
 
 // NewHandler creates a new Handler with the given dependencies
@@ -94,6 +97,7 @@ func NewHandler(db *data.DB, broker *realtime.Broker, webhooks *realtime.Webhook
 		PubSub:       ps,
 		Migrations:   migrator,
 		Applier:      applier,
+		MaxPageSize:  data.DefaultMaxPageSize,
 	}
 }
 
@@ -162,6 +166,29 @@ func (h *Handler) StartLogExporter(ctx context.Context) {
 	}
 }
 
+// StartRetentionWorker permanently deletes trashed records once they are older
+// than their collection's retention_days
+func (h *Handler) StartRetentionWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		purged, err := h.DB.PurgeExpiredRecords(ctx)
+		if err != nil {
+			log.Printf("⚠️ Retention purge failed: %v", err)
+		}
+		for collection, n := range purged {
+			log.Printf("🗑️ Purged %d expired records from %s", n, collection)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (h *Handler) flushLogsToSIEM(ctx context.Context) {
 	// Get latest logs from memory buffer
 	h.Metrics.RLock()
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// RecordHistory handles GET /api/collections/:name/records/:id/history
+// It lists the recorded changes to a record, newest first. ?limit= caps the entries.
+func (h *Handler) RecordHistory(c echo.Context) error {
+	collectionName := c.Param("name")
+	recordID := c.Param("id")
+
+	if collectionName == "" || recordID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name and record ID are required",
+		})
+	}
+
+	limit := h.MaxPageSize
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+		}
+		if h.MaxPageSize <= 0 || n < h.MaxPageSize {
+			limit = n
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+	entries, err := h.DB.RecordHistory(ctx, collectionName, recordID, ownerField, ownerID, limit)
+	if err != nil {
+		return writeRecordError(c, err, "Failed to fetch record history")
+	}
+	if len(entries) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no history for this record"})
+	}
+
+	hidden, err := h.DB.HiddenFields(ctx, collectionName, callerRole(c))
+	if err != nil {
+		return writeRecordError(c, err, "Failed to fetch record history")
+	}
+	for i := range entries {
+		entries[i].Data = data.StripHidden(entries[i].Data, hidden)
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// recordAsOf answers GET /records/:id?as_of= from the record's history
+func (h *Handler) recordAsOf(ctx context.Context, c echo.Context, collectionName, recordID, ownerField, ownerID, asOf string) error {
+	t, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "as_of must be an RFC 3339 timestamp"})
+	}
+
+	record, err := h.DB.RecordAsOf(ctx, collectionName, recordID, ownerField, ownerID, t)
+	if err != nil {
+		return writeRecordError(c, err, "Failed to fetch record")
+	}
+	hidden, err := h.DB.HiddenFields(ctx, collectionName, callerRole(c))
+	if err != nil {
+		return writeRecordError(c, err, "Failed to fetch record")
+	}
+	return c.JSON(http.StatusOK, data.StripHidden(record, hidden))
+}
+
+// RevertRecord handles POST /api/collections/:name/records/:id/revert
+// The body {"version": N} names a history entry whose data is written back.
+func (h *Handler) RevertRecord(c echo.Context) error {
+	collectionName := c.Param("name")
+	recordID := c.Param("id")
+
+	if collectionName == "" || recordID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name and record ID are required",
+		})
+	}
+
+	var req struct {
+		Version int64 `json:"version"`
+	}
+	if err := c.Bind(&req); err != nil || req.Version <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "version is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+	if err := h.DB.RevertRecord(ctx, collectionName, recordID, req.Version, ownerField, ownerID, callerRole(c)); err != nil {
+		return writeRecordError(c, err, "Failed to revert record")
+	}
+
+	record, err := h.DB.GetRecord(ctx, collectionName, recordID, ownerField, ownerID, h.selection(c))
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]string{"id": recordID})
+	}
+	if etag := recordETag(record); etag != "" {
+		c.Response().Header().Set("ETag", etag)
+	}
+	return c.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,602 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxImportErrors caps the row errors reported back by a skip_errors import
+	maxImportErrors = 100
+	// importTimeout bounds a single streaming import or export
+	importTimeout = 10 * time.Minute
+	// exportFlushRows is how many rows an export buffers before flushing
+	exportFlushRows = 1000
+)
+
+// rowError is a problem with a single input row. Imports abort on the first
+// one unless skip_errors is set, in which case the row is reported and skipped.
+type rowError struct {
+	Row     int               `json:"row"`
+	Message string            `json:"error"`
+	Fields  []ValidationError `json:"fields,omitempty"`
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// rowSource yields decoded input rows, returning a nil row at end of input.
+// Recoverable problems with one row are reported as *rowError.
+type rowSource func() (map[string]any, error)
+
+// ImportRecords handles POST /api/tables/:name/import
+//
+// The body is streamed into the table with COPY as a JSON array, NDJSON or CSV,
+// chosen by ?format= or the Content-Type. CSV headers name the columns and can
+// be renamed with ?map=Header:column,... Options: ?upsert=true merges rows on
+// ?on_conflict= (default id), ?skip_errors=true skips rows that fail to parse
+// or validate and reports them, and ?dry_run=true rolls everything back.
+func (h *Handler) ImportRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	if collectionName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name required"})
+	}
+
+	format, err := importFormat(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	mapping, err := parseColumnMap(c.QueryParam("map"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	opts := data.ImportOptions{
+		Upsert: c.QueryParam("upsert") == "true",
+		DryRun: c.QueryParam("dry_run") == "true",
+	}
+	if onConflict := c.QueryParam("on_conflict"); onConflict != "" {
+		for _, col := range strings.Split(onConflict, ",") {
+			opts.OnConflict = append(opts.OnConflict, strings.TrimSpace(col))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), importTimeout)
+	defer cancel()
+
+	im := &importer{skipErrors: c.QueryParam("skip_errors") == "true", role: callerRole(c), upsert: opts.Upsert}
+	meta, err := h.DB.GetCollectionMeta(ctx, collectionName)
+	switch {
+	case err == nil:
+		im.schema, im.unknownFields = meta.Schema, meta.UnknownFields
+	case !errors.Is(err, data.ErrCollectionNotFound):
+		// Importing without the schema would skip validation and field access
+		return writeError(c, err, "Failed to import records")
+	}
+
+	body := c.Request().Body
+	switch format {
+	case "csv":
+		im.source, err = csvSource(body, mapping, jsonFields(im.schema))
+	case "ndjson":
+		im.source = ndjsonSource(body)
+	default:
+		im.source, err = jsonArraySource(body)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// The first good row fixes the column list COPY is started with
+	first, err := im.next()
+	if err != nil {
+		return importFailed(c, err)
+	}
+
+	var imported int64
+	if first != nil {
+		next := func() ([]any, error) {
+			if first != nil {
+				row := first
+				first = nil
+				return row, nil
+			}
+			return im.next()
+		}
+		imported, err = h.DB.CopyRecords(ctx, collectionName, im.columns, next, opts)
+		if err != nil {
+			return importFailed(c, err)
+		}
+	}
+
+	rowErrors := im.errors
+	if rowErrors == nil {
+		rowErrors = []rowError{}
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"message":  fmt.Sprintf("Imported %d records", imported),
+		"imported": imported,
+		"skipped":  im.skipped,
+		"errors":   rowErrors,
+		"dry_run":  opts.DryRun,
+	})
+}
+
+// importFailed reports a row error with its position, and anything else
+// through writeError
+func importFailed(c echo.Context, err error) error {
+	var re *rowError
+	if errors.As(err, &re) {
+		body := map[string]any{"error": re.Error(), "row": re.Row}
+		if len(re.Fields) > 0 {
+			body["fields"] = re.Fields
+		}
+		return c.JSON(http.StatusBadRequest, body)
+	}
+	// e.g. the import body limit was exceeded mid-stream
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return writeError(c, err, "Failed to import records")
+}
+
+// importFormat picks the input format from ?format=, falling back to the
+// Content-Type and then to a JSON array
+func importFormat(c echo.Context) (string, error) {
+	format := c.QueryParam("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			format = "ndjson"
+		default:
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "csv", "ndjson", "json":
+		return format, nil
+	}
+	return "", fmt.Errorf("format must be 'csv', 'ndjson' or 'json'")
+}
+
+// parseColumnMap parses ?map=Header:column,... into a header to column lookup
+func parseColumnMap(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if s == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid map entry %q, expected Header:column", pair)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+// jsonFields lists the schema fields whose CSV cells hold JSON documents
+func jsonFields(schema []data.FieldSchema) map[string]bool {
+	fields := make(map[string]bool)
+	for _, f := range schema {
+		if t := strings.ToLower(f.Type); t == "json" || t == "jsonb" {
+			fields[f.Name] = true
+		}
+	}
+	return fields
+}
+
+// csvSource reads rows keyed by the (mapped) header line. Empty cells become
+// NULL, and cells of JSON fields are decoded so validation sees the document
+// rather than its text.
+func csvSource(r io.Reader, mapping map[string]string, jsonCols map[string]bool) (rowSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return func() (map[string]any, error) { return nil, nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if mapped, ok := mapping[name]; ok {
+			name = mapped
+		}
+		columns[i] = name
+	}
+
+	return func() (map[string]any, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &rowError{Message: parseErr.Err.Error()}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != len(columns) {
+			return nil, &rowError{Message: fmt.Sprintf("expected %d fields, got %d", len(columns), len(record))}
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, cell := range record {
+			if cell == "" {
+				row[columns[i]] = nil
+				continue
+			}
+			var doc any
+			if jsonCols[columns[i]] && json.Unmarshal([]byte(cell), &doc) == nil {
+				row[columns[i]] = doc
+				continue
+			}
+			row[columns[i]] = cell
+		}
+		return row, nil
+	}, nil
+}
+
+// ndjsonSource reads one JSON object per line, skipping blank lines
+func ndjsonSource(r io.Reader) rowSource {
+	reader := bufio.NewReader(r)
+	return func() (map[string]any, error) {
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				if err == io.EOF {
+					return nil, nil
+				}
+				continue
+			}
+
+			var row map[string]any
+			if jsonErr := json.Unmarshal(line, &row); jsonErr != nil || row == nil {
+				return nil, &rowError{Message: "line is not a JSON object"}
+			}
+			return row, nil
+		}
+	}
+}
+
+// jsonArraySource streams the objects of a top-level JSON array without
+// decoding the whole body at once
+func jsonArraySource(r io.Reader) (rowSource, error) {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("invalid JSON array")
+	}
+
+	return func() (map[string]any, error) {
+		if !dec.More() {
+			return nil, nil
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON array: %v", data.ErrInvalidQuery, err)
+		}
+		var row map[string]any
+		if err := json.Unmarshal(raw, &row); err != nil || row == nil {
+			return nil, &rowError{Message: "element is not a JSON object"}
+		}
+		return row, nil
+	}, nil
+}
+
+// importer turns source rows into COPY rows, validating them against the
+// collection schema and the field access of role, and keeping the report of
+// skipped rows
+type importer struct {
+	source        rowSource
+	schema        []data.FieldSchema
+	unknownFields string
+	skipErrors    bool
+	role          string
+	upsert        bool // rows may overwrite existing records
+
+	columns []string
+	index   map[string]int
+	row     int
+	skipped int
+	errors  []rowError
+}
+
+// next returns the values of the next good row in column order, or nil at the
+// end of input. The first good row fixes the columns; later rows may leave
+// columns out (written as NULL) but not add new ones.
+func (im *importer) next() ([]any, error) {
+	for {
+		record, err := im.source()
+		if err == nil && record == nil {
+			return nil, nil
+		}
+		im.row++
+
+		var values []any
+		if err == nil {
+			values, err = im.values(record)
+		}
+		if err == nil {
+			return values, nil
+		}
+
+		var re *rowError
+		if !errors.As(err, &re) {
+			return nil, err
+		}
+		re.Row = im.row
+		if !im.skipErrors {
+			return nil, re
+		}
+		im.skipped++
+		if len(im.errors) < maxImportErrors {
+			im.errors = append(im.errors, *re)
+		}
+	}
+}
+
+func (im *importer) values(record map[string]any) ([]any, error) {
+	// Timestamps are owned by the database; ids are kept so exports round-trip
+	delete(record, "created_at")
+	delete(record, "updated_at")
+	delete(record, "deleted_at")
+
+	if len(im.schema) > 0 {
+		op := "create"
+		if im.upsert {
+			op = "upsert"
+		}
+		var fieldErrs []ValidationError
+		record, fieldErrs = ApplyFieldAccess(im.schema, record, im.role, op, im.unknownFields)
+		if len(fieldErrs) > 0 {
+			return nil, &rowError{Message: "validation failed", Fields: fieldErrs}
+		}
+		record, fieldErrs = ValidateRecord(im.schema, record, false, im.unknownFields)
+		if len(fieldErrs) > 0 {
+			return nil, &rowError{Message: "validation failed", Fields: fieldErrs}
+		}
+	}
+	if len(record) == 0 {
+		return nil, &rowError{Message: "row has no fields"}
+	}
+
+	if im.columns == nil {
+		for col := range record {
+			im.columns = append(im.columns, col)
+		}
+		sort.Strings(im.columns)
+		im.index = make(map[string]int, len(im.columns))
+		for i, col := range im.columns {
+			im.index[col] = i
+		}
+	}
+
+	values := make([]any, len(im.columns))
+	for col, val := range record {
+		i, ok := im.index[col]
+		if !ok {
+			return nil, &rowError{Message: fmt.Sprintf("field %q is not present in the first row", col)}
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// ExportRecords handles GET /api/tables/:name/export
+//
+// Rows are streamed as CSV (default) or NDJSON per ?format=. The usual list
+// filters, ?order= and ?trashed= apply, and ?limit= optionally caps the rows.
+func (h *Handler) ExportRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	if collectionName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name required"})
+	}
+
+	opts := data.ListOptions{
+		Filters: c.QueryParams(),
+		OrderBy: c.QueryParam("order"),
+		Trashed: c.QueryParam("trashed"),
+		Select:  data.Selection{Role: callerRole(c)},
+	}
+	switch opts.Trashed {
+	case data.TrashedExclude, data.TrashedOnly, data.TrashedWith:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "trashed must be 'only' or 'with'"})
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+		}
+		opts.Limit = limit
+	}
+
+	stream := &exportStream{res: c.Response(), filename: collectionName}
+	var w data.RowWriter
+	switch c.QueryParam("format") {
+	case "", "csv":
+		stream.contentType, stream.filename = "text/csv; charset=utf-8", collectionName+".csv"
+		w = &csvRowWriter{exportStream: stream, w: csv.NewWriter(stream.res)}
+	case "ndjson":
+		stream.contentType, stream.filename = "application/x-ndjson", collectionName+".ndjson"
+		w = &ndjsonRowWriter{exportStream: stream, enc: json.NewEncoder(stream.res)}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be 'csv' or 'ndjson'"})
+	}
+
+	// Restrict rows to the caller's own when RLS is enabled
+	opts.OwnerField, opts.OwnerID = h.extractRlsOwnerInfo(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), importTimeout)
+	defer cancel()
+
+	err := h.DB.ExportRecords(ctx, collectionName, opts, w)
+	if err == nil {
+		err = stream.flush()
+	}
+	if err != nil {
+		if stream.res.Committed {
+			// The status is already sent; all we can do is cut the stream short
+			log.Printf("⚠️ ExportRecords failed: %v", err)
+			return nil
+		}
+		return writeError(c, err, "Failed to export records")
+	}
+	return nil
+}
+
+// exportStream commits the download headers once the query has produced its
+// columns, so earlier failures can still be answered with a JSON error
+type exportStream struct {
+	res         *echo.Response
+	contentType string
+	filename    string
+	rows        int
+	flushRows   func() error
+}
+
+func (s *exportStream) begin() {
+	s.res.Header().Set(echo.HeaderContentType, s.contentType)
+	s.res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", s.filename))
+	s.res.WriteHeader(http.StatusOK)
+}
+
+// wrote counts a row and flushes every exportFlushRows rows
+func (s *exportStream) wrote() error {
+	s.rows++
+	if s.rows%exportFlushRows != 0 {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *exportStream) flush() error {
+	if !s.res.Committed {
+		s.begin()
+	}
+	if s.flushRows != nil {
+		if err := s.flushRows(); err != nil {
+			return err
+		}
+	}
+	s.res.Flush()
+	return nil
+}
+
+// csvRowWriter writes an export as CSV with a header line
+type csvRowWriter struct {
+	*exportStream
+	w *csv.Writer
+}
+
+func (cw *csvRowWriter) WriteHeader(columns []string) error {
+	cw.flushRows = func() error {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	cw.begin()
+	return cw.w.Write(columns)
+}
+
+func (cw *csvRowWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		cell, err := csvCell(v)
+		if err != nil {
+			return err
+		}
+		record[i] = cell
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+	return cw.wrote()
+}
+
+// ndjsonRowWriter writes an export as one JSON object per line
+type ndjsonRowWriter struct {
+	*exportStream
+	enc     *json.Encoder
+	columns []string
+}
+
+func (nw *ndjsonRowWriter) WriteHeader(columns []string) error {
+	nw.columns = columns
+	nw.begin()
+	return nil
+}
+
+func (nw *ndjsonRowWriter) WriteRow(values []any) error {
+	row := make(map[string]any, len(values))
+	for i, v := range values {
+		if n, ok := v.(pgtype.Numeric); ok {
+			b, err := n.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			v = json.RawMessage(b)
+		}
+		row[nw.columns[i]] = v
+	}
+	if err := nw.enc.Encode(row); err != nil {
+		return err
+	}
+	return nw.wrote()
+}
+
+// csvCell renders a column value the way the CSV import reads it back
+func csvCell(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val), nil
+	case pgtype.Numeric:
+		b, err := val.MarshalJSON()
+		return string(b), err
+	case map[string]any, []any:
+		b, err := json.Marshal(val)
+		return string(b), err
+	}
+	return fmt.Sprint(v), nil
+}
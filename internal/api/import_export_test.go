@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain reads a source to the end, collecting rows and row errors
+func drain(t *testing.T, src rowSource) ([]map[string]any, []string) {
+	t.Helper()
+	var rows []map[string]any
+	var errs []string
+	for {
+		row, err := src()
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if row == nil {
+			return rows, errs
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestImportFormat(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{"Default", "", "", "json", false},
+		{"JSON body", "", "application/json", "json", false},
+		{"CSV body", "", "text/csv; charset=utf-8", "csv", false},
+		{"NDJSON body", "", "application/x-ndjson", "ndjson", false},
+		{"Query wins", "format=csv", "application/json", "csv", false},
+		{"Unknown format", "format=xml", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/?"+tt.query, nil)
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			got, err := importFormat(e.NewContext(req, httptest.NewRecorder()))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseColumnMap(t *testing.T) {
+	mapping, err := parseColumnMap("Full Name:name, E-mail : email")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Full Name": "name", "E-mail": "email"}, mapping)
+
+	_, err = parseColumnMap("name")
+	assert.Error(t, err)
+	_, err = parseColumnMap("name:")
+	assert.Error(t, err)
+}
+
+func TestCSVSource(t *testing.T) {
+	body := "\ufeffFull Name,age,meta\n" +
+		"Ada,36,\"{\"\"a\"\":1}\"\n" +
+		"Bob,,\n" +
+		"Eve,1\n" +
+		"\"Mal\"lory\",2,\n" +
+		"Zed,3,not json\n"
+
+	src, err := csvSource(strings.NewReader(body), map[string]string{"Full Name": "name"}, map[string]bool{"meta": true})
+	require.NoError(t, err)
+
+	rows, errs := drain(t, src)
+	assert.Equal(t, []map[string]any{
+		{"name": "Ada", "age": "36", "meta": map[string]any{"a": float64(1)}},
+		{"name": "Bob", "age": nil, "meta": nil},
+		{"name": "Zed", "age": "3", "meta": "not json"},
+	}, rows)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0], "expected 3 fields, got 2")
+}
+
+func TestNDJSONSource(t *testing.T) {
+	body := "{\"name\":\"Ada\"}\n\n[1,2]\n{\"name\":\"Bob\",\"age\":3}"
+
+	rows, errs := drain(t, ndjsonSource(strings.NewReader(body)))
+	assert.Equal(t, []map[string]any{
+		{"name": "Ada"},
+		{"name": "Bob", "age": float64(3)},
+	}, rows)
+	assert.Len(t, errs, 1)
+}
+
+func TestJSONArraySource(t *testing.T) {
+	src, err := jsonArraySource(strings.NewReader(`[{"name":"Ada"}, 42, {"name":"Bob"}]`))
+	require.NoError(t, err)
+
+	rows, errs := drain(t, src)
+	assert.Equal(t, []map[string]any{{"name": "Ada"}, {"name": "Bob"}}, rows)
+	assert.Len(t, errs, 1)
+
+	_, err = jsonArraySource(strings.NewReader(`{"name":"Ada"}`))
+	assert.Error(t, err)
+}
+
+func TestImporter(t *testing.T) {
+	schema := []data.FieldSchema{
+		{Name: "name", Type: "text", Required: true},
+		{Name: "age", Type: "int4"},
+	}
+	body := `[
+		{"name": "Ada", "age": 36, "created_at": "2024-01-01T00:00:00Z"},
+		{"age": 1},
+		{"name": "Bob"},
+		{"name": "Eve", "age": "old"},
+		{"name": "Mal", "age": 2, "id": "c0ffee00-0000-0000-0000-000000000000"}
+	]`
+
+	newImporter := func(skip bool) *importer {
+		src, err := jsonArraySource(strings.NewReader(body))
+		require.NoError(t, err)
+		return &importer{source: src, schema: schema, skipErrors: skip}
+	}
+
+	t.Run("Stops at first bad row", func(t *testing.T) {
+		im := newImporter(false)
+
+		row, err := im.next()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"age", "name"}, im.columns)
+		assert.Equal(t, []any{int64(36), "Ada"}, row)
+
+		_, err = im.next()
+		var re *rowError
+		require.ErrorAs(t, err, &re)
+		assert.Equal(t, 2, re.Row)
+		assert.Equal(t, []ValidationError{{Field: "name", Message: "is required"}}, re.Fields)
+	})
+
+	t.Run("Skips and reports bad rows", func(t *testing.T) {
+		im := newImporter(true)
+
+		var rows [][]any
+		for {
+			row, err := im.next()
+			require.NoError(t, err)
+			if row == nil {
+				break
+			}
+			rows = append(rows, row)
+		}
+
+		// Missing fields are written as NULL
+		assert.Equal(t, [][]any{{int64(36), "Ada"}, {nil, "Bob"}}, rows)
+		assert.Equal(t, 3, im.skipped)
+		require.Len(t, im.errors, 3)
+		assert.Equal(t, []int{2, 4, 5}, []int{im.errors[0].Row, im.errors[1].Row, im.errors[2].Row})
+		assert.Contains(t, im.errors[2].Message, `"id"`)
+	})
+}
+
+func TestImporterFieldAccess(t *testing.T) {
+	schema := []data.FieldSchema{
+		{Name: "name", Type: "text"},
+		{Name: "sku", Type: "text", FieldAccess: data.FieldAccess{Write: data.WriteOnCreate}},
+		{Name: "score", Type: "int4", FieldAccess: data.FieldAccess{Write: data.WriteNever}},
+	}
+	newImporter := func(upsert bool) *importer {
+		src, err := jsonArraySource(strings.NewReader(`[{"name": "Ada", "sku": "A-1", "score": 99}]`))
+		require.NoError(t, err)
+		return &importer{source: src, schema: schema, role: "user", upsert: upsert, unknownFields: data.UnknownFieldsStrip}
+	}
+
+	im := newImporter(false)
+	row, err := im.next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "sku"}, im.columns)
+	assert.Equal(t, []any{"Ada", "A-1"}, row)
+
+	im = newImporter(true)
+	row, err = im.next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, im.columns)
+	assert.Equal(t, []any{"Ada"}, row)
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"Nil", nil, ""},
+		{"String", "hello", "hello"},
+		{"Integer", int32(42), "42"},
+		{"Bool", true, "true"},
+		{"Timestamp", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), "2024-05-01T10:00:00Z"},
+		{"Bytes", []byte("hi"), "aGk="},
+		{"JSON", map[string]any{"a": 1.0}, `{"a":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := csvCell(tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	stream := &exportStream{res: c.Response(), contentType: "text/csv; charset=utf-8", filename: "people.csv"}
+	w := &csvRowWriter{exportStream: stream, w: csv.NewWriter(c.Response())}
+
+	require.NoError(t, w.WriteHeader([]string{"id", "name"}))
+	require.NoError(t, w.WriteRow([]any{"1", "Ada, Countess"}))
+	require.NoError(t, stream.flush())
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `attachment; filename="people.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "id,name\n1,\"Ada, Countess\"\n", rec.Body.String())
+}
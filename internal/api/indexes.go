@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// indexBuildTimeout bounds building an index over a large table
+const indexBuildTimeout = 10 * time.Minute
+
+// ListIndexes handles GET /api/tables/:name/indexes
+func (h *Handler) ListIndexes(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	indexes, err := h.DB.ListIndexes(ctx, c.Param("name"))
+	if err != nil {
+		if errors.Is(err, data.ErrCollectionNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if indexes == nil {
+		indexes = []data.IndexInfo{}
+	}
+	return c.JSON(http.StatusOK, indexes)
+}
+
+// CreateIndex handles POST /api/tables/:name/indexes
+//
+// It creates a plain, unique, composite, partial or expression index. With
+// concurrently set the build doesn't block writes; the migration recorded for
+// it builds the index normally, as migrations run in a transaction.
+func (h *Handler) CreateIndex(c echo.Context) error {
+	tableName := c.Param("name")
+	var spec data.IndexSpec
+	if err := c.Bind(&spec); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid body"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), indexBuildTimeout)
+	defer cancel()
+
+	name, sql, err := h.DB.CreateIndex(ctx, tableName, spec)
+	if err != nil {
+		if errors.Is(err, data.ErrIndexExists) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to create index")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("create_index_%s_on_%s", name, tableName)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]string{"index": name})
+}
+
+// DropIndex handles DELETE /api/tables/:name/indexes/:index
+//
+// Pass ?concurrently=true to drop without blocking reads and writes.
+func (h *Handler) DropIndex(c echo.Context) error {
+	tableName, indexName := c.Param("name"), c.Param("index")
+	concurrently := c.QueryParam("concurrently") == "true"
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), indexBuildTimeout)
+	defer cancel()
+
+	sql, err := h.DB.DropIndex(ctx, tableName, indexName, concurrently)
+	if err != nil {
+		if errors.Is(err, data.ErrIndexNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return writeError(c, err, "Failed to drop index")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("drop_index_%s_from_%s", indexName, tableName)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/Xangel0s/OzyBase/internal/data"
 	"github.com/Xangel0s/OzyBase/internal/realtime"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -111,53 +112,139 @@ func AccessMiddleware(db *data.DB, requirement string) echo.MiddlewareFunc {
 				return next(c) // Public routes or collections management
 			}
 
-			var listRule, createRule, updateRule, deleteRule, rlsRule string
-			var rlsEnabled bool
-			err := db.Pool.QueryRow(c.Request().Context(),
-				"SELECT list_rule, create_rule, update_rule, delete_rule, rls_enabled, rls_rule FROM _v_collections WHERE name = $1",
-				collectionName).Scan(&listRule, &createRule, &updateRule, &deleteRule, &rlsEnabled, &rlsRule)
-
+			rules, err := loadCollectionRules(c.Request().Context(), db.Pool, collectionName)
 			if err != nil {
 				return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
 			}
 
 			// Store RLS config for later use in handlers
-			c.Set("rls_enabled", rlsEnabled)
-			c.Set("rls_rule", rlsRule)
+			c.Set("rls_enabled", rules.RlsEnabled)
+			c.Set("rls_rule", rules.RlsRule)
 
-			rule := listRule
-			if requirement == "create" {
-				rule = createRule
+			if denied := rules.check(c, requirement); denied != "" {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": denied})
+			}
+			if requirement != "list" && rules.ReadOnly {
+				return c.JSON(http.StatusMethodNotAllowed, map[string]string{"error": errReadOnlyCollection})
 			}
+			return next(c)
+		}
+	}
+}
 
-			// ACL Logic
-			switch rule {
-			case "public":
-				return next(c)
-			case "auth":
-				if c.Get("user_id") == nil {
-					return c.JSON(http.StatusForbidden, map[string]string{"error": "authentication required for this collection"})
-				}
-				return next(c)
-			case "admin":
-				role := c.Get("role")
-				if role == nil || role.(string) != "admin" {
-					return c.JSON(http.StatusForbidden, map[string]string{"error": "admin access required for this collection"})
-				}
-				return next(c)
-			default:
-				// Support custom roles like 'role:manager'
-				if strings.HasPrefix(rule, "role:") {
-					requiredRole := strings.TrimPrefix(rule, "role:")
-					userRole := c.Get("role")
-					if userRole == nil || userRole.(string) != requiredRole {
-						return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s role required for this collection", requiredRole)})
-					}
-					return next(c)
-				}
-				return c.JSON(http.StatusForbidden, map[string]string{"error": "access denied"})
+// WritableMiddleware rejects writes to view collections on routes that don't
+// go through AccessMiddleware, such as the dashboard table endpoints
+func WritableMiddleware(db *data.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if data.IsViewKind(db.CollectionKind(c.Request().Context(), c.Param("name"))) {
+				return c.JSON(http.StatusMethodNotAllowed, map[string]string{"error": errReadOnlyCollection})
+			}
+			return next(c)
+		}
+	}
+}
+
+// errReadOnlyCollection is returned for writes to collections backed by a view
+const errReadOnlyCollection = "collection is read-only"
+
+// accessRules are the ACL rules guarding each kind of request
+type accessRules struct {
+	List, Create, Update, Delete string
+}
+
+// collectionRules are the ACL and RLS settings of a collection
+type collectionRules struct {
+	accessRules
+	RlsEnabled bool
+	RlsRule    string
+	ReadOnly   bool        // view collections only serve reads
+	Schema     accessRules // rules of the collection's Postgres schema, empty where unset
+}
+
+// rowQuerier runs single-row queries on the pool or in a request's transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// loadCollectionRules reads the rules of a collection along with those of its
+// schema. The schema's RLS rule applies when the collection has none enabled.
+func loadCollectionRules(ctx context.Context, q rowQuerier, collectionName string) (collectionRules, error) {
+	var r collectionRules
+	ref, err := data.ParseTableRef(collectionName)
+	if err != nil {
+		return r, err
+	}
+
+	var schemaRls bool
+	var schemaRlsRule string
+	err = q.QueryRow(ctx, `
+		SELECT c.list_rule, c.create_rule, c.update_rule, c.delete_rule, c.rls_enabled, c.rls_rule, COALESCE(c.kind, 'table') <> 'table',
+			COALESCE(s.list_rule, ''), COALESCE(s.create_rule, ''), COALESCE(s.update_rule, ''), COALESCE(s.delete_rule, ''),
+			COALESCE(s.rls_enabled, FALSE), COALESCE(s.rls_rule, '')
+		FROM _v_collections c
+		LEFT JOIN _v_schemas s ON s.name = $2
+		WHERE c.name = $1
+	`, collectionName, ref.Schema).Scan(&r.List, &r.Create, &r.Update, &r.Delete, &r.RlsEnabled, &r.RlsRule, &r.ReadOnly,
+		&r.Schema.List, &r.Schema.Create, &r.Schema.Update, &r.Schema.Delete, &schemaRls, &schemaRlsRule)
+	if err == nil && !r.RlsEnabled && schemaRls {
+		r.RlsEnabled, r.RlsRule = true, schemaRlsRule
+	}
+	return r, err
+}
+
+// check evaluates the schema rule and then the collection rule guarding
+// requirement. It returns an empty string when both grant access.
+func (r collectionRules) check(c echo.Context, requirement string) string {
+	if rule := r.Schema.forRequirement(requirement); rule != "" {
+		if denied := checkAccessRule(c, rule); denied != "" {
+			return denied
+		}
+	}
+	return checkAccessRule(c, r.forRequirement(requirement))
+}
+
+// forRequirement picks the rule guarding "list", "create", "update" or "delete"
+func (r accessRules) forRequirement(requirement string) string {
+	switch requirement {
+	case "create":
+		return r.Create
+	case "update":
+		return r.Update
+	case "delete":
+		return r.Delete
+	}
+	return r.List
+}
+
+// checkAccessRule evaluates a collection ACL rule ("public", "auth", "admin" or "role:<name>")
+// for the current request. It returns an empty string when access is granted, otherwise the reason.
+func checkAccessRule(c echo.Context, rule string) string {
+	switch rule {
+	case "public":
+		return ""
+	case "auth":
+		if c.Get("user_id") == nil {
+			return "authentication required for this collection"
+		}
+		return ""
+	case "admin":
+		role, _ := c.Get("role").(string)
+		if role != "admin" {
+			return "admin access required for this collection"
+		}
+		return ""
+	default:
+		// Support custom roles like 'role:manager'
+		if strings.HasPrefix(rule, "role:") {
+			requiredRole := strings.TrimPrefix(rule, "role:")
+			userRole, _ := c.Get("role").(string)
+			if userRole != requiredRole {
+				return fmt.Sprintf("%s role required for this collection", requiredRole)
 			}
+			return ""
 		}
+		return "access denied"
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Xangel0s/OzyBase/internal/data"
 	"github.com/Xangel0s/OzyBase/internal/realtime"
 	"github.com/labstack/echo/v4"
 )
@@ -11,11 +12,12 @@ import (
 // RealtimeHandler handles the SSE connection
 type RealtimeHandler struct {
 	Broker *realtime.Broker
+	DB     *data.DB // field access rules applied to event payloads
 }
 
 // NewRealtimeHandler creates a new instances of RealtimeHandler
-func NewRealtimeHandler(broker *realtime.Broker) *RealtimeHandler {
-	return &RealtimeHandler{Broker: broker}
+func NewRealtimeHandler(broker *realtime.Broker, db *data.DB) *RealtimeHandler {
+	return &RealtimeHandler{Broker: broker, DB: db}
 }
 
 // Stream handles GET /api/realtime
@@ -35,11 +37,12 @@ func (h *RealtimeHandler) Stream(c echo.Context) error {
 	w.Flush()
 
 	ctx := c.Request().Context()
+	fields := newHiddenFieldCache(h.DB, h.Broker, callerRole(c))
 
 	for {
 		select {
 		case event := <-eventChan:
-			msg, err := json.Marshal(event)
+			msg, err := json.Marshal(filterEvent(ctx, fields, event))
 			if err != nil {
 				continue
 			}
@@ -0,0 +1,384 @@
+package api
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// systemColumns are managed by OzyBase and never validated against the schema
+var systemColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+}
+
+// ValidateRecord checks a record payload against a collection schema and returns
+// a copy with values coerced to the Go types pgx expects for each column.
+// With partial set (updates), missing required fields are not reported.
+func ValidateRecord(schema []data.FieldSchema, body map[string]any, partial bool, unknownFields string) (map[string]any, []ValidationError) {
+	var errs []ValidationError
+	out := make(map[string]any, len(body))
+
+	fields := make(map[string]data.FieldSchema, len(schema))
+	for _, f := range schema {
+		fields[f.Name] = f
+	}
+
+	for key, val := range body {
+		if systemColumns[key] {
+			out[key] = val
+			continue
+		}
+
+		field, ok := fields[key]
+		if !ok {
+			if unknownFields == data.UnknownFieldsReject {
+				errs = append(errs, ValidationError{Field: key, Message: "unknown field"})
+			}
+			continue
+		}
+
+		if val == nil {
+			if field.Required {
+				errs = append(errs, ValidationError{Field: key, Message: "is required"})
+				continue
+			}
+			out[key] = nil
+			continue
+		}
+
+		coerced, err := coerceField(field, val)
+		if err == nil {
+			err = field.CheckValue(coerced)
+		}
+		if err != nil {
+			errs = append(errs, ValidationError{Field: key, Message: err.Error()})
+			continue
+		}
+		out[key] = coerced
+	}
+
+	if !partial {
+		for _, f := range schema {
+			if _, present := body[f.Name]; !present && f.Required && f.Default == nil {
+				errs = append(errs, ValidationError{Field: f.Name, Message: "is required"})
+			}
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return out, errs
+}
+
+// coerceField converts a decoded JSON value to the Go type for a field, mapping
+// the higher-level field types onto the scalar types they're stored as
+func coerceField(field data.FieldSchema, val any) (any, error) {
+	switch strings.ToLower(field.Type) {
+	case data.FieldRelation, data.FieldFile, data.FieldFiles:
+		// Relations hold ids of their target's type, files always UUIDs
+		idType := "uuid"
+		if strings.ToLower(field.Type) == data.FieldRelation {
+			idType = cmp.Or(field.IDType, idType)
+		}
+		if field.IsLinkField() {
+			return coerceList(idType, val)
+		}
+		return coerceValue(idType, val)
+	case data.FieldSelect:
+		return coerceValue("text", val)
+	case data.FieldArray:
+		return coerceList(field.Items, val)
+	case data.FieldVector:
+		return coerceVector(field, val)
+	}
+	return coerceValue(field.Type, val)
+}
+
+// coerceVector checks the size of a vector and encodes it for its storage:
+// pgvector's text form, or a float slice for FLOAT4[]
+func coerceVector(field data.FieldSchema, val any) (any, error) {
+	items, ok := val.([]any)
+	if !ok {
+		return nil, errors.New("must be an array of numbers")
+	}
+	if len(items) != field.Dimensions {
+		return nil, fmt.Errorf("must have %d dimensions", field.Dimensions)
+	}
+	vector := make([]float64, len(items))
+	for i, item := range items {
+		f, ok := item.(float64)
+		if !ok {
+			return nil, errors.New("must be an array of numbers")
+		}
+		vector[i] = f
+	}
+	if field.VectorStorage == data.VectorStoragePGVector {
+		return data.FormatVector(vector), nil
+	}
+	return vector, nil
+}
+
+// coerceList coerces every item of a JSON array to itemType
+func coerceList(itemType string, val any) (any, error) {
+	items, ok := val.([]any)
+	if !ok {
+		return nil, errors.New("must be an array")
+	}
+	out := make([]any, len(items))
+	for i, item := range items {
+		coerced, err := coerceValue(itemType, item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d %s", i, err.Error())
+		}
+		out[i] = coerced
+	}
+	return out, nil
+}
+
+// coerceValue converts a decoded JSON value to the Go type for an OzyBase field type
+func coerceValue(fieldType string, val any) (any, error) {
+	switch strings.ToLower(fieldType) {
+	case "int2":
+		return coerceInt(val, math.MinInt16, math.MaxInt16)
+	case "int4", "integer", "number":
+		return coerceInt(val, math.MinInt32, math.MaxInt32)
+	case "int8":
+		return coerceInt(val, math.MinInt64, math.MaxInt64)
+	case "float4", "float8":
+		return coerceFloat(val)
+	case "numeric":
+		// Keep numerics as text so Postgres parses them without float rounding
+		switch v := val.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				return nil, errors.New("must be a number")
+			}
+			return strings.TrimSpace(v), nil
+		}
+		return nil, errors.New("must be a number")
+	case "bool", "boolean":
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.New("must be a boolean")
+	case "uuid":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errors.New("must be a UUID string")
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, errors.New("must be a valid UUID")
+		}
+		return id.String(), nil
+	case "timestamptz", "timestamp":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errors.New("must be an RFC 3339 timestamp")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, errors.New("must be an RFC 3339 timestamp")
+		}
+		return t, nil
+	case "date":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errors.New("must be a date (YYYY-MM-DD)")
+		}
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, errors.New("must be a date (YYYY-MM-DD)")
+		}
+		return s, nil
+	case "text", "varchar", "string", "time", "timetz":
+		switch v := val.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+		return nil, errors.New("must be a string")
+	case "json", "jsonb":
+		// Send JSON as text so strings and numbers aren't mistaken for raw SQL values
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil, errors.New("must be valid JSON")
+		}
+		return string(b), nil
+	case "bytea":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errors.New("must be a base64 string")
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, errors.New("must be a base64 string")
+		}
+		return b, nil
+	}
+
+	// Unknown types are passed through and left to Postgres
+	return val, nil
+}
+
+func coerceInt(val any, min, max int64) (any, error) {
+	var n int64
+	switch v := val.(type) {
+	case float64:
+		if v != math.Trunc(v) || v < float64(min) || v > float64(max) {
+			return nil, errors.New("must be an integer in range")
+		}
+		n = int64(v)
+	case string:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		n = parsed
+	default:
+		return nil, errors.New("must be an integer")
+	}
+
+	if n < min || n > max {
+		return nil, errors.New("must be an integer in range")
+	}
+	return n, nil
+}
+
+func coerceFloat(val any) (any, error) {
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err == nil {
+			return f, nil
+		}
+	}
+	return nil, errors.New("must be a number")
+}
+
+// validatePayload applies the collection schema and field access rules to a
+// request body for op ("create", "update" or "upsert"). Collections without a
+// stored schema (unmanaged tables) are passed through unchanged. Failing to
+// load the schema is an error, so the rules are never skipped by accident.
+func (h *Handler) validatePayload(c echo.Context, collectionName string, body map[string]any, op string) (map[string]any, []ValidationError, error) {
+	meta, err := h.DB.GetCollectionMeta(c.Request().Context(), collectionName)
+	if errors.Is(err, data.ErrCollectionNotFound) {
+		return body, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(meta.Schema) == 0 {
+		return body, nil, nil
+	}
+	body, errs := ApplyFieldAccess(meta.Schema, body, callerRole(c), op, meta.UnknownFields)
+	if len(errs) > 0 {
+		return body, errs, nil
+	}
+	body, errs = ValidateRecord(meta.Schema, body, op == "update", meta.UnknownFields)
+	return body, errs, nil
+}
+
+// validationFailed writes the structured 400 response for field errors
+func validationFailed(c echo.Context, errs []ValidationError) error {
+	return c.JSON(http.StatusBadRequest, validationBody(errs))
+}
+
+func validationBody(errs []ValidationError) map[string]any {
+	return map[string]any{
+		"error":  "validation failed",
+		"fields": errs,
+	}
+}
+
+// writeRecordError extends writeError with the single-record outcomes:
+// 404 for a missing record, 412 for a failed If-Match precondition and 400
+// for history reads on a collection that doesn't record history
+func writeRecordError(c echo.Context, err error, fallback string) error {
+	status, body := recordErrorResponse(err, fallback)
+	return c.JSON(status, body)
+}
+
+// writeError maps database errors to client responses without leaking raw SQL errors.
+// Constraint and type errors become per-field validation errors.
+func writeError(c echo.Context, err error, fallback string) error {
+	status, body := errorResponse(err, fallback)
+	return c.JSON(status, body)
+}
+
+func recordErrorResponse(err error, fallback string) (int, map[string]any) {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return http.StatusNotFound, map[string]any{"error": err.Error()}
+	case errors.Is(err, data.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, map[string]any{"error": err.Error()}
+	case errors.Is(err, data.ErrHistoryDisabled):
+		return http.StatusBadRequest, map[string]any{"error": err.Error()}
+	}
+	return errorResponse(err, fallback)
+}
+
+func errorResponse(err error, fallback string) (int, map[string]any) {
+	if errors.Is(err, data.ErrInvalidQuery) {
+		return http.StatusBadRequest, map[string]any{"error": err.Error()}
+	}
+	if errors.Is(err, data.ErrEmbedDenied) {
+		return http.StatusForbidden, map[string]any{"error": err.Error()}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		field := pgErr.ColumnName
+		if field == "" {
+			field = data.ConstraintField(pgErr.TableName, pgErr.ConstraintName)
+		}
+		switch pgErr.Code {
+		case "23502": // not_null_violation
+			return http.StatusBadRequest, validationBody([]ValidationError{{Field: field, Message: "is required"}})
+		case "23505": // unique_violation
+			return http.StatusConflict, map[string]any{
+				"error":  "duplicate value",
+				"fields": []ValidationError{{Field: field, Message: "must be unique"}},
+			}
+		case "23503": // foreign_key_violation
+			return http.StatusBadRequest, validationBody([]ValidationError{{Field: field, Message: "references a missing record"}})
+		case "23514": // check_violation
+			if field != "" {
+				return http.StatusBadRequest, validationBody([]ValidationError{{Field: field, Message: "does not satisfy the field validators"}})
+			}
+			return http.StatusBadRequest, validationBody([]ValidationError{{Message: "violates constraint " + pgErr.ConstraintName}})
+		case "22P02", "22003", "22007", "22008": // invalid text representation, out of range, bad datetime
+			return http.StatusBadRequest, validationBody([]ValidationError{{Field: field, Message: "has an invalid value"}})
+		case "25006": // read_only_sql_transaction, raised by writes to view collections
+			return http.StatusMethodNotAllowed, map[string]any{"error": errReadOnlyCollection}
+		}
+	}
+
+	log.Printf("⚠️ %s: %v", fallback, err)
+	return http.StatusInternalServerError, map[string]any{"error": fallback}
+}
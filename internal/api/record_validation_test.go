@@ -0,0 +1,189 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = []data.FieldSchema{
+	{Name: "title", Type: "text", Required: true},
+	{Name: "views", Type: "int4"},
+	{Name: "price", Type: "numeric"},
+	{Name: "published", Type: "bool"},
+	{Name: "author_id", Type: "uuid"},
+	{Name: "published_at", Type: "timestamptz"},
+	{Name: "meta", Type: "jsonb"},
+	{Name: "status", Type: "text", Required: true, Default: "draft"},
+}
+
+func TestValidateRecord(t *testing.T) {
+	t.Run("Coerces values", func(t *testing.T) {
+		out, errs := ValidateRecord(testSchema, map[string]any{
+			"id":           "abc",
+			"title":        42.0,
+			"views":        "7",
+			"price":        19.99,
+			"published":    "true",
+			"author_id":    "6F9619FF-8B86-D011-B42D-00CF4FC964FF",
+			"published_at": "2024-05-01T10:00:00Z",
+			"meta":         map[string]any{"tags": []any{"go"}},
+		}, false, data.UnknownFieldsStrip)
+
+		assert.Empty(t, errs)
+		assert.Equal(t, "abc", out["id"])
+		assert.Equal(t, "42", out["title"])
+		assert.Equal(t, int64(7), out["views"])
+		assert.Equal(t, "19.99", out["price"])
+		assert.Equal(t, true, out["published"])
+		assert.Equal(t, "6f9619ff-8b86-d011-b42d-00cf4fc964ff", out["author_id"])
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), out["published_at"])
+		assert.Equal(t, `{"tags":["go"]}`, out["meta"])
+	})
+
+	t.Run("Reports field errors", func(t *testing.T) {
+		_, errs := ValidateRecord(testSchema, map[string]any{
+			"views":     1.5,
+			"published": "maybe",
+			"author_id": "not-a-uuid",
+		}, false, data.UnknownFieldsStrip)
+
+		assert.Equal(t, []ValidationError{
+			{Field: "author_id", Message: "must be a valid UUID"},
+			{Field: "published", Message: "must be a boolean"},
+			{Field: "title", Message: "is required"},
+			{Field: "views", Message: "must be an integer in range"},
+		}, errs)
+	})
+
+	t.Run("Partial updates skip missing required fields", func(t *testing.T) {
+		_, errs := ValidateRecord(testSchema, map[string]any{"views": 3.0}, true, data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+
+		_, errs = ValidateRecord(testSchema, map[string]any{"title": nil}, true, data.UnknownFieldsStrip)
+		assert.Equal(t, []ValidationError{{Field: "title", Message: "is required"}}, errs)
+	})
+
+	t.Run("Applies field validators", func(t *testing.T) {
+		minPrice := 0.0
+		schema := []data.FieldSchema{
+			{Name: "price", Type: "numeric", Min: &minPrice},
+			{Name: "status", Type: "text", Enum: []string{"draft", "published"}},
+		}
+		_, errs := ValidateRecord(schema, map[string]any{"price": -1.0, "status": "gone"}, false, data.UnknownFieldsStrip)
+		assert.Equal(t, []ValidationError{
+			{Field: "price", Message: "must be at least 0"},
+			{Field: "status", Message: "must be one of draft, published"},
+		}, errs)
+	})
+
+	t.Run("Coerces higher-level field types", func(t *testing.T) {
+		schema := []data.FieldSchema{
+			{Name: "author", Type: "relation", Target: "users"},
+			{Name: "tags", Type: "relation", Target: "tags", Multiple: true},
+			{Name: "status", Type: "select", Options: []string{"draft", "published"}},
+			{Name: "scores", Type: "array", Items: "int4"},
+			{Name: "address", Type: "jsonb", JSONSchema: []byte(`{"type": "object", "required": ["city"]}`)},
+		}
+		out, errs := ValidateRecord(schema, map[string]any{
+			"author":  "6F9619FF-8B86-D011-B42D-00CF4FC964FF",
+			"tags":    []any{"6F9619FF-8B86-D011-B42D-00CF4FC964FF"},
+			"status":  "draft",
+			"scores":  []any{1.0, "2"},
+			"address": map[string]any{"city": "Lima"},
+		}, false, data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+		assert.Equal(t, "6f9619ff-8b86-d011-b42d-00cf4fc964ff", out["author"])
+		assert.Equal(t, []any{"6f9619ff-8b86-d011-b42d-00cf4fc964ff"}, out["tags"])
+		assert.Equal(t, []any{int64(1), int64(2)}, out["scores"])
+
+		_, errs = ValidateRecord(schema, map[string]any{
+			"tags":    "6F9619FF-8B86-D011-B42D-00CF4FC964FF",
+			"status":  "gone",
+			"scores":  []any{1.5},
+			"address": map[string]any{},
+		}, false, data.UnknownFieldsStrip)
+		assert.Equal(t, []ValidationError{
+			{Field: "address", Message: "/city is required"},
+			{Field: "scores", Message: "item 0 must be an integer in range"},
+			{Field: "status", Message: "must be one of draft, published"},
+			{Field: "tags", Message: "must be an array"},
+		}, errs)
+	})
+
+	t.Run("Relations take the id type of their target", func(t *testing.T) {
+		schema := []data.FieldSchema{
+			{Name: "event", Type: "relation", Target: "events", IDType: "int8"},
+			{Name: "labels", Type: "relation", Target: "labels", Multiple: true, IDType: "text"},
+		}
+		out, errs := ValidateRecord(schema, map[string]any{
+			"event":  42.0,
+			"labels": []any{"01J9Z3R4X7K2M8N5P6Q7R8S9T0"},
+		}, false, data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(42), out["event"])
+		assert.Equal(t, []any{"01J9Z3R4X7K2M8N5P6Q7R8S9T0"}, out["labels"])
+
+		_, errs = ValidateRecord(schema, map[string]any{"event": "6f9619ff-8b86-d011-b42d-00cf4fc964ff"}, false, data.UnknownFieldsStrip)
+		assert.Equal(t, []ValidationError{{Field: "event", Message: "must be an integer"}}, errs)
+	})
+
+	t.Run("Unknown fields policy", func(t *testing.T) {
+		out, errs := ValidateRecord(testSchema, map[string]any{"title": "a", "extra": 1.0}, false, data.UnknownFieldsStrip)
+		assert.Empty(t, errs)
+		assert.NotContains(t, out, "extra")
+
+		_, errs = ValidateRecord(testSchema, map[string]any{"title": "a", "extra": 1.0}, false, data.UnknownFieldsReject)
+		assert.Equal(t, []ValidationError{{Field: "extra", Message: "unknown field"}}, errs)
+	})
+}
+
+func TestCoerceValueRanges(t *testing.T) {
+	_, err := coerceValue("int2", 40000.0)
+	assert.Error(t, err)
+
+	v, err := coerceValue("int8", "9007199254740993")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), v)
+
+	_, err = coerceValue("numeric", "12abc")
+	assert.Error(t, err)
+
+	_, err = coerceValue("text", map[string]any{})
+	assert.Error(t, err)
+}
+
+func TestWriteError(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"Not null", &pgconn.PgError{Code: "23502", ColumnName: "title"}, http.StatusBadRequest},
+		{"Unique", &pgconn.PgError{Code: "23505", TableName: "posts", ConstraintName: "posts_slug_key"}, http.StatusConflict},
+		{"Check", &pgconn.PgError{Code: "23514", TableName: "posts", ConstraintName: "posts_views_check"}, http.StatusBadRequest},
+		{"Invalid text", &pgconn.PgError{Code: "22P02"}, http.StatusBadRequest},
+		{"Read-only view", &pgconn.PgError{Code: "25006"}, http.StatusMethodNotAllowed},
+		{"Invalid query", data.ErrInvalidQuery, http.StatusBadRequest},
+		{"Other", &pgconn.PgError{Code: "42P01", Message: "relation does not exist"}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+			assert.NoError(t, writeError(c, tt.err, "Failed"))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "relation does not exist")
+		})
+	}
+}
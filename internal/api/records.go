@@ -3,21 +3,32 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
 func (h *Handler) extractRlsOwnerInfo(c echo.Context) (string, string) {
 	rlsEnabled, _ := c.Get("rls_enabled").(bool)
+	rlsRule, _ := c.Get("rls_rule").(string)
+	return rlsOwner(c, rlsEnabled, rlsRule)
+}
+
+// rlsOwner returns the owner column of an "owner = auth.uid()" RLS rule along
+// with the caller's id, or empty strings when records aren't owned
+func rlsOwner(c echo.Context, rlsEnabled bool, rlsRule string) (string, string) {
 	if !rlsEnabled {
 		return "", ""
 	}
 
-	rlsRule, _ := c.Get("rls_rule").(string)
 	userID, _ := c.Get("user_id").(string)
 
 	if rlsRule != "" && userID != "" && strings.Contains(rlsRule, "auth.uid()") {
@@ -53,20 +64,49 @@ func (h *Handler) CreateRecord(c echo.Context) error {
 		})
 	}
 
+	// Upsert when asked via ?on_conflict= or Prefer: resolution=...
+	resolution := preferences(c)["resolution"]
+	onConflict := c.QueryParam("on_conflict")
+	upsert := onConflict != "" || resolution == "merge-duplicates" || resolution == "ignore-duplicates"
+
+	op := "create"
+	if upsert {
+		op = "upsert"
+	}
+	// A merge may overwrite an existing row, so it needs update rights as well
+	if upsert && resolution != "ignore-duplicates" {
+		rules, err := loadCollectionRules(c.Request().Context(), h.DB.Pool, collectionName)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+		}
+		if denied := rules.check(c, "update"); denied != "" {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": denied})
+		}
+	}
+	data, fieldErrs, err := h.validatePayload(c, collectionName, data, op)
+	if err != nil {
+		return writeError(c, err, "Failed to create record")
+	}
+	if len(fieldErrs) > 0 {
+		return validationFailed(c, fieldErrs)
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
+	if upsert {
+		return h.upsertRecord(ctx, c, collectionName, data, onConflict, resolution == "ignore-duplicates")
+	}
+
 	// Insert the record
 	id, err := h.DB.InsertRecord(ctx, collectionName, data)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return writeError(c, err, "Failed to create record")
 	}
 
 	// Fetch the complete record to return
 	ownerField, ownerID := h.extractRlsOwnerInfo(c)
-	record, err := h.DB.GetRecord(ctx, collectionName, id, ownerField, ownerID)
+	record, err := h.DB.GetRecord(ctx, collectionName, id, ownerField, ownerID, h.selection(c))
 	if err != nil {
 		// Return at least the ID if fetch fails
 		return c.JSON(http.StatusCreated, map[string]string{
@@ -77,7 +117,59 @@ func (h *Handler) CreateRecord(c echo.Context) error {
 	return c.JSON(http.StatusCreated, record)
 }
 
+// upsertRecord resolves a create request with ON CONFLICT semantics. It answers
+// 201 when a row was inserted, 200 when an existing row was merged and 204 when
+// a duplicate was ignored. Merging into a trashed row or one the caller doesn't
+// own answers 404.
+func (h *Handler) upsertRecord(ctx context.Context, c echo.Context, collectionName string, body map[string]any, onConflict string, ignoreDuplicates bool) error {
+	var conflictCols []string
+	if onConflict != "" {
+		for _, col := range strings.Split(onConflict, ",") {
+			conflictCols = append(conflictCols, strings.TrimSpace(col))
+		}
+	}
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+	res, err := h.DB.UpsertRecord(ctx, collectionName, body, conflictCols, ignoreDuplicates, ownerField, ownerID, callerRole(c))
+	if err != nil {
+		return writeRecordError(c, err, "Failed to upsert record")
+	}
+
+	if res.Ignored {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	status := http.StatusOK
+	if res.Inserted {
+		status = http.StatusCreated
+	}
+
+	record, err := h.DB.GetRecord(ctx, collectionName, res.ID, ownerField, ownerID, h.selection(c))
+	if err != nil {
+		return c.JSON(status, map[string]string{"id": res.ID})
+	}
+	return c.JSON(status, record)
+}
+
+// preferences parses the Prefer request header ("resolution=merge-duplicates, return=minimal")
+func preferences(c echo.Context) map[string]string {
+	prefs := make(map[string]string)
+	for _, header := range c.Request().Header.Values("Prefer") {
+		for _, token := range strings.Split(header, ",") {
+			key, val, _ := strings.Cut(strings.TrimSpace(token), "=")
+			if key != "" {
+				prefs[strings.ToLower(key)] = strings.TrimSpace(val)
+			}
+		}
+	}
+	return prefs
+}
+
 // ListRecords handles GET /api/collections/:name/records
+//
+// Pagination: ?limit=&offset= or ?cursor= (keyset over the order column plus id).
+// ?count=exact|estimated reports the total in a Content-Range header, and
+// ?envelope=true wraps the page in an object instead of returning a bare array.
 func (h *Handler) ListRecords(c echo.Context) error {
 	collectionName := c.Param("name")
 	if collectionName == "" {
@@ -86,22 +178,31 @@ func (h *Handler) ListRecords(c echo.Context) error {
 		})
 	}
 
-	orderBy := c.QueryParam("order")
-
-	// Collect all query parameters as filters
-	filters := c.QueryParams()
-
-	// Inject RLS filter if enabled
-	ownerField, ownerID := h.extractRlsOwnerInfo(c)
-	if ownerField != "" && ownerID != "" {
-		filters[ownerField] = append(filters[ownerField], "eq."+ownerID)
+	opts, err := h.parseListOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
 	}
 
+	// Restrict rows to the caller's own when RLS is enabled
+	opts.OwnerField, opts.OwnerID = h.extractRlsOwnerInfo(c)
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
-	records, err := h.DB.ListRecords(ctx, collectionName, filters, orderBy)
+	result, err := h.DB.ListRecords(ctx, collectionName, opts)
 	if err != nil {
+		if errors.Is(err, data.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, data.ErrEmbedDenied) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		// SECURITY: Don't leak SQL errors to client
 		fmt.Printf("[ERROR] ListRecords: %v\n", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -109,14 +210,125 @@ func (h *Handler) ListRecords(c echo.Context) error {
 		})
 	}
 
+	records := result.Records
 	if records == nil {
 		records = []map[string]any{}
 	}
 
-	return c.JSON(http.StatusOK, records)
+	// Keyset pages don't know how many rows precede them
+	start := opts.Offset
+	if opts.Cursor != "" {
+		start = -1
+	}
+	c.Response().Header().Set("Content-Range", contentRange(start, len(records), result.Total))
+	if result.NextCursor != "" {
+		c.Response().Header().Set("X-Next-Cursor", result.NextCursor)
+	}
+
+	if c.QueryParam("envelope") == "true" {
+		page := map[string]any{
+			"items":       records,
+			"limit":       opts.Limit,
+			"offset":      opts.Offset,
+			"next_cursor": nil,
+			"total":       nil,
+		}
+		if result.NextCursor != "" {
+			page["next_cursor"] = result.NextCursor
+		}
+		if result.Total >= 0 {
+			page["total"] = result.Total
+		}
+		return jsonWithETag(c, http.StatusOK, page)
+	}
+
+	return jsonWithETag(c, http.StatusOK, records)
+}
+
+// parseListOptions reads pagination and count params, clamping limit to the server maximum
+func (h *Handler) parseListOptions(c echo.Context) (data.ListOptions, error) {
+	opts := data.ListOptions{
+		Filters: c.QueryParams(),
+		OrderBy: c.QueryParam("order"),
+		Limit:   h.MaxPageSize,
+		Cursor:  c.QueryParam("cursor"),
+		Count:   c.QueryParam("count"),
+		Trashed: c.QueryParam("trashed"),
+		Select:  h.selection(c),
+	}
+
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("limit must be a positive integer")
+		}
+		if h.MaxPageSize > 0 && limit > h.MaxPageSize {
+			limit = h.MaxPageSize
+		}
+		opts.Limit = limit
+	}
+
+	if v := c.QueryParam("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return opts, fmt.Errorf("offset must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	if opts.Cursor != "" && opts.Offset > 0 {
+		return opts, fmt.Errorf("cursor and offset cannot be combined")
+	}
+
+	switch opts.Count {
+	case data.CountNone, data.CountExact, data.CountEstimated:
+	default:
+		return opts, fmt.Errorf("count must be 'exact' or 'estimated'")
+	}
+
+	switch opts.Trashed {
+	case data.TrashedExclude, data.TrashedOnly, data.TrashedWith:
+	default:
+		return opts, fmt.Errorf("trashed must be 'only' or 'with'")
+	}
+
+	return opts, nil
+}
+
+// selection builds the projection for ?select=, vetting embedded collections
+// against their own list rules so relations can't bypass collection ACLs. The
+// rules are read in the transaction of the read so its RLS context applies.
+func (h *Handler) selection(c echo.Context) data.Selection {
+	return data.Selection{
+		Expr: c.QueryParam("select"),
+		Role: callerRole(c),
+		CanEmbed: func(ctx context.Context, tx pgx.Tx, table string) bool {
+			rules, err := loadCollectionRules(ctx, tx, table)
+			if err != nil {
+				// Unmanaged tables are only reachable by admins
+				role, _ := c.Get("role").(string)
+				return role == "admin"
+			}
+			return rules.check(c, "list") == ""
+		},
+	}
+}
+
+// contentRange formats a PostgREST-style Content-Range value, e.g. "0-24/3573" or "0-24/*".
+// A negative offset means the page's position is unknown, as for cursor pages.
+func contentRange(offset, n int, total int64) string {
+	totalStr := "*"
+	if total >= 0 {
+		totalStr = strconv.FormatInt(total, 10)
+	}
+	if n == 0 || offset < 0 {
+		return "*/" + totalStr
+	}
+	return fmt.Sprintf("%d-%d/%s", offset, offset+n-1, totalStr)
 }
 
 // GetRecord handles GET /api/collections/:name/records/:id
+// ?as_of=<RFC 3339 timestamp> reads the record as it was at that time from its history.
 func (h *Handler) GetRecord(c echo.Context) error {
 	collectionName := c.Param("name")
 	recordID := c.Param("id")
@@ -131,13 +343,30 @@ func (h *Handler) GetRecord(c echo.Context) error {
 	defer cancel()
 
 	ownerField, ownerID := h.extractRlsOwnerInfo(c)
-	record, err := h.DB.GetRecord(ctx, collectionName, recordID, ownerField, ownerID)
+	if asOf := c.QueryParam("as_of"); asOf != "" {
+		return h.recordAsOf(ctx, c, collectionName, recordID, ownerField, ownerID, asOf)
+	}
+
+	record, err := h.DB.GetRecord(ctx, collectionName, recordID, ownerField, ownerID, h.selection(c))
 	if err != nil {
+		if errors.Is(err, data.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, data.ErrEmbedDenied) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
+	if notModified(c, recordETag(record)) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return c.JSON(http.StatusOK, record)
 }
 
@@ -152,28 +381,47 @@ func (h *Handler) UpdateRecord(c echo.Context) error {
 		})
 	}
 
-	var data map[string]any
-	if err := json.NewDecoder(c.Request().Body).Decode(&data); err != nil {
+	var body map[string]any
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "Invalid JSON body: " + err.Error(),
 		})
 	}
 
+	body, fieldErrs, err := h.validatePayload(c, collectionName, body, "update")
+	if err != nil {
+		return writeError(c, err, "Failed to update record")
+	}
+	if len(fieldErrs) > 0 {
+		return validationFailed(c, fieldErrs)
+	}
+
+	versions, err := ifMatchVersions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	ownerField, ownerID := h.extractRlsOwnerInfo(c)
-	err := h.DB.UpdateRecord(ctx, collectionName, recordID, data, ownerField, ownerID)
+	err = h.DB.UpdateRecord(ctx, collectionName, recordID, body, ownerField, ownerID, versions)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return writeRecordError(c, err, "Failed to update record")
+	}
+
+	// Hand back the new version so the client can chain conditional writes
+	if record, err := h.DB.GetRecord(ctx, collectionName, recordID, ownerField, ownerID, data.Selection{Expr: "updated_at"}); err == nil {
+		if etag := recordETag(record); etag != "" {
+			c.Response().Header().Set("ETag", etag)
+		}
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
 // DeleteRecord handles DELETE /api/collections/:name/records/:id
+// The record is moved to the trash unless ?hard=true asks for permanent deletion.
 func (h *Handler) DeleteRecord(c echo.Context) error {
 	collectionName := c.Param("name")
 	recordID := c.Param("id")
@@ -184,38 +432,124 @@ func (h *Handler) DeleteRecord(c echo.Context) error {
 		})
 	}
 
+	versions, err := ifMatchVersions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	ownerField, ownerID := h.extractRlsOwnerInfo(c)
-	err := h.DB.DeleteRecord(ctx, collectionName, recordID, ownerField, ownerID)
+	if c.QueryParam("hard") == "true" {
+		err = h.DB.PurgeRecord(ctx, collectionName, recordID, ownerField, ownerID, versions)
+	} else {
+		err = h.DB.DeleteRecord(ctx, collectionName, recordID, ownerField, ownerID, versions)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return writeRecordError(c, err, "Failed to delete record")
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
-// ImportRecords handles POST /api/tables/:name/import
-func (h *Handler) ImportRecords(c echo.Context) error {
+// RestoreRecord handles POST /api/collections/:name/records/:id/restore
+func (h *Handler) RestoreRecord(c echo.Context) error {
 	collectionName := c.Param("name")
-	if collectionName == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name required"})
+	recordID := c.Param("id")
+
+	if collectionName == "" || recordID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name and record ID are required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+	err := h.DB.RestoreRecord(ctx, collectionName, recordID, ownerField, ownerID)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "record not found in trash"})
+	}
+	if err != nil {
+		return writeError(c, err, "Failed to restore record")
+	}
+
+	record, err := h.DB.GetRecord(ctx, collectionName, recordID, ownerField, ownerID, h.selection(c))
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]string{"id": recordID})
 	}
+	return c.JSON(http.StatusOK, record)
+}
 
-	var records []map[string]any
-	if err := json.NewDecoder(c.Request().Body).Decode(&records); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid JSON array"})
+// UpdateRecords handles PATCH /api/collections/:name/records
+// It applies the body to every row matching the query filters.
+func (h *Handler) UpdateRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	filters := c.QueryParams()
+
+	if !data.HasFilters(filters) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "At least one filter is required for bulk updates",
+		})
 	}
 
+	var body map[string]any
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid JSON body: " + err.Error(),
+		})
+	}
+
+	body, fieldErrs, err := h.validatePayload(c, collectionName, body, "update")
+	if err != nil {
+		return writeError(c, err, "Failed to update records")
+	}
+	if len(fieldErrs) > 0 {
+		return validationFailed(c, fieldErrs)
+	}
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
 	defer cancel()
 
-	if err := h.DB.BulkInsertRecord(ctx, collectionName, records); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	affected, err := h.DB.UpdateRecords(ctx, collectionName, filters, body, ownerField, ownerID, callerRole(c))
+	if err != nil {
+		return writeError(c, err, "Failed to update records")
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{"affected": affected})
+}
+
+// DeleteRecords handles DELETE /api/collections/:name/records
+// It soft-deletes every row matching the query filters.
+func (h *Handler) DeleteRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	filters := c.QueryParams()
+
+	if !data.HasFilters(filters) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "At least one filter is required for bulk deletes",
+		})
+	}
+
+	ownerField, ownerID := h.extractRlsOwnerInfo(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	affected, err := h.DB.DeleteRecords(ctx, collectionName, filters, ownerField, ownerID, callerRole(c))
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		log.Printf("⚠️ DeleteRecords failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete records",
+		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Imported %d records", len(records))})
+	return c.JSON(http.StatusOK, map[string]int64{"affected": affected})
 }
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListOptions(t *testing.T) {
+	e := echo.New()
+	h := &Handler{MaxPageSize: 100}
+
+	newContext := func(query string) echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/api/collections/posts/records?"+query, nil)
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	t.Run("Defaults to server maximum", func(t *testing.T) {
+		opts, err := h.parseListOptions(newContext(""))
+		assert.NoError(t, err)
+		assert.Equal(t, 100, opts.Limit)
+		assert.Equal(t, 0, opts.Offset)
+	})
+
+	t.Run("Clamps limit", func(t *testing.T) {
+		opts, err := h.parseListOptions(newContext("limit=5000&offset=20"))
+		assert.NoError(t, err)
+		assert.Equal(t, 100, opts.Limit)
+		assert.Equal(t, 20, opts.Offset)
+	})
+
+	t.Run("Rejects invalid values", func(t *testing.T) {
+		for _, q := range []string{"limit=0", "limit=abc", "offset=-1", "count=fuzzy", "cursor=abc&offset=10", "trashed=all"} {
+			_, err := h.parseListOptions(newContext(q))
+			assert.Error(t, err, q)
+		}
+	})
+}
+
+func TestContentRange(t *testing.T) {
+	assert.Equal(t, "0-24/3573", contentRange(0, 25, 3573))
+	assert.Equal(t, "50-59/*", contentRange(50, 10, -1))
+	assert.Equal(t, "*/0", contentRange(0, 0, 0))
+	// Cursor pages report the total only
+	assert.Equal(t, "*/3573", contentRange(-1, 25, 3573))
+	assert.Equal(t, "*/*", contentRange(-1, 25, -1))
+}
+
+func TestPreferences(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Add("Prefer", "resolution=merge-duplicates, return=minimal")
+	req.Header.Add("Prefer", "count=exact")
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	prefs := preferences(c)
+	assert.Equal(t, "merge-duplicates", prefs["resolution"])
+	assert.Equal(t, "minimal", prefs["return"])
+	assert.Equal(t, "exact", prefs["count"])
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// CallRPC handles POST /api/rpc/:function
+//
+// The body is a JSON object of named arguments. Only functions of the public
+// schema whose comment carries the @rpc annotation can be called; they run
+// with the caller's RLS claims like record queries do.
+func (h *Handler) CallRPC(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	fn, err := h.DB.GetRPCFunction(ctx, c.Param("function"))
+	if errors.Is(err, data.ErrFunctionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return writeError(c, err, "Failed to load function")
+	}
+
+	body := map[string]any{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&body); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+
+	args, errs := ValidateRPCArgs(fn, body)
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+
+	result, err := h.DB.CallRPC(ctx, fn, args)
+	if err != nil {
+		return writeError(c, err, "Failed to call function")
+	}
+	if fn.Returns == data.RPCReturnsVoid {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// ValidateRPCArgs checks a body against a function's arguments and coerces the
+// values like record fields of the same type
+func ValidateRPCArgs(fn *data.RPCFunction, body map[string]any) (map[string]any, []ValidationError) {
+	var errs []ValidationError
+	out := make(map[string]any, len(body))
+
+	known := make(map[string]bool, len(fn.Args))
+	for _, arg := range fn.Args {
+		known[arg.Name] = true
+
+		val, ok := body[arg.Name]
+		if !ok {
+			if !arg.HasDefault {
+				errs = append(errs, ValidationError{Field: arg.Name, Message: "is required"})
+			}
+			continue
+		}
+		if val == nil {
+			out[arg.Name] = nil
+			continue
+		}
+
+		coerced, err := coerceField(data.FieldSchema{Name: arg.Name, Type: arg.Type, Items: arg.Items}, val)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: arg.Name, Message: err.Error()})
+			continue
+		}
+		out[arg.Name] = coerced
+	}
+
+	for key := range body {
+		if !known[key] {
+			errs = append(errs, ValidationError{Field: key, Message: "unknown argument"})
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return out, errs
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRPCArgs(t *testing.T) {
+	fn := &data.RPCFunction{
+		Name: "place_order",
+		Args: []data.RPCArg{
+			{Name: "customer", Type: "uuid"},
+			{Name: "skus", Type: data.FieldArray, Items: "text"},
+			{Name: "qty", Type: "int4", HasDefault: true},
+		},
+	}
+
+	t.Run("Coerces arguments", func(t *testing.T) {
+		out, errs := ValidateRPCArgs(fn, map[string]any{
+			"customer": "6F9619FF-8B86-D011-B42D-00CF4FC964FF",
+			"skus":     []any{"a-1", 2.0},
+		})
+		assert.Empty(t, errs)
+		assert.Equal(t, "6f9619ff-8b86-d011-b42d-00cf4fc964ff", out["customer"])
+		assert.Equal(t, []any{"a-1", "2"}, out["skus"])
+		assert.NotContains(t, out, "qty")
+	})
+
+	t.Run("Reports missing, invalid and unknown arguments", func(t *testing.T) {
+		_, errs := ValidateRPCArgs(fn, map[string]any{
+			"qty":   1.5,
+			"extra": true,
+		})
+		assert.Equal(t, []ValidationError{
+			{Field: "customer", Message: "is required"},
+			{Field: "extra", Message: "unknown argument"},
+			{Field: "qty", Message: "must be an integer in range"},
+			{Field: "skus", Message: "is required"},
+		}, errs)
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// SearchRecords handles GET /api/collections/:name/search
+//
+// q is a web search style query ("wireless mouse", "\"exact phrase\"",
+// "mouse -wired"). Other params filter, select and page the matches like
+// ListRecords; results are ordered by rank and carry _rank and _highlights.
+func (h *Handler) SearchRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	if collectionName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name is required",
+		})
+	}
+
+	list, err := h.parseListOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if list.Cursor != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "search results are paged with offset, not cursor"})
+	}
+
+	opts := data.SearchOptions{
+		Query:   c.QueryParam("q"),
+		Filters: maps.Clone(list.Filters),
+		Trashed: list.Trashed,
+		Limit:   list.Limit,
+		Offset:  list.Offset,
+		Select:  list.Select,
+	}
+	delete(opts.Filters, "q")
+
+	// Restrict rows to the caller's own when RLS is enabled
+	opts.OwnerField, opts.OwnerID = h.extractRlsOwnerInfo(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	records, err := h.DB.SearchRecords(ctx, collectionName, opts)
+	if err != nil {
+		return writeError(c, err, "Failed to search records")
+	}
+	if records == nil {
+		records = []map[string]any{}
+	}
+	return c.JSON(http.StatusOK, records)
+}
+
+// UpdateSearchConfig handles PUT /api/collections/:name/search
+//
+// It replaces the generated search column of a table collection. An empty
+// field list turns search off.
+func (h *Handler) UpdateSearchConfig(c echo.Context) error {
+	name := c.Param("name")
+	if !data.ValidCollectionName(name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid collection name"})
+	}
+
+	var cfg data.SearchConfig
+	if err := c.Bind(&cfg); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Minute)
+	defer cancel()
+
+	if data.IsViewKind(h.DB.CollectionKind(ctx, name)) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "search is only supported on table collections"})
+	}
+
+	sql, err := h.DB.UpdateSearchConfig(ctx, name, cfg)
+	if err != nil {
+		return writeError(c, err, "Failed to update search configuration")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("update_search_%s", name)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	if cfg.Fields == nil {
+		cfg.Fields = []string{}
+	}
+	if len(cfg.Fields) == 0 {
+		cfg.Language = ""
+	} else if cfg.Language == "" {
+		cfg.Language = data.DefaultSearchLanguage
+	}
+	return c.JSON(http.StatusOK, cfg)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// vectorIndexTimeout bounds building an ANN index over a large collection
+const vectorIndexTimeout = 10 * time.Minute
+
+// SimilarRequest is the body of a nearest-neighbor query
+type SimilarRequest struct {
+	Field   string            `json:"field"`
+	Vector  []float64         `json:"vector"`
+	K       int               `json:"k"`       // defaults to 10, capped at the page size
+	Metric  string            `json:"metric"`  // "cosine" (default), "l2" or "inner_product"
+	Filters map[string]string `json:"filters"` // same grammar as the list query params
+	Trashed string            `json:"trashed"`
+	Select  string            `json:"select"`
+}
+
+// SimilarRecords handles POST /api/collections/:name/similar
+//
+// It returns the k records whose vector field is closest to the query vector,
+// nearest first, each with its _distance. Uses the field's ANN index when the
+// metric matches it.
+func (h *Handler) SimilarRecords(c echo.Context) error {
+	collectionName := c.Param("name")
+	if collectionName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Collection name is required",
+		})
+	}
+
+	var req SimilarRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Field == "" || len(req.Vector) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "field and vector are required"})
+	}
+	if req.K == 0 {
+		req.K = 10
+	}
+	if h.MaxPageSize > 0 && req.K > h.MaxPageSize {
+		req.K = h.MaxPageSize
+	}
+
+	sel := h.selection(c)
+	if req.Select != "" {
+		sel.Expr = req.Select
+	}
+	opts := data.SimilarOptions{
+		Field:   req.Field,
+		Vector:  req.Vector,
+		K:       req.K,
+		Metric:  req.Metric,
+		Filters: make(map[string][]string, len(req.Filters)),
+		Trashed: req.Trashed,
+		Select:  sel,
+	}
+	for key, value := range req.Filters {
+		opts.Filters[key] = []string{value}
+	}
+
+	// Restrict rows to the caller's own when RLS is enabled
+	opts.OwnerField, opts.OwnerID = h.extractRlsOwnerInfo(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	records, err := h.DB.SimilarRecords(ctx, collectionName, opts)
+	if err != nil {
+		return writeError(c, err, "Failed to find similar records")
+	}
+	if records == nil {
+		records = []map[string]any{}
+	}
+	return c.JSON(http.StatusOK, records)
+}
+
+// ListVectorIndexes handles GET /api/collections/:name/vector-indexes
+func (h *Handler) ListVectorIndexes(c echo.Context) error {
+	defs, err := h.DB.ListVectorIndexes(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return writeError(c, err, "Failed to list vector indexes")
+	}
+	if defs == nil {
+		defs = []string{}
+	}
+	return c.JSON(http.StatusOK, defs)
+}
+
+// CreateVectorIndex handles PUT /api/collections/:name/vector-indexes
+//
+// It (re)builds the HNSW or IVFFlat index of a pgvector field. Queries only
+// use it when they ask for the metric it was built for.
+func (h *Handler) CreateVectorIndex(c echo.Context) error {
+	name := c.Param("name")
+	var idx data.VectorIndex
+	if err := c.Bind(&idx); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), vectorIndexTimeout)
+	defer cancel()
+
+	sql, err := h.DB.CreateVectorIndex(ctx, name, idx)
+	if err != nil {
+		return writeError(c, err, "Failed to create vector index")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("create_vector_index_%s_%s", name, idx.Field)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]string{"index": data.VectorIndexName(name, idx.Field)})
+}
+
+// DropVectorIndex handles DELETE /api/collections/:name/vector-indexes/:field
+func (h *Handler) DropVectorIndex(c echo.Context) error {
+	name, field := c.Param("name"), c.Param("field")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	sql, err := h.DB.DropVectorIndex(ctx, name, field)
+	if err != nil {
+		return writeError(c, err, "Failed to drop vector index")
+	}
+
+	// 📜 Record Migration
+	description := fmt.Sprintf("drop_vector_index_%s_%s", name, field)
+	if _, err := h.Migrations.CreateMigration(description, sql); err != nil {
+		log.Printf("⚠️ Warning: Failed to record migration: %v", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
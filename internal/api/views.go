@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Xangel0s/OzyBase/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// materializeTimeout bounds creating and refreshing a materialized view
+	materializeTimeout = 10 * time.Minute
+	// viewRefreshInterval coalesces writes before on_change views are refreshed
+	viewRefreshInterval = 5 * time.Second
+)
+
+// GetRefreshStatus handles GET /api/collections/:name/refresh
+func (h *Handler) GetRefreshStatus(c echo.Context) error {
+	status, err := h.DB.GetRefreshStatus(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return writeError(c, err, "Failed to load refresh status")
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// RefreshView handles POST /api/collections/:name/refresh
+//
+// It refreshes a materialized view collection now, whatever its policy, and
+// returns the outcome. A failed refresh keeps the previous data and returns 500.
+func (h *Handler) RefreshView(c echo.Context) error {
+	name := c.Param("name")
+	if !data.ValidCollectionName(name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid collection name"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), materializeTimeout)
+	defer cancel()
+
+	// Reject other collections before running anything
+	if _, err := h.DB.GetRefreshStatus(ctx, name); err != nil {
+		return writeError(c, err, "Failed to load refresh status")
+	}
+
+	status, err := h.DB.RefreshMaterializedView(ctx, name)
+	if err != nil {
+		return writeError(c, err, "Failed to refresh view")
+	}
+	if status.Status != nil && *status.Status == "error" {
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error":  "Refresh failed",
+			"status": status,
+		})
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// StartViewRefresher refreshes materialized views on the on_change policy after
+// writes to the tables they select from. Writes are collected from the realtime
+// broker and coalesced per interval, so a burst of writes costs one refresh.
+func (h *Handler) StartViewRefresher(ctx context.Context) {
+	var mu sync.Mutex
+	changed := make(map[string]bool)
+
+	// Drain events on their own goroutine so a slow refresh never stalls the broker
+	events := h.Broker.Subscribe()
+	go func() {
+		for event := range events {
+			mu.Lock()
+			changed[event.Table] = true
+			mu.Unlock()
+		}
+	}()
+
+	ticker := time.NewTicker(viewRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mu.Lock()
+		tables := slices.Collect(maps.Keys(changed))
+		clear(changed)
+		mu.Unlock()
+		if len(tables) == 0 {
+			continue
+		}
+
+		views, err := h.DB.ViewsRefreshedBy(ctx, tables)
+		if err != nil {
+			log.Printf("⚠️ Failed to find views to refresh: %v", err)
+			continue
+		}
+		for _, view := range views {
+			refreshCtx, cancel := context.WithTimeout(ctx, materializeTimeout)
+			status, err := h.DB.RefreshMaterializedView(refreshCtx, view)
+			cancel()
+			switch {
+			case err != nil:
+				log.Printf("⚠️ Failed to refresh view %s: %v", view, err)
+			case status.Error != nil:
+				log.Printf("⚠️ Refresh of view %s failed: %s", view, *status.Error)
+			}
+		}
+	}
+}
@@ -19,6 +19,10 @@ type Config struct {
 	RateLimitRPS   float64
 	RateLimitBurst int
 	BodyLimit      string
+	MaxPageSize    int
+
+	// ImportBodyLimit replaces BodyLimit for streaming table imports
+	ImportBodyLimit string
 
 	// Storage
 	StorageProvider string
@@ -72,6 +76,7 @@ func Load() (*Config, error) {
 	burst, _ := strconv.Atoi(getEnv("RATE_LIMIT_BURST", "20"))
 
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	maxPageSize, _ := strconv.Atoi(getEnv("OZY_MAX_PAGE_SIZE", "1000"))
 
 	cfg := &Config{
 		DatabaseURL:    dbURL,
@@ -81,6 +86,9 @@ func Load() (*Config, error) {
 		RateLimitRPS:   rps,
 		RateLimitBurst: burst,
 		BodyLimit:      getEnv("BODY_LIMIT", "10M"),
+		MaxPageSize:    maxPageSize,
+
+		ImportBodyLimit: getEnv("IMPORT_BODY_LIMIT", "1G"),
 
 		// Storage
 		StorageProvider: getEnv("OZY_STORAGE_PROVIDER", "local"),
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// aggregateFuncs lists the supported aggregates in the order their results are selected
+var aggregateFuncs = []string{"count", "sum", "avg", "min", "max"}
+
+// truncUnits are the date_trunc precisions a group key may bucket by
+var truncUnits = map[string]bool{
+	"second": true, "minute": true, "hour": true, "day": true,
+	"week": true, "month": true, "quarter": true, "year": true,
+}
+
+// AggregateOptions describes a GROUP BY query over a collection
+type AggregateOptions struct {
+	// Group is a comma-separated list of columns or date_trunc(unit,column)
+	// buckets, each optionally renamed as alias:expr. Empty aggregates all rows.
+	Group string
+	// Metrics maps an aggregate function (count, sum, avg, min, max) to a
+	// comma-separated list of columns, each optionally renamed as alias:column.
+	// count also accepts * to count rows.
+	Metrics map[string]string
+	Filters map[string][]string
+	Trashed string
+	// Order is a comma-separated list of result keys with optional .asc/.desc,
+	// e.g. "sum_total.desc". It defaults to the group keys ascending.
+	Order string
+	Limit int
+	// Role hides the fields it can't read from grouping, metrics and filters,
+	// see Selection.Role
+	Role string
+	// OwnerField and OwnerID work as in ListOptions
+	OwnerField string
+	OwnerID    string
+}
+
+// aggregateExpr is one validated output column of an aggregate query
+type aggregateExpr struct {
+	SQL   string
+	Alias string
+}
+
+// parseAlias splits "alias:expr" into its parts; without a prefix alias is empty
+func parseAlias(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	alias, expr, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", s, nil
+	}
+	alias = strings.TrimSpace(alias)
+	if !IsValidIdentifier(alias) {
+		return "", "", fmt.Errorf("%w: invalid alias %q", ErrInvalidQuery, alias)
+	}
+	return alias, strings.TrimSpace(expr), nil
+}
+
+// groupExpr validates a group key: a column or date_trunc(unit,column) over a
+// date or timestamp column. Bucketed keys default to the alias column_unit.
+func groupExpr(s string, columns map[string]string) (aggregateExpr, error) {
+	alias, expr, err := parseAlias(s)
+	if err != nil {
+		return aggregateExpr{}, err
+	}
+
+	if inner, ok := strings.CutPrefix(expr, "date_trunc("); ok && strings.HasSuffix(inner, ")") {
+		unit, col, ok := strings.Cut(strings.TrimSuffix(inner, ")"), ",")
+		unit, col = strings.ToLower(strings.TrimSpace(unit)), strings.TrimSpace(col)
+		if !ok || !truncUnits[unit] {
+			return aggregateExpr{}, fmt.Errorf("%w: invalid date_trunc unit in %q", ErrInvalidQuery, expr)
+		}
+		dataType, ok := columns[col]
+		if !ok {
+			return aggregateExpr{}, fmt.Errorf("%w: unknown group column %q", ErrInvalidQuery, col)
+		}
+		if dataType != "date" && !strings.HasPrefix(dataType, "timestamp") {
+			return aggregateExpr{}, fmt.Errorf("%w: date_trunc needs a date or timestamp column, %q is %s", ErrInvalidQuery, col, dataType)
+		}
+		if alias == "" {
+			alias = col + "_" + unit
+		}
+		return aggregateExpr{SQL: fmt.Sprintf("date_trunc('%s', %s)", unit, col), Alias: alias}, nil
+	}
+
+	if _, ok := columns[expr]; !ok {
+		return aggregateExpr{}, fmt.Errorf("%w: unknown group column %q", ErrInvalidQuery, expr)
+	}
+	if alias == "" {
+		alias = expr
+	}
+	return aggregateExpr{SQL: expr, Alias: alias}, nil
+}
+
+// metricExpr validates one aggregate over a column. sum and avg need a numeric
+// column; min and max reject JSON, which has no ordering. Results default to the
+// alias fn_column, or count for count(*).
+func metricExpr(fn, s string, columns map[string]string) (aggregateExpr, error) {
+	alias, col, err := parseAlias(s)
+	if err != nil {
+		return aggregateExpr{}, err
+	}
+
+	if col == "*" {
+		if fn != "count" {
+			return aggregateExpr{}, fmt.Errorf("%w: %s(*) is not supported", ErrInvalidQuery, fn)
+		}
+		if alias == "" {
+			alias = "count"
+		}
+		return aggregateExpr{SQL: "COUNT(*)", Alias: alias}, nil
+	}
+
+	dataType, ok := columns[col]
+	if !ok {
+		return aggregateExpr{}, fmt.Errorf("%w: unknown %s column %q", ErrInvalidQuery, fn, col)
+	}
+	switch fn {
+	case "sum", "avg":
+		if !isNumericColumn(dataType) {
+			return aggregateExpr{}, fmt.Errorf("%w: %s needs a numeric column, %q is %s", ErrInvalidQuery, fn, col, dataType)
+		}
+	case "min", "max":
+		if dataType == "json" || dataType == "jsonb" {
+			return aggregateExpr{}, fmt.Errorf("%w: %s is not defined for JSON column %q", ErrInvalidQuery, fn, col)
+		}
+	}
+
+	if alias == "" {
+		alias = fn + "_" + col
+	}
+	return aggregateExpr{SQL: fmt.Sprintf("%s(%s)", strings.ToUpper(fn), col), Alias: alias}, nil
+}
+
+// isNumericColumn reports whether an information_schema data type is numeric
+func isNumericColumn(dataType string) bool {
+	switch dataType {
+	case "smallint", "integer", "bigint", "real", "double precision", "numeric":
+		return true
+	}
+	return false
+}
+
+// buildAggregateQuery renders the SELECT for opts, numbering its parameters from 1
+func buildAggregateQuery(collectionName string, columns map[string]string, opts AggregateOptions) (string, []any, error) {
+	var groups, metrics []aggregateExpr
+
+	if strings.TrimSpace(opts.Group) != "" {
+		parts, err := splitTopLevel(opts.Group)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, part := range parts {
+			g, err := groupExpr(part, columns)
+			if err != nil {
+				return "", nil, err
+			}
+			groups = append(groups, g)
+		}
+	}
+
+	for fn := range opts.Metrics {
+		if !slices.Contains(aggregateFuncs, fn) {
+			return "", nil, fmt.Errorf("%w: unknown aggregate %q", ErrInvalidQuery, fn)
+		}
+	}
+	for _, fn := range aggregateFuncs {
+		list := strings.TrimSpace(opts.Metrics[fn])
+		if list == "" {
+			continue
+		}
+		for _, part := range strings.Split(list, ",") {
+			m, err := metricExpr(fn, part, columns)
+			if err != nil {
+				return "", nil, err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	if len(metrics) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one of count, sum, avg, min or max is required", ErrInvalidQuery)
+	}
+
+	outputs := append(append([]aggregateExpr{}, groups...), metrics...)
+	seen := make(map[string]bool, len(outputs))
+	selectList := make([]string, len(outputs))
+	for i, out := range outputs {
+		if seen[out.Alias] {
+			return "", nil, fmt.Errorf("%w: duplicate result key %q, rename it with alias:", ErrInvalidQuery, out.Alias)
+		}
+		seen[out.Alias] = true
+		selectList[i] = fmt.Sprintf(`%s AS "%s"`, out.SQL, out.Alias)
+	}
+
+	whereClauses, args, err := buildFilterClauses(opts.Filters, columns, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	trashed, err := trashedClause(opts.Trashed, columns)
+	if err != nil {
+		return "", nil, err
+	}
+	ownerWhere, ownerArgs := recordOwner{opts.OwnerField, opts.OwnerID}.clause("", len(args)+1)
+	args = append(args, ownerArgs...)
+
+	// #nosec G201
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s%s", strings.Join(selectList, ", "),
+		QuoteTable(collectionName), strings.Join(append([]string{trashed}, whereClauses...), " AND "), ownerWhere)
+
+	if len(groups) > 0 {
+		positions := make([]string, len(groups))
+		for i := range groups {
+			positions[i] = fmt.Sprint(i + 1)
+		}
+		query += " GROUP BY " + strings.Join(positions, ", ")
+	}
+
+	order, err := aggregateOrder(opts.Order, groups, seen)
+	if err != nil {
+		return "", nil, err
+	}
+	if order != "" {
+		query += " ORDER BY " + order
+	}
+	if opts.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+	}
+
+	return query, args, nil
+}
+
+// aggregateOrder renders the ORDER BY list, which may only name result keys
+func aggregateOrder(order string, groups []aggregateExpr, keys map[string]bool) (string, error) {
+	if strings.TrimSpace(order) == "" {
+		var terms []string
+		for _, g := range groups {
+			terms = append(terms, fmt.Sprintf(`"%s" ASC`, g.Alias))
+		}
+		return strings.Join(terms, ", "), nil
+	}
+
+	var terms []string
+	for _, part := range strings.Split(order, ",") {
+		term, err := parseSortOrder(strings.TrimSpace(part))
+		if err != nil {
+			return "", err
+		}
+		if !keys[term.Column] {
+			return "", fmt.Errorf("%w: order must name a group or aggregate, got %q", ErrInvalidQuery, term.Column)
+		}
+		terms = append(terms, fmt.Sprintf(`"%s" %s`, term.Column, term.direction()))
+	}
+	return strings.Join(terms, ", "), nil
+}
+
+// AggregateRecords runs a GROUP BY query over a collection, respecting RLS.
+// Each result row maps group keys and aggregate aliases to their values.
+func (db *DB) AggregateRecords(ctx context.Context, collectionName string, opts AggregateOptions) ([]map[string]any, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	var results []map[string]any
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns, err = readableColumns(ctx, tx, collectionName, opts.Role, columns)
+		if err != nil {
+			return err
+		}
+
+		query, args, err := buildAggregateQuery(collectionName, columns, opts)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		results, err = rowsToMaps(rows)
+		return err
+	})
+	return results, err
+}
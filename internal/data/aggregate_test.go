@@ -0,0 +1,106 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var aggregateColumns = map[string]string{
+	"id":         "uuid",
+	"status":     "text",
+	"country":    "text",
+	"total":      "numeric",
+	"items":      "integer",
+	"meta":       "jsonb",
+	"created_at": "timestamp with time zone",
+	"deleted_at": "timestamp with time zone",
+}
+
+func TestBuildAggregateQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  AggregateOptions
+		query string
+		args  []any
+	}{
+		{
+			"Count all rows",
+			AggregateOptions{Metrics: map[string]string{"count": "*"}},
+			`SELECT COUNT(*) AS "count" FROM "public"."orders" WHERE deleted_at IS NULL`,
+			nil,
+		},
+		{
+			"Group by column and day bucket",
+			AggregateOptions{
+				Group:   "status,date_trunc(day,created_at)",
+				Metrics: map[string]string{"sum": "total", "count": "*"},
+			},
+			`SELECT status AS "status", date_trunc('day', created_at) AS "created_at_day", COUNT(*) AS "count", SUM(total) AS "sum_total" ` +
+				`FROM "public"."orders" WHERE deleted_at IS NULL GROUP BY 1, 2 ORDER BY "status" ASC, "created_at_day" ASC`,
+			nil,
+		},
+		{
+			"Aliases, filters, order and limit",
+			AggregateOptions{
+				Group:   "country",
+				Metrics: map[string]string{"sum": "revenue:total", "max": "items"},
+				Filters: map[string][]string{"status": {"eq.paid"}},
+				Order:   "revenue.desc",
+				Limit:   10,
+			},
+			`SELECT country AS "country", SUM(total) AS "revenue", MAX(items) AS "max_items" ` +
+				`FROM "public"."orders" WHERE deleted_at IS NULL AND status = $1 GROUP BY 1 ORDER BY "revenue" DESC LIMIT 10`,
+			[]any{"paid"},
+		},
+		{
+			"Owner applies even when its column is not readable",
+			AggregateOptions{
+				Metrics:    map[string]string{"count": "*"},
+				Filters:    map[string][]string{"status": {"eq.paid"}},
+				OwnerField: "user_id",
+				OwnerID:    "u1",
+			},
+			`SELECT COUNT(*) AS "count" FROM "public"."orders" WHERE deleted_at IS NULL AND status = $1 AND user_id = $2`,
+			[]any{"paid", "u1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := buildAggregateQuery("orders", aggregateColumns, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.query, query)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestBuildAggregateQueryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AggregateOptions
+	}{
+		{"No aggregate", AggregateOptions{Group: "status"}},
+		{"Unknown aggregate", AggregateOptions{Metrics: map[string]string{"median": "total"}}},
+		{"Unknown group column", AggregateOptions{Group: "region", Metrics: map[string]string{"count": "*"}}},
+		{"Injected group", AggregateOptions{Group: "status; DROP TABLE orders", Metrics: map[string]string{"count": "*"}}},
+		{"Bad trunc unit", AggregateOptions{Group: "date_trunc(fortnight,created_at)", Metrics: map[string]string{"count": "*"}}},
+		{"Trunc on text", AggregateOptions{Group: "date_trunc(day,status)", Metrics: map[string]string{"count": "*"}}},
+		{"Sum of text", AggregateOptions{Metrics: map[string]string{"sum": "status"}}},
+		{"Max of JSON", AggregateOptions{Metrics: map[string]string{"max": "meta"}}},
+		{"Sum of star", AggregateOptions{Metrics: map[string]string{"sum": "*"}}},
+		{"Duplicate key", AggregateOptions{Group: "count:status", Metrics: map[string]string{"count": "*"}}},
+		{"Order by raw column", AggregateOptions{Group: "status", Metrics: map[string]string{"count": "*"}, Order: "total.desc"}},
+		{"Bad alias", AggregateOptions{Metrics: map[string]string{"count": "1x:*"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := buildAggregateQuery("orders", aggregateColumns, tt.opts)
+			assert.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidQuery), "expected ErrInvalidQuery, got %v", err)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// managedColumns are added to every table by OzyBase and can't be altered
+var managedColumns = map[string]bool{"id": true, "created_at": true, "updated_at": true, "deleted_at": true}
+
+// ColumnChange describes changes to an existing column; unset parts are left alone
+type ColumnChange struct {
+	Name        string `json:"name"`  // new name
+	Type        string `json:"type"`  // new scalar type, see TypeMapping
+	Using       string `json:"using"` // cast for the type change, e.g. "round(price)::int4"
+	Required    *bool  `json:"required"`
+	Default     any    `json:"default"`
+	DropDefault bool   `json:"drop_default"`
+}
+
+// ColumnState is the part of a column a ColumnChange alters, as the catalog has it
+type ColumnState struct {
+	Type    string // e.g. "integer" or "character varying(20)"
+	NotNull bool
+	Default string // default expression, empty without one
+}
+
+// BuildAlterColumnSQL renders the statements applying change to a column in
+// state current, and the statements undoing them. The rename comes last, so
+// every other statement refers to the current name.
+func BuildAlterColumnSQL(table, column string, current ColumnState, change ColumnChange) ([]string, []string, error) {
+	if !ValidCollectionName(table) || !IsValidIdentifier(column) {
+		return nil, nil, fmt.Errorf("%w: invalid table or column name", ErrInvalidSchema)
+	}
+	if managedColumns[column] {
+		return nil, nil, fmt.Errorf("%w: %s is managed by OzyBase", ErrInvalidSchema, column)
+	}
+	if change.Default != nil && change.DropDefault {
+		return nil, nil, fmt.Errorf("%w: set either default or drop_default", ErrInvalidSchema)
+	}
+	if change.Using != "" && change.Type == "" {
+		return nil, nil, fmt.Errorf("%w: using needs a type", ErrInvalidSchema)
+	}
+
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", QuoteTable(table), column)
+	var forward, reverse []string
+	step := func(do, undo string) {
+		forward = append(forward, do)
+		reverse = slices.Insert(reverse, 0, undo)
+	}
+	restoreDefault := alter + "DROP DEFAULT"
+	if current.Default != "" {
+		restoreDefault = alter + "SET DEFAULT " + current.Default
+	}
+
+	if change.DropDefault {
+		step(alter+"DROP DEFAULT", restoreDefault)
+	}
+	if change.Type != "" {
+		pgType, ok := TypeMapping[strings.ToLower(change.Type)]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unknown type: %s", ErrInvalidSchema, change.Type)
+		}
+		if change.Using != "" && !validSQLFragment(change.Using) {
+			return nil, nil, fmt.Errorf("%w: invalid using expression", ErrInvalidSchema)
+		}
+		do := alter + "TYPE " + pgType
+		if change.Using != "" {
+			do += " USING " + change.Using
+		}
+		step(do, fmt.Sprintf("%sTYPE %s USING %s::%s", alter, current.Type, column, current.Type))
+	}
+	if change.Default != nil {
+		step(alter+"SET DEFAULT "+formatDefault(change.Default, change.Type), restoreDefault)
+	}
+	if change.Required != nil {
+		restore := alter + "DROP NOT NULL"
+		if current.NotNull {
+			restore = alter + "SET NOT NULL"
+		}
+		if *change.Required {
+			step(alter+"SET NOT NULL", restore)
+		} else {
+			step(alter+"DROP NOT NULL", restore)
+		}
+	}
+	if change.Name != "" && change.Name != column {
+		if !IsValidIdentifier(change.Name) || managedColumns[change.Name] {
+			return nil, nil, fmt.Errorf("%w: invalid column name %s", ErrInvalidSchema, change.Name)
+		}
+		step(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", QuoteTable(table), column, change.Name),
+			fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", QuoteTable(table), change.Name, column))
+	}
+
+	if len(forward) == 0 {
+		return nil, nil, fmt.Errorf("%w: nothing to change", ErrInvalidSchema)
+	}
+	return forward, reverse, nil
+}
+
+// applyColumnChange returns the schema field after change, checking its
+// validators still fit a new type
+func applyColumnChange(field FieldSchema, change ColumnChange) (FieldSchema, error) {
+	if change.Type != "" {
+		if _, ok := TypeMapping[strings.ToLower(field.Type)]; !ok {
+			return field, fmt.Errorf("%w: the type of %s field %s can't be changed", ErrInvalidSchema, field.Type, field.Name)
+		}
+		field.Type = change.Type
+		if err := validateFieldOptions(field); err != nil {
+			return field, err
+		}
+	}
+	if change.Required != nil {
+		field.Required = *change.Required
+	}
+	switch {
+	case change.DropDefault:
+		field.Default = nil
+	case change.Default != nil:
+		field.Default = change.Default
+	}
+	if change.Name != "" {
+		field.Name = change.Name
+	}
+	return field, nil
+}
+
+// AlterColumn renames a column or changes its type, nullability or default,
+// and updates the collection schema to match. It returns the forward and
+// reverse migration. Link fields have no column and can only be renamed.
+func (db *DB) AlterColumn(ctx context.Context, table, column string, change ColumnChange) (string, string, error) {
+	if !ValidCollectionName(table) || !IsValidIdentifier(column) {
+		return "", "", fmt.Errorf("%w: invalid table or column name", ErrInvalidSchema)
+	}
+
+	var forward, reverse []string
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		var fields []FieldSchema
+		err := tx.QueryRow(ctx, "SELECT schema_def FROM _v_collections WHERE name = $1 FOR UPDATE", table).Scan(&fields)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		i := slices.IndexFunc(fields, func(f FieldSchema) bool { return f.Name == column })
+		if change.Name != column && slices.ContainsFunc(fields, func(f FieldSchema) bool { return f.Name == change.Name }) {
+			return fmt.Errorf("%w: field %s already exists", ErrInvalidSchema, change.Name)
+		}
+		if i >= 0 && fields[i].IsLinkField() {
+			forward, reverse, err = renameLinkSQL(table, column, change)
+		} else {
+			var current ColumnState
+			err = tx.QueryRow(ctx, `
+				SELECT format_type(a.atttypid, a.atttypmod), a.attnotnull, COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+				FROM pg_attribute a
+				LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+				WHERE a.attrelid = to_regclass($1) AND a.attname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+			`, QuoteTable(table), column).Scan(&current.Type, &current.NotNull, &current.Default)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: unknown column %s on %s", ErrInvalidSchema, column, table)
+			}
+			if err != nil {
+				return err
+			}
+			forward, reverse, err = BuildAlterColumnSQL(table, column, current, change)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range forward {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+
+		// Collections registered without a schema stay unmanaged
+		if i < 0 {
+			return nil
+		}
+		if fields[i], err = applyColumnChange(fields[i], change); err != nil {
+			return err
+		}
+		schemaJSON, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE _v_collections SET schema_def = $2, updated_at = NOW() WHERE name = $1", table, schemaJSON)
+		if err != nil || fields[i].Name == column {
+			return err
+		}
+
+		// Search fields are listed by name
+		_, err = tx.Exec(ctx, `
+			UPDATE _v_collections
+			SET search_fields = (
+				SELECT jsonb_agg(CASE WHEN f = to_jsonb($2::text) THEN to_jsonb($3::text) ELSE f END)
+				FROM jsonb_array_elements(search_fields) f
+			)
+			WHERE name = $1 AND jsonb_array_length(COALESCE(search_fields, '[]'::jsonb)) > 0
+		`, table, column, fields[i].Name)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return strings.Join(forward, ";\n") + ";", strings.Join(reverse, ";\n") + ";", nil
+}
+
+// renameLinkSQL renames the join table of a link field
+func renameLinkSQL(table, column string, change ColumnChange) ([]string, []string, error) {
+	if change.Type != "" || change.Required != nil || change.Default != nil || change.DropDefault {
+		return nil, nil, fmt.Errorf("%w: %s is stored in a join table and can only be renamed", ErrInvalidSchema, column)
+	}
+	if change.Name == "" || change.Name == column {
+		return nil, nil, fmt.Errorf("%w: nothing to change", ErrInvalidSchema)
+	}
+	from, to := JoinTableName(table, column), JoinTableName(table, change.Name)
+	if !IsValidIdentifier(change.Name) || !ValidCollectionName(to) {
+		return nil, nil, fmt.Errorf("%w: invalid column name %s", ErrInvalidSchema, change.Name)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", QuoteTable(from), QuoteIdent(relName(to)))},
+		[]string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", QuoteTable(to), QuoteIdent(relName(from)))}, nil
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAlterColumnSQL(t *testing.T) {
+	required, optional := true, false
+	state := ColumnState{Type: "integer", NotNull: false, Default: "0"}
+
+	tests := []struct {
+		name        string
+		change      ColumnChange
+		wantForward []string
+		wantReverse []string
+	}{
+		{
+			name:        "rename",
+			change:      ColumnChange{Name: "amount"},
+			wantForward: []string{"ALTER TABLE \"public\".\"orders\" RENAME COLUMN total TO amount"},
+			wantReverse: []string{"ALTER TABLE \"public\".\"orders\" RENAME COLUMN amount TO total"},
+		},
+		{
+			name:        "type with using",
+			change:      ColumnChange{Type: "numeric", Using: "total::numeric / 100"},
+			wantForward: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total TYPE NUMERIC USING total::numeric / 100"},
+			wantReverse: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total TYPE integer USING total::integer"},
+		},
+		{
+			name:        "nullability",
+			change:      ColumnChange{Required: &required},
+			wantForward: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total SET NOT NULL"},
+			wantReverse: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total DROP NOT NULL"},
+		},
+		{
+			name:        "drop default",
+			change:      ColumnChange{DropDefault: true, Required: &optional},
+			wantForward: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total DROP DEFAULT", "ALTER TABLE \"public\".\"orders\" ALTER COLUMN total DROP NOT NULL"},
+			wantReverse: []string{"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total DROP NOT NULL", "ALTER TABLE \"public\".\"orders\" ALTER COLUMN total SET DEFAULT 0"},
+		},
+		{
+			name:   "everything",
+			change: ColumnChange{Name: "amount", Type: "int8", Default: float64(5)},
+			wantForward: []string{
+				"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total TYPE INT8",
+				"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total SET DEFAULT 5",
+				"ALTER TABLE \"public\".\"orders\" RENAME COLUMN total TO amount",
+			},
+			wantReverse: []string{
+				"ALTER TABLE \"public\".\"orders\" RENAME COLUMN amount TO total",
+				"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total SET DEFAULT 0",
+				"ALTER TABLE \"public\".\"orders\" ALTER COLUMN total TYPE integer USING total::integer",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, reverse, err := BuildAlterColumnSQL("orders", "total", state, tt.change)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantForward, forward)
+			assert.Equal(t, tt.wantReverse, reverse)
+		})
+	}
+}
+
+func TestBuildAlterColumnSQLInvalid(t *testing.T) {
+	invalid := map[string]ColumnChange{
+		"nothing":             {},
+		"same name":           {Name: "total"},
+		"bad name":            {Name: "amount; DROP TABLE orders"},
+		"managed name":        {Name: "created_at"},
+		"unknown type":        {Type: "money"},
+		"using without type":  {Using: "total::numeric"},
+		"statement in using":  {Type: "numeric", Using: "total::numeric; DROP TABLE orders"},
+		"default and dropped": {Default: "1", DropDefault: true},
+	}
+	for name, change := range invalid {
+		_, _, err := BuildAlterColumnSQL("orders", "total", ColumnState{Type: "integer"}, change)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), name)
+	}
+
+	_, _, err := BuildAlterColumnSQL("orders", "id", ColumnState{Type: "uuid"}, ColumnChange{Name: "uid"})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+}
+
+func TestApplyColumnChange(t *testing.T) {
+	required := true
+	low := 1.0
+
+	field, err := applyColumnChange(FieldSchema{Name: "total", Type: "int4", Min: &low, Default: float64(0)},
+		ColumnChange{Name: "amount", Type: "numeric", Required: &required, DropDefault: true})
+	assert.NoError(t, err)
+	assert.Equal(t, FieldSchema{Name: "amount", Type: "numeric", Min: &low, Required: true}, field)
+
+	// Validators must still fit the new type
+	_, err = applyColumnChange(FieldSchema{Name: "total", Type: "int4", Min: &low}, ColumnChange{Type: "uuid"})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+
+	// Higher-level types keep their type
+	_, err = applyColumnChange(FieldSchema{Name: "author", Type: FieldRelation, Target: "users"}, ColumnChange{Type: "text"})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+
+	_, _, err = renameLinkSQL("posts", "tags", ColumnChange{Type: "text"})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+	forward, reverse, err := renameLinkSQL("posts", "tags", ColumnChange{Name: "labels"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE \"public\".\"posts__tags\" RENAME TO \"posts__labels\""}, forward)
+	assert.Equal(t, []string{"ALTER TABLE \"public\".\"posts__labels\" RENAME TO \"posts__tags\""}, reverse)
+}
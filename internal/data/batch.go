@@ -0,0 +1,56 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordTx exposes record writes bound to a single RLS-scoped transaction so
+// several operations can commit or roll back together
+type RecordTx struct {
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+// RunInTransaction calls fn with a RecordTx. Returning an error from fn rolls
+// back every write made through it.
+func (db *DB) RunInTransaction(ctx context.Context, fn func(rt *RecordTx) error) error {
+	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		return fn(&RecordTx{ctx: ctx, tx: tx})
+	})
+}
+
+// Insert works like DB.InsertRecord
+func (rt *RecordTx) Insert(collectionName string, data map[string]any) (string, error) {
+	if !ValidCollectionName(collectionName) {
+		return "", fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	return insertRecord(rt.ctx, rt.tx, collectionName, data)
+}
+
+// Upsert works like DB.UpsertRecord
+func (rt *RecordTx) Upsert(collectionName string, data map[string]any, conflictCols []string, ignoreDuplicates bool, ownerField, ownerID, role string) (UpsertResult, error) {
+	if !ValidCollectionName(collectionName) {
+		return UpsertResult{}, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	return upsertRecord(rt.ctx, rt.tx, collectionName, data, conflictCols, ignoreDuplicates, recordOwner{ownerField, ownerID}, role)
+}
+
+// Update works like DB.UpdateRecord
+func (rt *RecordTx) Update(collectionName, id string, data map[string]any, ownerField, ownerID string, versions []time.Time) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	return updateRecord(rt.ctx, rt.tx, collectionName, id, data, recordOwner{ownerField, ownerID}, versions)
+}
+
+// Delete works like DB.DeleteRecord
+func (rt *RecordTx) Delete(collectionName, id string, ownerField, ownerID string, versions []time.Time) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	return deleteRecord(rt.ctx, rt.tx, collectionName, id, recordOwner{ownerField, ownerID}, versions)
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrCollectionNotFound is returned for collections whose table doesn't exist
+// and, where metadata is required, for tables OzyBase doesn't manage
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Unknown field policies for record payloads
+const (
+	UnknownFieldsStrip  = "strip"
+	UnknownFieldsReject = "reject"
+)
+
+// CollectionMeta is the OzyBase metadata stored for a collection in _v_collections
+type CollectionMeta struct {
+	Name          string
+	Schema        []FieldSchema
+	UnknownFields string
+}
+
+// GetCollectionMeta loads the metadata of a managed collection. Unmanaged
+// tables fail with ErrCollectionNotFound.
+func (db *DB) GetCollectionMeta(ctx context.Context, name string) (*CollectionMeta, error) {
+	meta := &CollectionMeta{Name: name}
+	var schemaJSON []byte
+
+	err := db.Pool.QueryRow(ctx, `
+		SELECT schema_def, COALESCE(unknown_fields, 'strip')
+		FROM _v_collections
+		WHERE name = $1
+	`, name).Scan(&schemaJSON, &meta.UnknownFields)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(schemaJSON, &meta.Schema); err != nil {
+		return nil, fmt.Errorf("invalid schema definition for %s: %w", name, err)
+	}
+
+	return meta, nil
+}
+
+// NotifyTriggerSQL returns the statement that publishes a collection's changes to realtime
+func NotifyTriggerSQL(collectionName string) string {
+	return fmt.Sprintf(`
+		CREATE TRIGGER tr_notify_%s
+		AFTER INSERT OR UPDATE OR DELETE ON %s
+		FOR EACH ROW EXECUTE FUNCTION notify_event();
+	`, relName(collectionName), QuoteTable(collectionName))
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// errDryRun unwinds an import transaction after all rows were written
+var errDryRun = errors.New("dry run")
+
+// ImportOptions controls how CopyRecords writes rows
+type ImportOptions struct {
+	Upsert     bool     // merge rows that conflict instead of failing
+	OnConflict []string // conflict target for Upsert, defaults to id
+	DryRun     bool     // write everything, then roll back
+}
+
+// CopyRecords streams rows into a collection with COPY, respecting RLS. Every
+// row returned by next holds one value per entry in columns; next signals the
+// end of input by returning a nil row. It returns the number of rows written.
+func (db *DB) CopyRecords(ctx context.Context, collectionName string, columns []string, next func() ([]any, error), opts ImportOptions) (int64, error) {
+	if !ValidCollectionName(collectionName) {
+		return 0, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("%w: no columns to import", ErrInvalidQuery)
+	}
+
+	var written int64
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		tableCols, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		for _, col := range columns {
+			if _, ok := tableCols[col]; !ok {
+				return fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, col)
+			}
+		}
+
+		src := pgx.CopyFromFunc(next)
+
+		if !opts.Upsert {
+			ref, _ := ParseTableRef(collectionName) // validated above
+			written, err = tx.CopyFrom(ctx, ref.Identifier(), columns, src)
+		} else {
+			written, err = copyUpsert(ctx, tx, collectionName, tableCols, columns, src, opts.OnConflict)
+		}
+		if err != nil {
+			return err
+		}
+		if slices.Contains(columns, "id") {
+			if err := syncIDSequence(ctx, tx, collectionName); err != nil {
+				return err
+			}
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return written, err
+}
+
+// copyUpsert stages rows in a temporary table with COPY, then merges them
+// into the collection with INSERT ... ON CONFLICT
+func copyUpsert(ctx context.Context, tx pgx.Tx, collectionName string, tableCols map[string]string, columns []string, src pgx.CopyFromSource, conflictCols []string) (int64, error) {
+	if len(conflictCols) == 0 {
+		conflictCols = []string{"id"}
+	}
+
+	conflict := make(map[string]bool, len(conflictCols))
+	for _, col := range conflictCols {
+		if _, ok := tableCols[col]; !ok {
+			return 0, fmt.Errorf("%w: unknown on_conflict column %q", ErrInvalidQuery, col)
+		}
+		conflict[col] = true
+	}
+
+	var updates []string
+	for _, col := range columns {
+		if !conflict[col] {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", QuoteIdent(col), QuoteIdent(col)))
+		}
+	}
+	action := "DO NOTHING"
+	if len(updates) > 0 {
+		if _, ok := tableCols["updated_at"]; ok {
+			updates = append(updates, "updated_at = NOW()")
+		}
+		action = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+
+	// #nosec G201
+	stage := fmt.Sprintf("CREATE TEMP TABLE _ozy_import (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", QuoteTable(collectionName))
+	if _, err := tx.Exec(ctx, stage); err != nil {
+		return 0, err
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"_ozy_import"}, columns, src); err != nil {
+		return 0, err
+	}
+
+	colList := quoteIdentList(columns)
+	// #nosec G201
+	merge := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM _ozy_import ON CONFLICT (%s) %s",
+		QuoteTable(collectionName), colList, colList, quoteIdentList(conflictCols), action)
+	tag, err := tx.Exec(ctx, merge)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RowWriter receives the rows of an export
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+}
+
+// ExportRecords streams every row matching opts.Filters to w in the requested
+// order, respecting RLS. Trashed rows follow opts.Trashed; a positive
+// opts.Limit caps the row count. Pagination and projections are ignored, but
+// fields hidden from opts.Select.Role are left out along with generated
+// columns, which an import couldn't write back.
+func (db *DB) ExportRecords(ctx context.Context, collectionName string, opts ListOptions, w RowWriter) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns, err = readableColumns(ctx, tx, collectionName, opts.Select.Role, columns)
+		if err != nil {
+			return err
+		}
+		order, err := orderFor(opts.OrderBy, columns)
+		if err != nil {
+			return err
+		}
+
+		clauses, args, err := buildFilterClauses(opts.Filters, columns, 1)
+		if err != nil {
+			return err
+		}
+		trashed, err := trashedClause(opts.Trashed, columns)
+		if err != nil {
+			return err
+		}
+		ownerWhere, ownerArgs := recordOwner{opts.OwnerField, opts.OwnerID}.clause("", len(args)+1)
+		args = append(args, ownerArgs...)
+
+		exported, err := exportColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		var projection []string
+		for _, col := range exported {
+			if _, ok := columns[col]; ok {
+				projection = append(projection, QuoteIdent(col))
+			}
+		}
+
+		// #nosec G201
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s%s ORDER BY %s %s, id %s",
+			strings.Join(projection, ", "), QuoteTable(collectionName), strings.Join(append([]string{trashed}, clauses...), " AND "), ownerWhere,
+			order.Column, order.direction(), order.direction())
+		if opts.Limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+		}
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		fields := rows.FieldDescriptions()
+		names := make([]string, len(fields))
+		for i, fd := range fields {
+			names[i] = fd.Name
+		}
+		if err := w.WriteHeader(names); err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				return err
+			}
+			for i, fd := range fields {
+				values[i] = exportValue(fd.DataTypeOID, values[i])
+			}
+			if err := w.WriteRow(values); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// exportColumns lists the columns of a collection an import can write back, in
+// table order. Generated columns such as the search vector are skipped.
+func exportColumns(ctx context.Context, tx pgx.Tx, collectionName string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT attname::text FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped AND attgenerated = ''
+		ORDER BY attnum
+	`, QuoteTable(collectionName))
+	if err != nil {
+		return nil, err
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table not found: %s", collectionName)
+	}
+	return columns, nil
+}
+
+// exportValue renders values whose decoded Go type loses the column type, so
+// exported UUIDs and dates read back the way they are written
+func exportValue(oid uint32, v any) any {
+	switch val := v.(type) {
+	case [16]byte:
+		return uuid.UUID(val).String()
+	case time.Time:
+		if oid == pgtype.DateOID {
+			return val.Format(time.DateOnly)
+		}
+	}
+	return v
+}
@@ -63,12 +63,16 @@ func (db *DB) ListSchemas(ctx context.Context) ([]string, error) {
 	return schemas, nil
 }
 
-// ListTables returns a list of table names in the public schema
+// ListTables returns the collection names of the tables and views in user
+// schemas, qualified with their schema outside DefaultSchema
 func (db *DB) ListTables(ctx context.Context) ([]string, error) {
 	rows, err := db.Pool.Query(ctx, `
-		SELECT table_name
-		FROM information_schema.tables
-		WHERE table_schema = 'public'
+		SELECT collection_name(n.nspname, c.relname)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'v', 'm')
+		  AND `+userSchemasSQL+`
+		ORDER BY 1
 	`)
 	if err != nil {
 		return nil, err
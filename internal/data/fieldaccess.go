@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RoleAnonymous names unauthenticated callers in field access rules
+const RoleAnonymous = "anonymous"
+
+// HiddenFromAll in hidden_from hides a field from every role except admin
+const HiddenFromAll = "*"
+
+// Write modes of a field
+const (
+	WriteAlways   = ""       // writable whenever the record is
+	WriteOnCreate = "create" // set on create, read-only afterwards
+	WriteNever    = "none"   // never written through the records API
+)
+
+// FieldAccess narrows the collection rules for a single field. Fields a role
+// can't read are left out of its reads and realtime events and can't be
+// filtered on or written by it either.
+type FieldAccess struct {
+	HiddenFrom []string `json:"hidden_from,omitempty"` // roles, RoleAnonymous or HiddenFromAll
+	Write      string   `json:"write,omitempty"`       // WriteAlways, WriteOnCreate or WriteNever
+}
+
+// HiddenFor reports whether role may not read the field; "" is an anonymous caller
+func (a FieldAccess) HiddenFor(role string) bool {
+	if role == "" {
+		role = RoleAnonymous
+	}
+	if slices.Contains(a.HiddenFrom, role) {
+		return true
+	}
+	return role != "admin" && slices.Contains(a.HiddenFrom, HiddenFromAll)
+}
+
+// WritableBy reports whether role may set the field, on create or afterwards
+func (a FieldAccess) WritableBy(role string, create bool) bool {
+	if a.HiddenFor(role) {
+		return false
+	}
+	switch a.Write {
+	case WriteOnCreate:
+		return create
+	case WriteNever:
+		return false
+	}
+	return true
+}
+
+func (a FieldAccess) validate(field string) error {
+	switch a.Write {
+	case WriteAlways, WriteOnCreate, WriteNever:
+	default:
+		return fmt.Errorf("%w: write of %s must be 'create' or 'none'", ErrInvalidSchema, field)
+	}
+	for _, role := range a.HiddenFrom {
+		if role == "" {
+			return fmt.Errorf("%w: hidden_from of %s has an empty role", ErrInvalidSchema, field)
+		}
+	}
+	return nil
+}
+
+// hiddenFields returns the fields of a collection role can't read. An empty
+// role reads everything, see Selection.Role.
+func hiddenFields(ctx context.Context, q querier, table, role string) (map[string]bool, error) {
+	if role == "" {
+		return nil, nil
+	}
+
+	rows, err := q.Query(ctx, "SELECT schema_def FROM _v_collections WHERE name = $1", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hidden := make(map[string]bool)
+	for rows.Next() {
+		var fields []FieldSchema
+		if err := rows.Scan(&fields); err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if f.HiddenFor(role) {
+				hidden[f.Name] = true
+			}
+		}
+	}
+	return hidden, rows.Err()
+}
+
+// HiddenFields returns the fields of a collection role can't read
+func (db *DB) HiddenFields(ctx context.Context, table, role string) (map[string]bool, error) {
+	if role == "" {
+		role = RoleAnonymous
+	}
+	return hiddenFields(ctx, db.Pool, table, role)
+}
+
+// readableColumns drops the columns role can't read so filters and ordering
+// can't probe them
+func readableColumns(ctx context.Context, q querier, table, role string, columns map[string]string) (map[string]string, error) {
+	hidden, err := hiddenFields(ctx, q, table, role)
+	if err != nil || len(hidden) == 0 {
+		return columns, err
+	}
+	visible := make(map[string]string, len(columns))
+	for name, typ := range columns {
+		if !hidden[name] {
+			visible[name] = typ
+		}
+	}
+	return visible, nil
+}
+
+// UpdateFieldAccess replaces the access rules of the named fields of a collection
+func (db *DB) UpdateFieldAccess(ctx context.Context, table string, access map[string]FieldAccess) error {
+	return pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		var fields []FieldSchema
+		err := tx.QueryRow(ctx, "SELECT schema_def FROM _v_collections WHERE name = $1 FOR UPDATE", table).Scan(&fields)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: collection %s not found", ErrInvalidSchema, table)
+		}
+		if err != nil {
+			return err
+		}
+
+		for name, a := range access {
+			if err := a.validate(name); err != nil {
+				return err
+			}
+			i := slices.IndexFunc(fields, func(f FieldSchema) bool { return f.Name == name })
+			if i < 0 {
+				return fmt.Errorf("%w: unknown field %s", ErrInvalidSchema, name)
+			}
+			fields[i].FieldAccess = a
+		}
+
+		schemaJSON, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE _v_collections SET schema_def = $2, updated_at = NOW() WHERE name = $1", table, schemaJSON)
+		return err
+	})
+}
+
+// StripHidden returns a copy of record without the hidden fields
+func StripHidden(record map[string]any, hidden map[string]bool) map[string]any {
+	if record == nil || len(hidden) == 0 {
+		return record
+	}
+	out := make(map[string]any, len(record))
+	for k, v := range record {
+		if !hidden[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
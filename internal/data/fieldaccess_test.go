@@ -0,0 +1,67 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldAccessHiddenFor(t *testing.T) {
+	email := FieldAccess{HiddenFrom: []string{RoleAnonymous, "user"}}
+	assert.True(t, email.HiddenFor(""))
+	assert.True(t, email.HiddenFor(RoleAnonymous))
+	assert.True(t, email.HiddenFor("user"))
+	assert.False(t, email.HiddenFor("admin"))
+	assert.False(t, email.HiddenFor("manager"))
+
+	notes := FieldAccess{HiddenFrom: []string{HiddenFromAll}}
+	assert.True(t, notes.HiddenFor("manager"))
+	assert.False(t, notes.HiddenFor("admin"))
+
+	assert.True(t, FieldAccess{HiddenFrom: []string{HiddenFromAll, "admin"}}.HiddenFor("admin"))
+}
+
+func TestFieldAccessWritableBy(t *testing.T) {
+	tests := []struct {
+		name   string
+		access FieldAccess
+		create bool
+		want   bool
+	}{
+		{"Default on create", FieldAccess{}, true, true},
+		{"Default on update", FieldAccess{}, false, true},
+		{"Create-only on create", FieldAccess{Write: WriteOnCreate}, true, true},
+		{"Create-only on update", FieldAccess{Write: WriteOnCreate}, false, false},
+		{"Read-only", FieldAccess{Write: WriteNever}, true, false},
+		{"Hidden", FieldAccess{HiddenFrom: []string{"user"}}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.access.WritableBy("user", tt.create))
+		})
+	}
+}
+
+func TestFieldAccessSchemaValidation(t *testing.T) {
+	_, err := BuildCreateTableSQL("users", "", []FieldSchema{
+		{Name: "email", Type: "text", FieldAccess: FieldAccess{HiddenFrom: []string{RoleAnonymous}, Write: WriteOnCreate}},
+	})
+	assert.NoError(t, err)
+
+	invalid := []FieldAccess{
+		{Write: "sometimes"},
+		{HiddenFrom: []string{""}},
+	}
+	for _, access := range invalid {
+		_, err := BuildCreateTableSQL("users", "", []FieldSchema{{Name: "email", Type: "text", FieldAccess: access}})
+		assert.True(t, errors.Is(err, ErrInvalidSchema), "%+v", access)
+	}
+}
+
+func TestStripHidden(t *testing.T) {
+	record := map[string]any{"id": "1", "email": "a@b.c", "internal_notes": "vip"}
+	out := StripHidden(record, map[string]bool{"email": true, "internal_notes": true})
+	assert.Equal(t, map[string]any{"id": "1"}, out)
+	assert.Len(t, record, 3, "the input record is left untouched")
+}
@@ -0,0 +1,304 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Field types built on top of the scalar types in TypeMapping
+const (
+	FieldRelation = "relation" // foreign key to Target, or a join table when Multiple
+	FieldFile     = "file"     // UUID foreign key to _v_storage_objects
+	FieldFiles    = "files"    // join table to _v_storage_objects
+	FieldSelect   = "select"   // TEXT restricted to Options
+	FieldArray    = "array"    // Postgres array of the scalar Items type
+)
+
+// On-delete behaviors of relation fields, applied when the target record is deleted
+const (
+	OnDeleteSetNull  = "set_null" // clear the reference (single relations only)
+	OnDeleteCascade  = "cascade"  // delete the record, or drop the link of a multiple relation
+	OnDeleteRestrict = "restrict" // refuse to delete the target
+)
+
+// storageObjectsTable is the collection file fields point to
+const storageObjectsTable = "_v_storage_objects"
+
+// IsLinkField reports whether a field is stored in a join table rather than a column
+func (f FieldSchema) IsLinkField() bool {
+	switch strings.ToLower(f.Type) {
+	case FieldFiles:
+		return true
+	case FieldRelation:
+		return f.Multiple
+	}
+	return false
+}
+
+// linkTarget returns the table a relation or file field references
+func (f FieldSchema) linkTarget() string {
+	switch strings.ToLower(f.Type) {
+	case FieldFile, FieldFiles:
+		return storageObjectsTable
+	}
+	return f.Target
+}
+
+// linkIDType returns the field type of the ids a relation or file field holds
+func (f FieldSchema) linkIDType() string {
+	if strings.ToLower(f.Type) == FieldRelation && f.IDType != "" {
+		return f.IDType
+	}
+	return "uuid"
+}
+
+// allowedValues returns the values a field is restricted to: the options of a
+// select field, otherwise its enum validator
+func (f FieldSchema) allowedValues() []string {
+	if strings.ToLower(f.Type) == FieldSelect {
+		return f.Options
+	}
+	return f.Enum
+}
+
+// onDeleteSQL renders the ON DELETE action of a relation. Single relations
+// default to SET NULL, or RESTRICT when required; links default to CASCADE.
+func (f FieldSchema) onDeleteSQL() (string, error) {
+	switch f.OnDelete {
+	case "":
+		if f.IsLinkField() {
+			return "CASCADE", nil
+		}
+		if f.Required {
+			return "RESTRICT", nil
+		}
+		return "SET NULL", nil
+	case OnDeleteSetNull:
+		if f.Required || f.IsLinkField() {
+			return "", fmt.Errorf("%w: on_delete set_null needs an optional single relation, %s isn't one", ErrInvalidSchema, f.Name)
+		}
+		return "SET NULL", nil
+	case OnDeleteCascade:
+		return "CASCADE", nil
+	case OnDeleteRestrict:
+		return "RESTRICT", nil
+	}
+	return "", fmt.Errorf("%w: on_delete of %s must be 'set_null', 'cascade' or 'restrict'", ErrInvalidSchema, f.Name)
+}
+
+// columnTypeSQL renders the column type of a field including any foreign key,
+// e.g. "UUID REFERENCES authors(id) ON DELETE SET NULL". Link fields have no column.
+func columnTypeSQL(field FieldSchema) (string, error) {
+	switch strings.ToLower(field.Type) {
+	case FieldRelation, FieldFile:
+		target := field.linkTarget()
+		if !ValidCollectionName(target) {
+			return "", fmt.Errorf("%w: relation %s needs a valid target collection", ErrInvalidSchema, field.Name)
+		}
+		onDelete, err := field.onDeleteSQL()
+		if err != nil {
+			return "", err
+		}
+		idType, ok := idTypeSQL(field.linkIDType())
+		if !ok {
+			return "", fmt.Errorf("%w: relation %s has an unknown id type %s", ErrInvalidSchema, field.Name, field.IDType)
+		}
+		return fmt.Sprintf("%s REFERENCES %s(id) ON DELETE %s", idType, QuoteTable(target), onDelete), nil
+	case FieldSelect:
+		if len(field.Options) == 0 {
+			return "", fmt.Errorf("%w: select field %s needs at least one option", ErrInvalidSchema, field.Name)
+		}
+		return "TEXT", nil
+	case FieldVector:
+		return vectorColumnSQL(field)
+	case FieldArray:
+		items := strings.ToLower(field.Items)
+		itemType, ok := TypeMapping[items]
+		if !ok || items == "json" || items == "jsonb" {
+			return "", fmt.Errorf("%w: array field %s needs a scalar items type", ErrInvalidSchema, field.Name)
+		}
+		return itemType + "[]", nil
+	}
+
+	pgType, ok := TypeMapping[strings.ToLower(field.Type)]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown type: %s", ErrInvalidSchema, field.Type)
+	}
+	return pgType, nil
+}
+
+// JoinTableName returns the table holding the links of a multiple relation or
+// files field. It lives in the schema of the collection.
+func JoinTableName(table, field string) string {
+	return table + "__" + field
+}
+
+// JoinTableSQL renders the join table of a link field, whose collection has
+// ids of the field type idType. Links are dropped with their source record and
+// follow the field's on_delete for their target.
+func JoinTableSQL(table, idType string, field FieldSchema) (string, error) {
+	name := JoinTableName(table, field.Name)
+	if !ValidCollectionName(name) {
+		return "", fmt.Errorf("%w: join table name %s is too long", ErrInvalidSchema, name)
+	}
+	target := field.linkTarget()
+	if !ValidCollectionName(target) {
+		return "", fmt.Errorf("%w: relation %s needs a valid target collection", ErrInvalidSchema, field.Name)
+	}
+	onDelete, err := field.onDeleteSQL()
+	if err != nil {
+		return "", err
+	}
+	sourceType, ok := idTypeSQL(idType)
+	if !ok {
+		return "", fmt.Errorf("%w: %s has an unknown id type %s", ErrInvalidSchema, table, idType)
+	}
+	targetType, ok := idTypeSQL(field.linkIDType())
+	if !ok {
+		return "", fmt.Errorf("%w: relation %s has an unknown id type %s", ErrInvalidSchema, field.Name, field.IDType)
+	}
+
+	// #nosec G201
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	source_id %s NOT NULL REFERENCES %s(id) ON DELETE CASCADE,
+	target_id %s NOT NULL REFERENCES %s(id) ON DELETE %s,
+	position INT4 NOT NULL DEFAULT 0,
+	PRIMARY KEY (source_id, target_id)
+)`, QuoteTable(name), sourceType, QuoteTable(table), targetType, QuoteTable(target), onDelete), nil
+}
+
+// linkFields returns the link fields of a managed collection keyed by name
+func linkFields(ctx context.Context, q querier, collectionName string) (map[string]FieldSchema, error) {
+	rows, err := q.Query(ctx, `
+		SELECT f FROM _v_collections c, jsonb_array_elements(c.schema_def) f
+		WHERE c.name = $1
+		  AND (lower(f->>'type') = 'files' OR (lower(f->>'type') = 'relation' AND f->>'multiple' = 'true'))
+	`, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[string]FieldSchema)
+	for rows.Next() {
+		var field FieldSchema
+		if err := rows.Scan(&field); err != nil {
+			return nil, err
+		}
+		links[field.Name] = field
+	}
+	return links, rows.Err()
+}
+
+// splitLinks moves the values of link fields out of a write payload
+func splitLinks(data map[string]any, links map[string]FieldSchema) (map[string]any, map[string]any) {
+	if len(links) == 0 {
+		return data, nil
+	}
+	columns := make(map[string]any, len(data))
+	values := make(map[string]any)
+	for k, v := range data {
+		if _, ok := links[k]; ok {
+			values[k] = v
+			continue
+		}
+		columns[k] = v
+	}
+	return columns, values
+}
+
+// linkIDs converts the value of a link field to the target ids it lists
+func linkIDs(field string, value any) ([]string, error) {
+	var ids []string
+	switch v := value.(type) {
+	case nil:
+	case []string:
+		ids = v
+	case []any:
+		for _, item := range v {
+			switch id := item.(type) {
+			case string:
+				ids = append(ids, id)
+			case int64:
+				ids = append(ids, strconv.FormatInt(id, 10))
+			case float64:
+				// Integer ids of bigserial collections, as decoded from JSON
+				if id != math.Trunc(id) {
+					return nil, fmt.Errorf("%w: %s must be an array of ids", ErrInvalidQuery, field)
+				}
+				ids = append(ids, strconv.FormatFloat(id, 'f', -1, 64))
+			default:
+				return nil, fmt.Errorf("%w: %s must be an array of ids", ErrInvalidQuery, field)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s must be an array of ids", ErrInvalidQuery, field)
+	}
+	return ids, nil
+}
+
+// writeLinks replaces the links of a record with the given target ids, keeping their order
+func writeLinks(ctx context.Context, tx pgx.Tx, collectionName, id string, values map[string]any) error {
+	for _, field := range sortedKeys(values) {
+		ids, err := linkIDs(field, values[field])
+		if err != nil {
+			return err
+		}
+
+		join := QuoteTable(JoinTableName(collectionName, field))
+		// #nosec G201
+		if _, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE source_id = $1", join), id); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		// Ids travel as JSON so Postgres parses them as whatever type the join table holds
+		links := make([]map[string]any, len(ids))
+		for i, target := range ids {
+			links[i] = map[string]any{"source_id": id, "target_id": target, "position": i + 1}
+		}
+		linksJSON, err := json.Marshal(links)
+		if err != nil {
+			return err
+		}
+		// #nosec G201
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %s (source_id, target_id, position)
+			SELECT source_id, target_id, position FROM jsonb_populate_recordset(NULL::%s, $1::jsonb)
+			ON CONFLICT DO NOTHING
+		`, join, join), string(linksJSON))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkProjection renders the ids a link field lists for the row aliased as alias
+func linkProjection(table, alias string, field FieldSchema) string {
+	return fmt.Sprintf("ARRAY(SELECT j.target_id::text FROM %s j WHERE j.source_id = %s.id ORDER BY j.position)",
+		QuoteTable(JoinTableName(table, field.Name)), alias)
+}
+
+// JoinTables returns the join tables of a collection's link fields, which are
+// dropped along with it
+func JoinTables(ctx context.Context, tx pgx.Tx, collectionName string) ([]string, error) {
+	links, err := linkFields(ctx, tx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, name := range slices.Sorted(maps.Keys(links)) {
+		tables = append(tables, JoinTableName(collectionName, name))
+	}
+	return tables, nil
+}
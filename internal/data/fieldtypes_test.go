@@ -0,0 +1,95 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnTypeSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FieldSchema
+		want  string
+	}{
+		{"Scalar", FieldSchema{Name: "title", Type: "text"}, "TEXT"},
+		{"Optional relation", FieldSchema{Name: "author", Type: "relation", Target: "authors"}, "UUID REFERENCES \"public\".\"authors\"(id) ON DELETE SET NULL"},
+		{"Required relation", FieldSchema{Name: "author", Type: "relation", Target: "authors", Required: true}, "UUID REFERENCES \"public\".\"authors\"(id) ON DELETE RESTRICT"},
+		{"Cascading relation", FieldSchema{Name: "post", Type: "relation", Target: "posts", OnDelete: "cascade"}, "UUID REFERENCES \"public\".\"posts\"(id) ON DELETE CASCADE"},
+		{"File", FieldSchema{Name: "avatar", Type: "file"}, "UUID REFERENCES \"public\".\"_v_storage_objects\"(id) ON DELETE SET NULL"},
+		{"Select", FieldSchema{Name: "status", Type: "select", Options: []string{"draft"}}, "TEXT"},
+		{"Array", FieldSchema{Name: "tags", Type: "array", Items: "text"}, "TEXT[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := columnTypeSQL(tt.field)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestColumnTypeSQLErrors(t *testing.T) {
+	invalid := []FieldSchema{
+		{Name: "author", Type: "relation"},
+		{Name: "author", Type: "relation", Target: "authors; DROP TABLE x"},
+		{Name: "author", Type: "relation", Target: "authors", Required: true, OnDelete: "set_null"},
+		{Name: "author", Type: "relation", Target: "authors", OnDelete: "nothing"},
+		{Name: "status", Type: "select"},
+		{Name: "tags", Type: "array"},
+		{Name: "docs", Type: "array", Items: "jsonb"},
+		{Name: "x", Type: "money"},
+	}
+
+	for _, field := range invalid {
+		_, err := columnTypeSQL(field)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), "%+v", field)
+	}
+}
+
+func TestBuildCreateTableSQLFieldTypes(t *testing.T) {
+	sql, err := BuildCreateTableSQL("posts", "", []FieldSchema{
+		{Name: "author", Type: "relation", Target: "users"},
+		{Name: "tags", Type: "relation", Target: "tags", Multiple: true},
+		{Name: "attachments", Type: "files"},
+		{Name: "status", Type: "select", Options: []string{"draft", "published"}},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "author UUID REFERENCES \"public\".\"users\"(id) ON DELETE SET NULL")
+	assert.Contains(t, sql, "status TEXT CHECK (status IN ('draft', 'published'))")
+	assert.NotContains(t, sql, "tags UUID")
+
+	// Link fields become join tables created after the collection
+	assert.Contains(t, sql, ");\n\nCREATE TABLE IF NOT EXISTS \"public\".\"posts__tags\" (")
+	assert.Contains(t, sql, "source_id UUID NOT NULL REFERENCES \"public\".\"posts\"(id) ON DELETE CASCADE")
+	assert.Contains(t, sql, "target_id UUID NOT NULL REFERENCES \"public\".\"tags\"(id) ON DELETE CASCADE")
+	assert.Contains(t, sql, "target_id UUID NOT NULL REFERENCES \"public\".\"_v_storage_objects\"(id) ON DELETE CASCADE")
+}
+
+func TestSelectCheckValue(t *testing.T) {
+	field := FieldSchema{Name: "status", Type: "select", Options: []string{"draft", "published"}}
+	assert.NoError(t, field.CheckValue("draft"))
+	assert.EqualError(t, field.CheckValue("archived"), "must be one of draft, published")
+}
+
+func TestSplitLinks(t *testing.T) {
+	links := map[string]FieldSchema{"tags": {Name: "tags", Type: "relation", Multiple: true}}
+	columns, values := splitLinks(map[string]any{"title": "Hi", "tags": []any{"a"}}, links)
+	assert.Equal(t, map[string]any{"title": "Hi"}, columns)
+	assert.Equal(t, map[string]any{"tags": []any{"a"}}, values)
+
+	ids, err := linkIDs("tags", values["tags"])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids)
+
+	_, err = linkIDs("tags", "a")
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestMapPostgresArrayType(t *testing.T) {
+	assert.Equal(t, "array", mapPostgresTypeToOzy("ARRAY"))
+	assert.Equal(t, "array", mapPostgresTypeToOzy("text[]"))
+	assert.Equal(t, "text", mapPostgresTypeToOzy("text"))
+}
@@ -0,0 +1,463 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reservedParams are query params consumed by the records API itself and never treated as filters
+var reservedParams = map[string]bool{
+	"order":       true,
+	"select":      true,
+	"limit":       true,
+	"offset":      true,
+	"cursor":      true,
+	"trashed":     true,
+	"hard":        true,
+	"count":       true,
+	"envelope":    true,
+	"on_conflict": true,
+	"format":      true,
+}
+
+// HasFilters reports whether params contain at least one filter condition
+func HasFilters(params map[string][]string) bool {
+	for key := range params {
+		if !reservedParams[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// filterNode is either a single condition or an AND/OR group of nodes
+type filterNode struct {
+	// Group fields
+	Or       bool
+	Children []filterNode
+
+	// Condition fields
+	Column string
+	Path   []string // JSON path keys following Column
+	AsText bool     // last path step used ->> instead of ->
+	Op     string
+	Value  string
+	Config string // text search configuration of fts(config).
+
+	Negate bool
+}
+
+func (n filterNode) isGroup() bool {
+	return n.Column == ""
+}
+
+// parseFilters turns query params into a single AND group. Plain params are
+// column conditions ("age=gte.18", "meta->>color=eq.red"), while "or", "and",
+// "not.or" and "not.and" hold parenthesized groups: or=(a.eq.1,and(b.gt.2,b.lt.5)).
+func parseFilters(filters map[string][]string) (filterNode, error) {
+	root := filterNode{}
+
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedParams[key] {
+			continue
+		}
+
+		for _, val := range filters[key] {
+			var node filterNode
+			var err error
+
+			switch key {
+			case "or", "and", "not.or", "not.and":
+				node, err = parseGroup(key, val)
+			default:
+				node, err = parseCondition(key, val)
+			}
+			if err != nil {
+				return root, err
+			}
+			root.Children = append(root.Children, node)
+		}
+	}
+
+	return root, nil
+}
+
+// parseGroup parses a logic group: key is "or"/"and" optionally prefixed with "not.",
+// body is "(cond,cond,...)"
+func parseGroup(key, body string) (filterNode, error) {
+	node := filterNode{}
+	if rest, ok := strings.CutPrefix(key, "not."); ok {
+		node.Negate = true
+		key = rest
+	}
+	node.Or = key == "or"
+
+	body = strings.TrimSpace(body)
+	if !strings.HasPrefix(body, "(") || !strings.HasSuffix(body, ")") {
+		return node, fmt.Errorf("%w: %s group must be wrapped in parentheses", ErrInvalidQuery, key)
+	}
+
+	parts, err := splitTopLevel(body[1 : len(body)-1])
+	if err != nil {
+		return node, err
+	}
+	if len(parts) == 0 {
+		return node, fmt.Errorf("%w: empty %s group", ErrInvalidQuery, key)
+	}
+
+	for _, part := range parts {
+		child, err := parseGroupMember(part)
+		if err != nil {
+			return node, err
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	return node, nil
+}
+
+// parseGroupMember parses one element of a group: a nested "and(...)"/"not.or(...)"
+// or a condition written as "col.op.value"
+func parseGroupMember(s string) (filterNode, error) {
+	for _, prefix := range []string{"and", "or", "not.and", "not.or"} {
+		if strings.HasPrefix(s, prefix+"(") {
+			return parseGroup(prefix, s[len(prefix):])
+		}
+	}
+
+	// The column ends at the first '.' that follows its JSON path, if any
+	col, rest, ok := strings.Cut(s, ".")
+	if !ok {
+		return filterNode{}, fmt.Errorf("%w: malformed condition %q", ErrInvalidQuery, s)
+	}
+	return parseCondition(col, rest)
+}
+
+// parseCondition parses a column (optionally with a JSON path) and "[not.]op.value"
+func parseCondition(column, expr string) (filterNode, error) {
+	node := filterNode{}
+
+	col, path, asText, err := parseColumnPath(column)
+	if err != nil {
+		return node, err
+	}
+	node.Column, node.Path, node.AsText = col, path, asText
+
+	if rest, ok := strings.CutPrefix(expr, "not."); ok {
+		node.Negate = true
+		expr = rest
+	}
+
+	op, val, ok := strings.Cut(expr, ".")
+	if !ok {
+		// Bare values keep meaning equality, as they always have
+		op, val = "eq", expr
+	}
+
+	// fts(english).query names the text search configuration to use
+	if cfg, ok := strings.CutPrefix(op, "fts("); ok && strings.HasSuffix(cfg, ")") {
+		cfg = strings.TrimSuffix(cfg, ")")
+		if !IsValidIdentifier(cfg) {
+			return node, fmt.Errorf("%w: invalid text search configuration %q", ErrInvalidQuery, cfg)
+		}
+		op, node.Config = "fts", cfg
+	}
+
+	switch op {
+	case "eq", "neq", "gt", "gte", "lt", "lte", "like", "ilike", "cs", "cd", "fts":
+	case "in":
+		if !strings.HasPrefix(val, "(") || !strings.HasSuffix(val, ")") {
+			return node, fmt.Errorf("%w: in. expects a list like in.(a,b,c)", ErrInvalidQuery)
+		}
+	case "is":
+		switch strings.ToLower(val) {
+		case "null", "true", "false", "unknown":
+			val = strings.ToLower(val)
+		default:
+			return node, fmt.Errorf("%w: is. expects null, true, false or unknown", ErrInvalidQuery)
+		}
+	default:
+		return node, fmt.Errorf("%w: unknown filter operator %q", ErrInvalidQuery, op)
+	}
+
+	node.Op, node.Value = op, unquote(val)
+	return node, nil
+}
+
+// parseColumnPath splits "meta->a->>b" into the column and its JSON path keys
+func parseColumnPath(s string) (string, []string, bool, error) {
+	idx := strings.Index(s, "->")
+	if idx < 0 {
+		if !IsValidIdentifier(s) {
+			return "", nil, false, fmt.Errorf("%w: invalid column %q", ErrInvalidQuery, s)
+		}
+		return s, nil, false, nil
+	}
+
+	col := s[:idx]
+	if !IsValidIdentifier(col) {
+		return "", nil, false, fmt.Errorf("%w: invalid column %q", ErrInvalidQuery, col)
+	}
+
+	var path []string
+	asText := false
+	rest := s[idx:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "->>"):
+			asText = true
+			rest = rest[3:]
+		case strings.HasPrefix(rest, "->"):
+			asText = false
+			rest = rest[2:]
+		default:
+			return "", nil, false, fmt.Errorf("%w: malformed JSON path %q", ErrInvalidQuery, s)
+		}
+
+		next := strings.Index(rest, "->")
+		if next < 0 {
+			next = len(rest)
+		}
+		key := rest[:next]
+		if key == "" || strings.ContainsAny(key, "'\"();,") {
+			return "", nil, false, fmt.Errorf("%w: invalid JSON path key %q", ErrInvalidQuery, key)
+		}
+		path = append(path, key)
+		rest = rest[next:]
+	}
+
+	return col, path, asText, nil
+}
+
+// splitTopLevel splits on commas that are not nested in parentheses, braces or quotes
+func splitTopLevel(s string) ([]string, error) {
+	var parts []string
+	depth := 0
+	inQuote := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuote = !inQuote
+		case '(', '{':
+			if !inQuote {
+				depth++
+			}
+		case ')', '}':
+			if !inQuote {
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("%w: unbalanced parentheses in filter", ErrInvalidQuery)
+				}
+			}
+		case ',':
+			if !inQuote && depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 || inQuote {
+		return nil, fmt.Errorf("%w: unbalanced parentheses or quotes in filter", ErrInvalidQuery)
+	}
+	if tail := strings.TrimSpace(s[start:]); tail != "" || len(parts) > 0 {
+		parts = append(parts, tail)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("%w: empty element in filter list", ErrInvalidQuery)
+		}
+	}
+	return parts, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// filterBuilder renders filter trees as parameterized SQL against a known column set
+type filterBuilder struct {
+	columns map[string]string
+	args    []any
+	argIdx  int
+}
+
+func newFilterBuilder(columns map[string]string, argIdx int) *filterBuilder {
+	return &filterBuilder{columns: columns, argIdx: argIdx}
+}
+
+func (b *filterBuilder) param(v any) string {
+	b.args = append(b.args, v)
+	p := fmt.Sprintf("$%d", b.argIdx)
+	b.argIdx++
+	return p
+}
+
+// build renders a node; an empty group renders as an empty string
+func (b *filterBuilder) build(n filterNode) (string, error) {
+	var sql string
+	var err error
+
+	if n.isGroup() {
+		sql, err = b.buildGroup(n)
+	} else {
+		sql, err = b.buildCondition(n)
+	}
+	if err != nil || sql == "" {
+		return sql, err
+	}
+
+	if n.Negate {
+		return "NOT (" + sql + ")", nil
+	}
+	return sql, nil
+}
+
+func (b *filterBuilder) buildGroup(n filterNode) (string, error) {
+	var parts []string
+	for _, child := range n.Children {
+		sql, err := b.build(child)
+		if err != nil {
+			return "", err
+		}
+		if sql != "" {
+			parts = append(parts, sql)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	joiner := " AND "
+	if n.Or {
+		joiner = " OR "
+	}
+	return "(" + strings.Join(parts, joiner) + ")", nil
+}
+
+func (b *filterBuilder) buildCondition(n filterNode) (string, error) {
+	dataType, ok := b.columns[n.Column]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, n.Column)
+	}
+
+	target := n.Column
+	if len(n.Path) > 0 {
+		if dataType != "json" && dataType != "jsonb" {
+			return "", fmt.Errorf("%w: column %q is not JSON", ErrInvalidQuery, n.Column)
+		}
+		for i, key := range n.Path {
+			arrow := "->"
+			if i == len(n.Path)-1 && n.AsText {
+				arrow = "->>"
+			}
+			// Numeric keys index into arrays
+			if idx, err := strconv.Atoi(key); err == nil {
+				target += fmt.Sprintf(" %s %d", arrow, idx)
+			} else {
+				target += fmt.Sprintf(" %s %s::text", arrow, b.param(key))
+			}
+		}
+		target = "(" + target + ")"
+	}
+
+	switch n.Op {
+	case "eq":
+		return fmt.Sprintf("%s = %s", target, b.param(n.Value)), nil
+	case "neq":
+		return fmt.Sprintf("%s <> %s", target, b.param(n.Value)), nil
+	case "gt":
+		return fmt.Sprintf("%s > %s", target, b.param(n.Value)), nil
+	case "gte":
+		return fmt.Sprintf("%s >= %s", target, b.param(n.Value)), nil
+	case "lt":
+		return fmt.Sprintf("%s < %s", target, b.param(n.Value)), nil
+	case "lte":
+		return fmt.Sprintf("%s <= %s", target, b.param(n.Value)), nil
+	case "like":
+		return fmt.Sprintf("%s::text LIKE %s", target, b.param(likePattern(n.Value))), nil
+	case "ilike":
+		return fmt.Sprintf("%s::text ILIKE %s", target, b.param(likePattern(n.Value))), nil
+	case "cs":
+		return fmt.Sprintf("%s @> %s", target, b.param(n.Value)), nil
+	case "cd":
+		return fmt.Sprintf("%s <@ %s", target, b.param(n.Value)), nil
+	case "in":
+		items, err := splitTopLevel(n.Value[1 : len(n.Value)-1])
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			// Nothing can be IN an empty list
+			return "FALSE", nil
+		}
+		placeholders := make([]string, len(items))
+		for i, item := range items {
+			placeholders[i] = b.param(unquote(item))
+		}
+		return fmt.Sprintf("%s IN (%s)", target, strings.Join(placeholders, ", ")), nil
+	case "is":
+		return fmt.Sprintf("%s IS %s", target, strings.ToUpper(n.Value)), nil
+	case "fts":
+		cfg := ""
+		if n.Config != "" {
+			cfg = fmt.Sprintf("'%s', ", n.Config)
+		}
+		// Plain text columns are vectorized on the fly; tsvector columns such as
+		// search_vector use their index
+		if dataType != "tsvector" || len(n.Path) > 0 {
+			target = fmt.Sprintf("to_tsvector(%s%s::text)", cfg, target)
+		}
+		return fmt.Sprintf("%s @@ websearch_to_tsquery(%s%s)", target, cfg, b.param(n.Value)), nil
+	}
+
+	return "", fmt.Errorf("%w: unknown filter operator %q", ErrInvalidQuery, n.Op)
+}
+
+// likePattern maps PostgREST-style '*' wildcards to '%'. Patterns without any
+// wildcard keep the historical substring-match behaviour.
+func likePattern(v string) string {
+	v = strings.ReplaceAll(v, "*", "%")
+	if !strings.Contains(v, "%") {
+		return "%" + v + "%"
+	}
+	return v
+}
+
+// buildFilterClauses turns query params into parameterized WHERE conditions,
+// validating every referenced column against the table's real columns.
+func buildFilterClauses(filters map[string][]string, columns map[string]string, argIdx int) ([]string, []any, error) {
+	root, err := parseFilters(filters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := newFilterBuilder(columns, argIdx)
+
+	var clauses []string
+	for _, child := range root.Children {
+		sql, err := b.build(child)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sql != "" {
+			clauses = append(clauses, sql)
+		}
+	}
+
+	return clauses, b.args, nil
+}
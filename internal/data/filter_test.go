@@ -0,0 +1,139 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testColumns = map[string]string{
+	"id":     "uuid",
+	"status": "text",
+	"age":    "integer",
+	"tags":   "ARRAY",
+	"meta":   "jsonb",
+
+	"search_vector": "tsvector",
+}
+
+func TestBuildFilterClauses(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters map[string][]string
+		sql     []string
+		args    []any
+	}{
+		{
+			"Simple operators",
+			map[string][]string{"age": {"gte.18", "lt.65"}},
+			[]string{"age >= $1", "age < $2"},
+			[]any{"18", "65"},
+		},
+		{
+			"Bare value is equality",
+			map[string][]string{"status": {"draft"}},
+			[]string{"status = $1"},
+			[]any{"draft"},
+		},
+		{
+			"Negation and IS",
+			map[string][]string{"status": {"not.is.null"}},
+			[]string{"NOT (status IS NULL)"},
+			nil,
+		},
+		{
+			"IN list",
+			map[string][]string{"status": {"in.(draft,\"a,b\",published)"}},
+			[]string{"status IN ($1, $2, $3)"},
+			[]any{"draft", "a,b", "published"},
+		},
+		{
+			"Case sensitive like with wildcard",
+			map[string][]string{"status": {"like.dra*"}},
+			[]string{"status::text LIKE $1"},
+			[]any{"dra%"},
+		},
+		{
+			"ilike keeps substring match",
+			map[string][]string{"status": {"ilike.raf"}},
+			[]string{"status::text ILIKE $1"},
+			[]any{"%raf%"},
+		},
+		{
+			"Array containment",
+			map[string][]string{"tags": {"cs.{a,b}"}},
+			[]string{"tags @> $1"},
+			[]any{"{a,b}"},
+		},
+		{
+			"JSONB path",
+			map[string][]string{"meta->dims->>color": {"eq.red"}},
+			[]string{"(meta -> $1::text ->> $2::text) = $3"},
+			[]any{"dims", "color", "red"},
+		},
+		{
+			"Nested OR/AND groups",
+			map[string][]string{"or": {"(status.eq.draft,and(age.gte.18,age.lt.65))"}},
+			[]string{"(status = $1 OR (age >= $2 AND age < $3))"},
+			[]any{"draft", "18", "65"},
+		},
+		{
+			"Negated group",
+			map[string][]string{"not.and": {"(status.eq.draft,age.not.eq.1)"}},
+			[]string{"NOT ((status = $1 AND NOT (age = $2)))"},
+			[]any{"draft", "1"},
+		},
+		{
+			"Full-text search on a search vector",
+			map[string][]string{"search_vector": {"fts(english).wireless mouse"}},
+			[]string{"search_vector @@ websearch_to_tsquery('english', $1)"},
+			[]any{"wireless mouse"},
+		},
+		{
+			"Full-text search on a text column",
+			map[string][]string{"status": {"fts.draft"}},
+			[]string{"to_tsvector(status::text) @@ websearch_to_tsquery($1)"},
+			[]any{"draft"},
+		},
+		{
+			"Reserved params are ignored",
+			map[string][]string{"order": {"age.desc"}, "limit": {"10"}},
+			nil,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := buildFilterClauses(tt.filters, testColumns, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestBuildFilterClausesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters map[string][]string
+	}{
+		{"Unknown column", map[string][]string{"password": {"eq.x"}}},
+		{"Unknown operator", map[string][]string{"age": {"between.1"}}},
+		{"Invalid identifier", map[string][]string{"age;--": {"eq.1"}}},
+		{"Bad IS value", map[string][]string{"age": {"is.maybe"}}},
+		{"Path on non-JSON column", map[string][]string{"status->>x": {"eq.1"}}},
+		{"Unbalanced group", map[string][]string{"or": {"(age.eq.1,and(age.eq.2)"}}},
+		{"Group without parens", map[string][]string{"or": {"age.eq.1"}}},
+		{"Bad JSON key", map[string][]string{"meta->>a'b": {"eq.1"}}},
+		{"Bad text search configuration", map[string][]string{"status": {"fts(x'y).a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := buildFilterClauses(tt.filters, testColumns, 1)
+			assert.True(t, errors.Is(err, ErrInvalidQuery), "got %v", err)
+		})
+	}
+}
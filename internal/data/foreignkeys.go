@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrForeignKeyNotFound is returned for a foreign key the table doesn't have
+var ErrForeignKeyNotFound = errors.New("foreign key not found")
+
+// referentialActions are the ON DELETE and ON UPDATE actions of a foreign key,
+// named like the on_delete of relation fields
+var referentialActions = map[string]string{
+	OnDeleteCascade:  "CASCADE",
+	OnDeleteSetNull:  "SET NULL",
+	OnDeleteRestrict: "RESTRICT",
+}
+
+// catalogActions names the confdeltype/confupdtype codes of pg_constraint
+var catalogActions = map[string]string{
+	"a": "no_action",
+	"r": OnDeleteRestrict,
+	"c": OnDeleteCascade,
+	"n": OnDeleteSetNull,
+	"d": "set_default",
+}
+
+// ForeignKeySpec describes a foreign key from a column of a collection to a
+// unique column of another
+type ForeignKeySpec struct {
+	Name         string `json:"name"` // defaults to <table>_<column>_fkey
+	Column       string `json:"column"`
+	Target       string `json:"target"`        // referenced collection
+	TargetColumn string `json:"target_column"` // defaults to id
+	OnDelete     string `json:"on_delete"`     // cascade, set_null or restrict (default)
+	OnUpdate     string `json:"on_update"`     // cascade, set_null or restrict (default)
+	// NotValid adds the constraint without checking existing records. Only new
+	// writes are checked until it is validated, see ValidateForeignKey.
+	NotValid bool `json:"not_valid"`
+}
+
+// ForeignKeyInfo describes an existing foreign key of a collection
+type ForeignKeyInfo struct {
+	Name          string `json:"name"`
+	Columns       string `json:"columns"` // comma-separated for composite keys
+	Target        string `json:"target"`
+	TargetColumns string `json:"target_columns"`
+	OnDelete      string `json:"on_delete"`
+	OnUpdate      string `json:"on_update"`
+	Valid         bool   `json:"valid"` // false while added NOT VALID
+	Definition    string `json:"definition"`
+}
+
+// referentialAction renders the action of an on_delete/on_update value
+func referentialAction(clause, action string) (string, error) {
+	if action == "" {
+		action = OnDeleteRestrict
+	}
+	sql, ok := referentialActions[action]
+	if !ok {
+		return "", fmt.Errorf("%w: %s must be 'cascade', 'set_null' or 'restrict'", ErrInvalidSchema, clause)
+	}
+	return sql, nil
+}
+
+// BuildForeignKeySQL renders the ALTER TABLE statement adding the foreign key
+// of spec to table and the name the constraint gets
+func BuildForeignKeySQL(table string, spec ForeignKeySpec) (string, string, error) {
+	if !ValidCollectionName(table) || !ValidCollectionName(spec.Target) {
+		return "", "", fmt.Errorf("%w: invalid table or target name", ErrInvalidSchema)
+	}
+	targetColumn := spec.TargetColumn
+	if targetColumn == "" {
+		targetColumn = "id"
+	}
+	if !IsValidIdentifier(spec.Column) || !IsValidIdentifier(targetColumn) {
+		return "", "", fmt.Errorf("%w: invalid column or target column name", ErrInvalidSchema)
+	}
+	onDelete, err := referentialAction("on_delete", spec.OnDelete)
+	if err != nil {
+		return "", "", err
+	}
+	onUpdate, err := referentialAction("on_update", spec.OnUpdate)
+	if err != nil {
+		return "", "", err
+	}
+
+	name := spec.Name
+	if name == "" {
+		name = relName(table) + "_" + spec.Column + "_fkey"
+	}
+	if !IsValidIdentifier(name) {
+		return "", "", fmt.Errorf("%w: invalid constraint name %q, pass a shorter name", ErrInvalidSchema, name)
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s ON UPDATE %s",
+		QuoteTable(table), name, spec.Column, QuoteTable(spec.Target), targetColumn, onDelete, onUpdate)
+	if spec.NotValid {
+		sql += " NOT VALID"
+	}
+	return sql, name, nil
+}
+
+// typeMismatch reports a column whose type can't reference its target as an
+// invalid schema rather than a database failure
+func typeMismatch(err error, table string, spec ForeignKeySpec) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "42804" || pgErr.Code == "42883") {
+		return fmt.Errorf("%w: the type of %s.%s doesn't match the column it references on %s", ErrInvalidSchema, table, spec.Column, spec.Target)
+	}
+	return err
+}
+
+// CreateForeignKey adds a foreign key to a collection and returns its name
+// with the forward and reverse migration. Existing records are checked first
+// unless spec.NotValid is set. The column is indexed if no index starts with
+// it, as deletes and updates of the target look it up.
+func (db *DB) CreateForeignKey(ctx context.Context, table string, spec ForeignKeySpec) (string, string, string, error) {
+	addSQL, name, err := BuildForeignKeySQL(table, spec)
+	if err != nil {
+		return "", "", "", err
+	}
+	targetColumn := spec.TargetColumn
+	if targetColumn == "" {
+		targetColumn = "id"
+	}
+
+	var forward []string
+	reverse := []string{fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", QuoteTable(table), name)}
+	err = pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		var notNull, indexed, exists, targetUnique bool
+		err := tx.QueryRow(ctx, `
+			SELECT a.attnotnull,
+				EXISTS (SELECT 1 FROM pg_index ix WHERE ix.indrelid = a.attrelid AND ix.indkey[0] = a.attnum),
+				EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = a.attrelid AND conname = $3),
+				EXISTS (
+					SELECT 1 FROM pg_index ix
+					JOIN pg_attribute ta ON ta.attrelid = ix.indrelid AND ta.attnum = ix.indkey[0]
+					WHERE ix.indrelid = to_regclass($4) AND ix.indisunique AND ix.indnkeyatts = 1
+					  AND ix.indpred IS NULL AND ta.attname = $5
+				)
+			FROM pg_attribute a
+			WHERE a.attrelid = to_regclass($1) AND a.attname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+		`, QuoteTable(table), spec.Column, name, QuoteTable(spec.Target), targetColumn).Scan(&notNull, &indexed, &exists, &targetUnique)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: unknown column %s on %s", ErrInvalidSchema, spec.Column, table)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case exists:
+			return fmt.Errorf("%w: constraint %s already exists on %s", ErrInvalidSchema, name, table)
+		case !targetUnique:
+			return fmt.Errorf("%w: %s has no primary key or unique column %s", ErrInvalidSchema, spec.Target, targetColumn)
+		case notNull && (spec.OnDelete == OnDeleteSetNull || spec.OnUpdate == OnDeleteSetNull):
+			return fmt.Errorf("%w: set_null needs an optional column, %s is required", ErrInvalidSchema, spec.Column)
+		}
+
+		if !spec.NotValid {
+			var missing int64
+			err := tx.QueryRow(ctx, fmt.Sprintf(`
+				SELECT count(*) FROM %s t
+				WHERE t.%s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %s r WHERE r.%s = t.%s)
+			`, QuoteTable(table), spec.Column, QuoteTable(spec.Target), targetColumn, spec.Column)).Scan(&missing)
+			if err != nil {
+				return typeMismatch(err, table, spec)
+			}
+			if missing > 0 {
+				return fmt.Errorf("%w: %d records of %s reference missing %s records; fix them or pass not_valid",
+					ErrInvalidSchema, missing, table, spec.Target)
+			}
+		}
+
+		var indexName string
+		if !indexed {
+			var indexSQL string
+			indexSQL, indexName, err = BuildCreateIndexSQL(table, IndexSpec{Columns: []string{spec.Column}}, false)
+			if err != nil {
+				return err
+			}
+			forward = append(forward, indexSQL)
+			reverse = append(reverse, "DROP INDEX IF EXISTS "+siblingSQL(table, indexName))
+		}
+		forward = append(forward, addSQL)
+
+		for _, stmt := range forward {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				if indexName != "" {
+					err = duplicateIndex(err, indexName)
+				}
+				return typeMismatch(err, table, spec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	return name, strings.Join(forward, ";\n") + ";", strings.Join(reverse, ";\n") + ";", nil
+}
+
+// ValidateForeignKey checks the existing records of a foreign key added NOT
+// VALID and returns the statement to record as a migration. Validating only
+// blocks schema changes of the table, not its writes.
+func (db *DB) ValidateForeignKey(ctx context.Context, table, name string) (string, error) {
+	if _, err := db.foreignKeyDefinition(ctx, table, name); err != nil {
+		return "", err
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", QuoteTable(table), name)
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return "", fmt.Errorf("%w: %s still has records referencing missing records: %s", ErrInvalidSchema, table, pgErr.Detail)
+		}
+		return "", err
+	}
+	return sql + ";", nil
+}
+
+// DropForeignKey removes a foreign key of a collection and returns the forward
+// and reverse migration. The index supporting it is kept.
+func (db *DB) DropForeignKey(ctx context.Context, table, name string) (string, string, error) {
+	def, err := db.foreignKeyDefinition(ctx, table, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", QuoteTable(table), name)
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return "", "", err
+	}
+	return sql + ";", fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", QuoteTable(table), name, def), nil
+}
+
+// foreignKeyDefinition returns the definition of a foreign key of a
+// collection, as pg_get_constraintdef renders it
+func (db *DB) foreignKeyDefinition(ctx context.Context, table, name string) (string, error) {
+	if !ValidCollectionName(table) || !IsValidIdentifier(name) {
+		return "", fmt.Errorf("%w: invalid table or constraint name", ErrInvalidSchema)
+	}
+	var def string
+	err := db.Pool.QueryRow(ctx, `
+		SELECT pg_get_constraintdef(oid) FROM pg_constraint
+		WHERE conrelid = to_regclass($1) AND conname = $2 AND contype = 'f'
+	`, QuoteTable(table), name).Scan(&def)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("%w: %s on %s", ErrForeignKeyNotFound, name, table)
+	}
+	return def, err
+}
+
+// ListForeignKeys returns the foreign keys of a collection, naming the
+// collections they reference
+func (db *DB) ListForeignKeys(ctx context.Context, table string) ([]ForeignKeyInfo, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.conname,
+			(SELECT string_agg(a.attname, ',' ORDER BY k.i) FROM unnest(c.conkey) WITH ORDINALITY k(num, i)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.num),
+			collection_name(n.nspname, t.relname),
+			(SELECT string_agg(a.attname, ',' ORDER BY k.i) FROM unnest(c.confkey) WITH ORDINALITY k(num, i)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.num),
+			c.confdeltype::text, c.confupdtype::text, c.convalidated, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.confrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE c.conrelid = to_regclass($1) AND c.contype = 'f'
+		ORDER BY c.conname
+	`, QuoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []ForeignKeyInfo
+	for rows.Next() {
+		var fk ForeignKeyInfo
+		if err := rows.Scan(&fk.Name, &fk.Columns, &fk.Target, &fk.TargetColumns,
+			&fk.OnDelete, &fk.OnUpdate, &fk.Valid, &fk.Definition); err != nil {
+			return nil, err
+		}
+		fk.OnDelete, fk.OnUpdate = catalogActions[fk.OnDelete], catalogActions[fk.OnUpdate]
+		keys = append(keys, fk)
+	}
+	return keys, rows.Err()
+}
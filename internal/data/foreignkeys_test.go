@@ -0,0 +1,61 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildForeignKeySQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		spec     ForeignKeySpec
+		wantSQL  string
+		wantName string
+	}{
+		{
+			name:     "defaults",
+			table:    "posts",
+			spec:     ForeignKeySpec{Column: "author_id", Target: "users"},
+			wantSQL:  "ALTER TABLE \"public\".\"posts\" ADD CONSTRAINT posts_author_id_fkey FOREIGN KEY (author_id) REFERENCES \"public\".\"users\"(id) ON DELETE RESTRICT ON UPDATE RESTRICT",
+			wantName: "posts_author_id_fkey",
+		},
+		{
+			name:     "actions and not valid",
+			table:    "billing.invoices",
+			spec:     ForeignKeySpec{Column: "customer_code", Target: "customers", TargetColumn: "code", OnDelete: "set_null", OnUpdate: "cascade", NotValid: true},
+			wantSQL:  "ALTER TABLE \"billing\".\"invoices\" ADD CONSTRAINT invoices_customer_code_fkey FOREIGN KEY (customer_code) REFERENCES \"public\".\"customers\"(code) ON DELETE SET NULL ON UPDATE CASCADE NOT VALID",
+			wantName: "invoices_customer_code_fkey",
+		},
+		{
+			name:     "named",
+			table:    "posts",
+			spec:     ForeignKeySpec{Name: "posts_owner", Column: "owner", Target: "users", OnDelete: "cascade"},
+			wantSQL:  "ALTER TABLE \"public\".\"posts\" ADD CONSTRAINT posts_owner FOREIGN KEY (owner) REFERENCES \"public\".\"users\"(id) ON DELETE CASCADE ON UPDATE RESTRICT",
+			wantName: "posts_owner",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, name, err := BuildForeignKeySQL(tt.table, tt.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantName, name)
+		})
+	}
+
+	invalid := []ForeignKeySpec{
+		{Column: "author_id"},
+		{Column: "author id", Target: "users"},
+		{Column: "author_id", Target: "users", TargetColumn: "id;"},
+		{Column: "author_id", Target: "users", OnDelete: "set_default"},
+		{Column: "author_id", Target: "users", OnUpdate: "none"},
+		{Column: "author_id", Target: "pg_catalog.pg_class"},
+	}
+	for _, spec := range invalid {
+		_, _, err := BuildForeignKeySQL("posts", spec)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), "%+v", spec)
+	}
+}
@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrHistoryDisabled is returned when reading or reverting history of a
+// collection that doesn't record it
+var ErrHistoryDisabled = errors.New("history is not enabled for this collection")
+
+// HistoryEntry is one recorded change to a record. Data is the row after the
+// change, or the row as it was deleted for DELETE entries.
+type HistoryEntry struct {
+	Version   int64          `json:"version"`
+	Action    string         `json:"action"`
+	Data      map[string]any `json:"data"`
+	Actor     *string        `json:"actor"`
+	ChangedAt time.Time      `json:"changed_at"`
+}
+
+// HistoryTriggerSQL returns the statement that starts recording changes to a collection
+func HistoryTriggerSQL(collectionName string) string {
+	return fmt.Sprintf(`CREATE TRIGGER tr_history_%s
+		AFTER INSERT OR UPDATE OR DELETE ON %s
+		FOR EACH ROW EXECUTE FUNCTION record_history();`, relName(collectionName), QuoteTable(collectionName))
+}
+
+// SetHistoryEnabled turns change recording for a collection on or off.
+// Turning it off keeps the history recorded so far.
+func (db *DB) SetHistoryEnabled(ctx context.Context, collectionName string, enabled bool) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	return pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE _v_collections SET history_enabled = $2, updated_at = NOW() WHERE name = $1", collectionName, enabled)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("collection not found: %s", collectionName)
+		}
+
+		// #nosec G201
+		if _, err := tx.Exec(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS tr_history_%s ON %s", relName(collectionName), QuoteTable(collectionName))); err != nil {
+			return err
+		}
+		if enabled {
+			if _, err := tx.Exec(ctx, HistoryTriggerSQL(collectionName)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// requireHistory fails with ErrHistoryDisabled unless a collection records its changes
+func requireHistory(ctx context.Context, tx pgx.Tx, collectionName string) error {
+	var enabled bool
+	err := tx.QueryRow(ctx, "SELECT COALESCE(history_enabled, FALSE) FROM _v_collections WHERE name = $1", collectionName).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		return ErrHistoryDisabled
+	}
+	return err
+}
+
+// ownerCondition restricts history rows to snapshots owned by ownerID, mirroring
+// the owner RLS rule of the live table
+func ownerCondition(ownerField, ownerID string, argIdx int) (string, []any) {
+	if ownerField == "" || ownerID == "" {
+		return "", nil
+	}
+	if !IsValidIdentifier(ownerField) {
+		return " AND FALSE", nil
+	}
+	return fmt.Sprintf(" AND data->>'%s' = $%d", ownerField, argIdx), []any{ownerID}
+}
+
+// RecordHistory lists the recorded changes to a record, newest first. A positive
+// limit caps the number of entries.
+func (db *DB) RecordHistory(ctx context.Context, collectionName, id, ownerField, ownerID string, limit int) ([]HistoryEntry, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	var entries []HistoryEntry
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		if err := requireHistory(ctx, tx, collectionName); err != nil {
+			return err
+		}
+
+		owner, ownerArgs := ownerCondition(ownerField, ownerID, 3)
+		query := `SELECT id, action, data, actor, changed_at FROM _v_record_history
+			WHERE collection = $1 AND record_id = $2` + owner + ` ORDER BY changed_at DESC, id DESC`
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
+
+		rows, err := tx.Query(ctx, query, append([]any{collectionName, id}, ownerArgs...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e HistoryEntry
+			if err := rows.Scan(&e.Version, &e.Action, &e.Data, &e.Actor, &e.ChangedAt); err != nil {
+				return err
+			}
+			delete(e.Data, SearchVectorColumn)
+			entries = append(entries, e)
+		}
+		return rows.Err()
+	})
+	return entries, err
+}
+
+// RecordAsOf returns a record as it was at a point in time. It fails with
+// ErrRecordNotFound if the record didn't exist yet, was deleted or was trashed then.
+func (db *DB) RecordAsOf(ctx context.Context, collectionName, id, ownerField, ownerID string, asOf time.Time) (map[string]any, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	var entry HistoryEntry
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		if err := requireHistory(ctx, tx, collectionName); err != nil {
+			return err
+		}
+
+		owner, ownerArgs := ownerCondition(ownerField, ownerID, 4)
+		query := `SELECT action, data FROM _v_record_history
+			WHERE collection = $1 AND record_id = $2 AND changed_at <= $3` + owner + `
+			ORDER BY changed_at DESC, id DESC LIMIT 1`
+		err := tx.QueryRow(ctx, query, append([]any{collectionName, id, asOf}, ownerArgs...)...).Scan(&entry.Action, &entry.Data)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Action == "DELETE" || entry.Data["deleted_at"] != nil {
+		return nil, ErrRecordNotFound
+	}
+	delete(entry.Data, SearchVectorColumn)
+	return entry.Data, nil
+}
+
+// RevertRecord writes a recorded version of a record back to the live table,
+// respecting RLS. The record is recreated if it was deleted since and restored
+// if it is in the trash; created_at is kept and updated_at is set to now.
+// Fields role may not update keep their current value; create-only ones are
+// only written when the record is recreated.
+func (db *DB) RevertRecord(ctx context.Context, collectionName, id string, version int64, ownerField, ownerID, role string) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		if err := requireHistory(ctx, tx, collectionName); err != nil {
+			return err
+		}
+
+		owner, ownerArgs := ownerCondition(ownerField, ownerID, 4)
+		var snapshot map[string]any
+		err := tx.QueryRow(ctx, `SELECT data FROM _v_record_history
+			WHERE collection = $1 AND record_id = $2 AND id = $3`+owner,
+			append([]any{collectionName, id, version}, ownerArgs...)...).Scan(&snapshot)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Generated columns such as the search vector recompute themselves
+		stored, err := exportColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns := make(map[string]bool, len(stored))
+		for _, col := range stored {
+			columns[col] = true
+		}
+
+		var fields []FieldSchema
+		err = tx.QueryRow(ctx, "SELECT schema_def FROM _v_collections WHERE name = $1", collectionName).Scan(&fields)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		access := make(map[string]FieldAccess, len(fields))
+		for _, f := range fields {
+			access[f.Name] = f.FieldAccess
+		}
+
+		cols, updates := revertColumns(snapshot, columns, access, role)
+		colList := quoteIdentList(cols)
+		table := QuoteTable(collectionName)
+		// #nosec G201
+		query := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM jsonb_populate_record(NULL::%s, $1)
+			ON CONFLICT (id) DO UPDATE SET %s`,
+			table, colList, colList, table, strings.Join(updates, ", "))
+		_, err = tx.Exec(ctx, query, snapshot)
+		return err
+	})
+}
+
+// revertColumns picks the columns a revert inserts from a snapshot and the SET
+// list applied when the record still exists. Columns dropped since the
+// snapshot are skipped, columns added since keep their value, and only the
+// stored columns in columns are written.
+func revertColumns(snapshot map[string]any, columns map[string]bool, access map[string]FieldAccess, role string) ([]string, []string) {
+	cols := []string{"id"}
+	var updates []string
+	for _, col := range sortedKeys(snapshot) {
+		if !columns[col] {
+			continue
+		}
+		switch col {
+		case "id", "updated_at", "deleted_at":
+			continue
+		case "created_at":
+			cols = append(cols, col)
+			continue
+		}
+		if !access[col].WritableBy(role, true) {
+			continue
+		}
+		cols = append(cols, col)
+		if access[col].WritableBy(role, false) {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", QuoteIdent(col), QuoteIdent(col)))
+		}
+	}
+	if columns["deleted_at"] {
+		updates = append(updates, "deleted_at = NULL")
+	}
+	if columns["updated_at"] {
+		updates = append(updates, "updated_at = NOW()")
+	}
+	if len(updates) == 0 {
+		updates = append(updates, `"id" = EXCLUDED."id"`)
+	}
+	return cols, updates
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerCondition(t *testing.T) {
+	sql, args := ownerCondition("user_id", "u1", 3)
+	assert.Equal(t, " AND data->>'user_id' = $3", sql)
+	assert.Equal(t, []any{"u1"}, args)
+
+	sql, args = ownerCondition("", "", 3)
+	assert.Empty(t, sql)
+	assert.Nil(t, args)
+
+	// An owner field that can't be a column hides everything rather than nothing
+	sql, _ = ownerCondition("x' OR '1'='1", "u1", 3)
+	assert.Equal(t, " AND FALSE", sql)
+}
+
+func TestHistoryTriggerSQL(t *testing.T) {
+	sql := HistoryTriggerSQL("orders")
+	assert.Contains(t, sql, "CREATE TRIGGER tr_history_orders")
+	assert.Contains(t, sql, "ON \"public\".\"orders\"")
+	assert.Contains(t, sql, "EXECUTE FUNCTION record_history()")
+}
+
+func TestRevertColumns(t *testing.T) {
+	snapshot := map[string]any{
+		"id":               "r1",
+		"title":            "Draft",
+		"Order":            2,
+		"sku":              "A-1",
+		"price":            10,
+		"dropped":          true,
+		SearchVectorColumn: "'draft':1",
+		"created_at":       "2026-01-01T00:00:00Z",
+		"updated_at":       "2026-01-02T00:00:00Z",
+		"deleted_at":       nil,
+	}
+	// The search vector is generated, so it isn't among the stored columns
+	columns := map[string]bool{"id": true, "title": true, "Order": true, "sku": true, "price": true, "created_at": true, "updated_at": true, "deleted_at": true}
+	access := map[string]FieldAccess{
+		"sku":   {Write: WriteOnCreate},
+		"price": {Write: WriteNever},
+	}
+
+	cols, updates := revertColumns(snapshot, columns, access, "user")
+	assert.Equal(t, []string{"id", "Order", "created_at", "sku", "title"}, cols)
+	assert.Equal(t, []string{`"Order" = EXCLUDED."Order"`, `"title" = EXCLUDED."title"`, "deleted_at = NULL", "updated_at = NOW()"}, updates)
+
+	cols, updates = revertColumns(map[string]any{"id": "r1"}, map[string]bool{"id": true}, nil, "")
+	assert.Equal(t, []string{"id"}, cols)
+	assert.Equal(t, []string{`"id" = EXCLUDED."id"`}, updates)
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSchema holds collections whose name isn't qualified with a schema
+const DefaultSchema = "public"
+
+// userSchemasSQL limits a query on n (pg_namespace) to the schemas
+// collections can live in
+const userSchemasSQL = `n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'`
+
+// TableRef names the table of a collection. Collections are addressed as
+// "table" in DefaultSchema or "schema.table" in any other.
+type TableRef struct {
+	Schema string
+	Name   string
+}
+
+// ParseTableRef splits a collection name into its schema and table
+func ParseTableRef(collectionName string) (TableRef, error) {
+	ref := TableRef{Schema: DefaultSchema, Name: collectionName}
+	if schema, name, ok := strings.Cut(collectionName, "."); ok {
+		ref = TableRef{Schema: schema, Name: name}
+	}
+	if !IsValidIdentifier(ref.Schema) || !IsValidIdentifier(ref.Name) {
+		return TableRef{}, fmt.Errorf("%w: invalid collection name %q", ErrInvalidSchema, collectionName)
+	}
+	if !ValidSchemaName(ref.Schema) {
+		return TableRef{}, fmt.Errorf("%w: collections can't live in the %s schema", ErrInvalidSchema, ref.Schema)
+	}
+	return ref, nil
+}
+
+// ValidSchemaName reports whether collections can live in the named schema;
+// the system schemas are off limits
+func ValidSchemaName(name string) bool {
+	return IsValidIdentifier(name) && !strings.HasPrefix(name, "pg_") && name != "information_schema"
+}
+
+// ValidCollectionName reports whether name addresses a table, as "table" or "schema.table"
+func ValidCollectionName(name string) bool {
+	_, err := ParseTableRef(name)
+	return err == nil
+}
+
+// String returns the collection name of the table
+func (t TableRef) String() string {
+	if t.Schema == DefaultSchema {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// Identifier returns the schema-qualified table name, e.g. for CopyFrom
+func (t TableRef) Identifier() pgx.Identifier {
+	return pgx.Identifier{t.Schema, t.Name}
+}
+
+// SQL returns the schema-qualified, quoted table name for use in statements
+func (t TableRef) SQL() string {
+	return t.Identifier().Sanitize()
+}
+
+// Sibling returns the table named name in the same schema, e.g. a join table
+func (t TableRef) Sibling(name string) TableRef {
+	return TableRef{Schema: t.Schema, Name: name}
+}
+
+// relName returns the table of a collection without its schema, for naming
+// triggers and other objects that live alongside the table
+func relName(collectionName string) string {
+	if _, name, ok := strings.Cut(collectionName, "."); ok {
+		return name
+	}
+	return collectionName
+}
+
+// siblingSQL renders the quoted, schema-qualified name of an object living
+// next to a collection's table, such as one of its indexes
+func siblingSQL(collectionName, name string) string {
+	ref, err := ParseTableRef(collectionName)
+	if err != nil {
+		return QuoteIdent(name)
+	}
+	return ref.Sibling(name).SQL()
+}
+
+// QuoteIdent quotes a single identifier such as a column or schema name
+func QuoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteIdentList quotes each of names and joins them into a column list
+func quoteIdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// QuoteTable renders the quoted table of a collection. Names are validated
+// where they enter the data layer; one that doesn't parse is quoted as a
+// single identifier, which can't match a real table.
+func QuoteTable(collectionName string) string {
+	ref, err := ParseTableRef(collectionName)
+	if err != nil {
+		return QuoteIdent(collectionName)
+	}
+	return ref.SQL()
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTableRef(t *testing.T) {
+	ref, err := ParseTableRef("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, TableRef{Schema: "public", Name: "orders"}, ref)
+	assert.Equal(t, "orders", ref.String())
+	assert.Equal(t, `"public"."orders"`, ref.SQL())
+
+	ref, err = ParseTableRef("billing.invoices")
+	assert.NoError(t, err)
+	assert.Equal(t, TableRef{Schema: "billing", Name: "invoices"}, ref)
+	assert.Equal(t, "billing.invoices", ref.String())
+	assert.Equal(t, `"billing"."invoices__lines"`, ref.Sibling("invoices__lines").SQL())
+
+	for _, name := range []string{"", "a.b.c", "billing.", "pg_catalog.pg_class", "information_schema.tables", "bad-name"} {
+		_, err := ParseTableRef(name)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), name)
+		assert.False(t, ValidCollectionName(name), name)
+	}
+}
+
+func TestQualifiedNames(t *testing.T) {
+	assert.Equal(t, "invoices", relName("billing.invoices"))
+	assert.Equal(t, "orders", relName("orders"))
+	assert.Equal(t, `"billing"."invoices_status_idx"`, siblingSQL("billing.invoices", "invoices_status_idx"))
+	assert.Equal(t, `"public"."orders"`, QuoteTable("orders"))
+	assert.Equal(t, `"billing.invoices__lines"`, QuoteIdent(JoinTableName("billing.invoices", "lines")))
+	assert.Equal(t, `"billing"."invoices__lines"`, QuoteTable(JoinTableName("billing.invoices", "lines")))
+	assert.Equal(t, `"id", "order", "User"`, quoteIdentList([]string{"id", "order", "User"}))
+}
+
+func TestBuildCreateTableSQLInSchema(t *testing.T) {
+	sql, err := BuildCreateTableSQL("billing.invoices", "", []FieldSchema{{Name: "total", Type: "number"}})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, `CREATE SCHEMA IF NOT EXISTS "billing";`)
+	assert.Contains(t, sql, `CREATE TABLE IF NOT EXISTS "billing"."invoices" (`)
+
+	sql, err = BuildCreateTableSQL("orders", "", []FieldSchema{{Name: "total", Type: "number"}})
+	assert.NoError(t, err)
+	assert.NotContains(t, sql, "CREATE SCHEMA")
+
+	_, err = BuildCreateTableSQL("pg_catalog.orders", "", []FieldSchema{{Name: "total", Type: "number"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrIndexNotFound is returned when dropping an index the table doesn't have
+var ErrIndexNotFound = errors.New("index not found")
+
+// ErrIndexExists is returned when creating an index whose name is taken
+var ErrIndexExists = errors.New("index already exists")
+
+// indexMethods are the access methods indexes can be built with; vector
+// indexes have their own endpoint, see VectorIndex
+var indexMethods = map[string]bool{"btree": true, "hash": true, "gin": true, "gist": true, "brin": true}
+
+// IndexSpec describes an index to create on a collection
+type IndexSpec struct {
+	Name string `json:"name"` // defaults to <table>_<columns>_idx
+	// Columns are indexed in order, each optionally followed by asc or desc,
+	// e.g. ["status", "created_at desc"]. Expression indexes one expression
+	// instead, e.g. "lower(email)" or "(meta->>'sku')".
+	Columns    []string `json:"columns"`
+	Expression string   `json:"expression"`
+	Method     string   `json:"method"`  // btree (default), hash, gin, gist or brin
+	Opclass    string   `json:"opclass"` // operator class, e.g. jsonb_path_ops for gin
+	Unique     bool     `json:"unique"`
+	Where      string   `json:"where"` // predicate of a partial index, e.g. "deleted_at IS NULL"
+	// Concurrently builds without blocking writes. It is slower and can't run
+	// inside a transaction.
+	Concurrently bool `json:"concurrently"`
+}
+
+// IndexInfo describes an existing index with its size and usage statistics
+type IndexInfo struct {
+	Name          string `json:"name"`
+	Definition    string `json:"definition"`
+	Unique        bool   `json:"unique"`
+	Primary       bool   `json:"primary"`
+	Constraint    bool   `json:"constraint"` // backs a primary key or unique constraint
+	Valid         bool   `json:"valid"`      // false while a concurrent build runs or after it failed
+	SizeBytes     int64  `json:"size_bytes"`
+	Size          string `json:"size"`
+	Scans         int64  `json:"scans"`
+	TuplesRead    int64  `json:"tuples_read"`
+	TuplesFetched int64  `json:"tuples_fetched"`
+}
+
+// validSQLFragment rejects fragments that could end the statement or hide the
+// rest of it. Index expressions, predicates and USING casts are otherwise
+// passed to Postgres.
+func validSQLFragment(s string) bool {
+	if strings.TrimSpace(s) == "" || strings.ContainsAny(s, ";$\\") ||
+		strings.Contains(s, "--") || strings.Contains(s, "/*") {
+		return false
+	}
+	depth, quoted := 0, false
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && !quoted
+}
+
+// indexKey renders one entry of the column list: name [opclass] [ASC|DESC]
+func indexKey(column, opclass string) (string, string, error) {
+	parts := strings.Fields(column)
+	if len(parts) == 0 || len(parts) > 2 || !IsValidIdentifier(parts[0]) {
+		return "", "", fmt.Errorf("%w: invalid index column %q", ErrInvalidQuery, column)
+	}
+	key := parts[0]
+	if opclass != "" {
+		key += " " + opclass
+	}
+	if len(parts) == 2 {
+		dir := strings.ToUpper(parts[1])
+		if dir != "ASC" && dir != "DESC" {
+			return "", "", fmt.Errorf("%w: invalid index column %q", ErrInvalidQuery, column)
+		}
+		key += " " + dir
+	}
+	return key, parts[0], nil
+}
+
+// BuildCreateIndexSQL renders the CREATE INDEX statement of spec and the name
+// the index gets. CONCURRENTLY is left out when concurrently is false so the
+// statement can also be recorded as a migration, which runs in a transaction.
+func BuildCreateIndexSQL(table string, spec IndexSpec, concurrently bool) (string, string, error) {
+	if !ValidCollectionName(table) {
+		return "", "", fmt.Errorf("%w: invalid table name %q", ErrInvalidQuery, table)
+	}
+	method := strings.ToLower(spec.Method)
+	if method == "" {
+		method = "btree"
+	}
+	if !indexMethods[method] {
+		return "", "", fmt.Errorf("%w: method must be btree, hash, gin, gist or brin", ErrInvalidQuery)
+	}
+	if spec.Unique && method != "btree" {
+		return "", "", fmt.Errorf("%w: unique indexes must use btree", ErrInvalidQuery)
+	}
+	if spec.Opclass != "" && !IsValidIdentifier(spec.Opclass) {
+		return "", "", fmt.Errorf("%w: invalid operator class %q", ErrInvalidQuery, spec.Opclass)
+	}
+
+	var keys, names []string
+	switch {
+	case spec.Expression != "" && len(spec.Columns) > 0:
+		return "", "", fmt.Errorf("%w: use either columns or expression", ErrInvalidQuery)
+	case spec.Expression != "":
+		if !validSQLFragment(spec.Expression) {
+			return "", "", fmt.Errorf("%w: invalid index expression", ErrInvalidQuery)
+		}
+		if spec.Name == "" {
+			return "", "", fmt.Errorf("%w: expression indexes need a name", ErrInvalidQuery)
+		}
+		key := "(" + spec.Expression + ")"
+		if spec.Opclass != "" {
+			key += " " + spec.Opclass
+		}
+		keys = append(keys, key)
+	case len(spec.Columns) > 0:
+		for _, col := range spec.Columns {
+			key, name, err := indexKey(col, spec.Opclass)
+			if err != nil {
+				return "", "", err
+			}
+			keys, names = append(keys, key), append(names, name)
+		}
+	default:
+		return "", "", fmt.Errorf("%w: columns or expression is required", ErrInvalidQuery)
+	}
+
+	name := spec.Name
+	if name == "" {
+		name = relName(table) + "_" + strings.Join(names, "_") + "_idx"
+	}
+	if !IsValidIdentifier(name) {
+		return "", "", fmt.Errorf("%w: invalid index name %q, pass a shorter name", ErrInvalidQuery, name)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CREATE ")
+	if spec.Unique {
+		sb.WriteString("UNIQUE ")
+	}
+	sb.WriteString("INDEX ")
+	if concurrently {
+		sb.WriteString("CONCURRENTLY ")
+	}
+	fmt.Fprintf(&sb, "%s ON %s USING %s (%s)", name, QuoteTable(table), method, strings.Join(keys, ", "))
+	if spec.Where != "" {
+		if !validSQLFragment(spec.Where) {
+			return "", "", fmt.Errorf("%w: invalid index predicate", ErrInvalidQuery)
+		}
+		sb.WriteString(" WHERE " + spec.Where)
+	}
+	return sb.String(), name, nil
+}
+
+// CreateIndex builds an index on a collection and returns its name and the
+// statement to record as a migration. A failed concurrent build leaves an
+// invalid index behind, which is dropped again.
+func (db *DB) CreateIndex(ctx context.Context, table string, spec IndexSpec) (string, string, error) {
+	sql, name, err := BuildCreateIndexSQL(table, spec, spec.Concurrently)
+	if err != nil {
+		return "", "", err
+	}
+	migrationSQL, _, _ := BuildCreateIndexSQL(table, spec, false)
+
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		if err := duplicateIndex(err, name); errors.Is(err, ErrIndexExists) {
+			// The index of that name is someone else's; leave it alone
+			return "", "", err
+		}
+		if spec.Concurrently {
+			_, _ = db.Pool.Exec(context.WithoutCancel(ctx), "DROP INDEX CONCURRENTLY IF EXISTS "+siblingSQL(table, name))
+		}
+		return "", "", err
+	}
+	return name, migrationSQL + ";", nil
+}
+
+// duplicateIndex reports a failed build of an index whose name is already taken
+// as ErrIndexExists
+func duplicateIndex(err error, name string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P07" { // duplicate_table, relations share one namespace
+		return fmt.Errorf("%w: %s", ErrIndexExists, name)
+	}
+	return err
+}
+
+// DropIndex removes an index of a collection and returns the statement to
+// record as a migration. Indexes backing constraints are dropped with their
+// constraint instead.
+func (db *DB) DropIndex(ctx context.Context, table, name string, concurrently bool) (string, error) {
+	if !ValidCollectionName(table) || !IsValidIdentifier(name) {
+		return "", fmt.Errorf("%w: invalid table or index name", ErrInvalidQuery)
+	}
+
+	var constraint bool
+	err := db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conindid = ix.indexrelid)
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		WHERE i.relname = $1 AND ix.indrelid = to_regclass($2)
+	`, name, QuoteTable(table)).Scan(&constraint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s on %s", ErrIndexNotFound, name, table)
+	}
+	if constraint {
+		return "", fmt.Errorf("%w: %s backs a constraint and can't be dropped on its own", ErrInvalidQuery, name)
+	}
+
+	index := siblingSQL(table, name)
+	sql := "DROP INDEX IF EXISTS " + index
+	if concurrently {
+		sql = "DROP INDEX CONCURRENTLY IF EXISTS " + index
+	}
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return "", err
+	}
+	return "DROP INDEX IF EXISTS " + index + ";", nil
+}
+
+// ListIndexes returns the indexes of a collection with their size and how
+// often they were used since statistics were last reset
+func (db *DB) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	var exists bool
+	if ValidCollectionName(table) {
+		if err := db.Pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", QuoteTable(table)).Scan(&exists); err != nil {
+			return nil, err
+		}
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, table)
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT i.relname, pg_get_indexdef(ix.indexrelid), ix.indisunique, ix.indisprimary,
+			EXISTS (SELECT 1 FROM pg_constraint WHERE conindid = ix.indexrelid), ix.indisvalid,
+			pg_relation_size(ix.indexrelid), pg_size_pretty(pg_relation_size(ix.indexrelid)),
+			COALESCE(s.idx_scan, 0), COALESCE(s.idx_tup_read, 0), COALESCE(s.idx_tup_fetch, 0)
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = ix.indexrelid
+		WHERE ix.indrelid = to_regclass($1)
+		ORDER BY i.relname
+	`, QuoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []IndexInfo
+	for rows.Next() {
+		var ix IndexInfo
+		if err := rows.Scan(&ix.Name, &ix.Definition, &ix.Unique, &ix.Primary, &ix.Constraint, &ix.Valid,
+			&ix.SizeBytes, &ix.Size, &ix.Scans, &ix.TuplesRead, &ix.TuplesFetched); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, ix)
+	}
+	return indexes, rows.Err()
+}
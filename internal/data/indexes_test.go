@@ -0,0 +1,85 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCreateIndexSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		spec         IndexSpec
+		concurrently bool
+		wantSQL      string
+		wantName     string
+	}{
+		{
+			name:     "composite unique",
+			spec:     IndexSpec{Columns: []string{"tenant_id", "email"}, Unique: true},
+			wantSQL:  "CREATE UNIQUE INDEX users_tenant_id_email_idx ON \"public\".\"users\" USING btree (tenant_id, email)",
+			wantName: "users_tenant_id_email_idx",
+		},
+		{
+			name:     "partial with direction",
+			spec:     IndexSpec{Name: "users_recent", Columns: []string{"created_at desc"}, Where: "deleted_at IS NULL"},
+			wantSQL:  "CREATE INDEX users_recent ON \"public\".\"users\" USING btree (created_at DESC) WHERE deleted_at IS NULL",
+			wantName: "users_recent",
+		},
+		{
+			name:     "gin on jsonb",
+			spec:     IndexSpec{Columns: []string{"meta"}, Method: "GIN", Opclass: "jsonb_path_ops"},
+			wantSQL:  "CREATE INDEX users_meta_idx ON \"public\".\"users\" USING gin (meta jsonb_path_ops)",
+			wantName: "users_meta_idx",
+		},
+		{
+			name:     "expression",
+			spec:     IndexSpec{Name: "users_lower_email", Expression: "lower(email)", Unique: true},
+			wantSQL:  "CREATE UNIQUE INDEX users_lower_email ON \"public\".\"users\" USING btree ((lower(email)))",
+			wantName: "users_lower_email",
+		},
+		{
+			name:         "concurrently",
+			spec:         IndexSpec{Columns: []string{"status"}, Concurrently: true},
+			concurrently: true,
+			wantSQL:      "CREATE INDEX CONCURRENTLY users_status_idx ON \"public\".\"users\" USING btree (status)",
+			wantName:     "users_status_idx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, name, err := BuildCreateIndexSQL("users", tt.spec, tt.concurrently)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantName, name)
+		})
+	}
+}
+
+func TestBuildCreateIndexSQLInvalid(t *testing.T) {
+	invalid := map[string]IndexSpec{
+		"no columns":          {},
+		"columns and expr":    {Columns: []string{"email"}, Expression: "lower(email)", Name: "x"},
+		"unnamed expression":  {Expression: "lower(email)"},
+		"bad column":          {Columns: []string{"email; DROP TABLE users"}},
+		"bad direction":       {Columns: []string{"email sideways"}},
+		"bad method":          {Columns: []string{"email"}, Method: "rtree"},
+		"unique gin":          {Columns: []string{"meta"}, Method: "gin", Unique: true},
+		"bad opclass":         {Columns: []string{"meta"}, Opclass: "jsonb ops"},
+		"statement in expr":   {Name: "x", Expression: "lower(email)); DROP TABLE users; --"},
+		"comment in where":    {Columns: []string{"email"}, Where: "true /* */"},
+		"dollar quote":        {Columns: []string{"email"}, Where: "email = $$a$$"},
+		"unbalanced parens":   {Name: "x", Expression: "lower(email"},
+		"unbalanced quotes":   {Columns: []string{"email"}, Where: "email = 'a"},
+		"closing paren first": {Columns: []string{"email"}, Where: "true) OR (true"},
+	}
+	for name, spec := range invalid {
+		_, _, err := BuildCreateIndexSQL("users", spec, false)
+		assert.True(t, errors.Is(err, ErrInvalidQuery), name)
+	}
+
+	_, _, err := BuildCreateIndexSQL("users; --", IndexSpec{Columns: []string{"email"}}, false)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
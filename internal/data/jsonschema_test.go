@@ -0,0 +1,66 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const addressSchema = `{
+	"type": "object",
+	"required": ["city"],
+	"additionalProperties": false,
+	"properties": {
+		"city": {"type": "string", "minLength": 1},
+		"zip": {"type": "string", "pattern": "^[0-9]{5}$"},
+		"floor": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "maxItems": 2, "items": {"enum": ["home", "work"]}}
+	}
+}`
+
+func TestCheckJSONValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Valid", `{"city": "Lima", "zip": "15001", "floor": 3, "tags": ["home"]}`, ""},
+		{"Wrong root type", `[1]`, "/ must be of type object"},
+		{"Missing required", `{"zip": "15001"}`, "/city is required"},
+		{"Extra property", `{"city": "Lima", "country": "PE"}`, "/country is not allowed"},
+		{"Too short", `{"city": ""}`, "/city must be at least 1 characters"},
+		{"Pattern", `{"city": "Lima", "zip": "abc"}`, "/zip must match pattern ^[0-9]{5}$"},
+		{"Not an integer", `{"city": "Lima", "floor": 1.5}`, "/floor must be of type integer"},
+		{"Below minimum", `{"city": "Lima", "floor": -1}`, "/floor must be at least 0"},
+		{"Too many items", `{"city": "Lima", "tags": ["home", "work", "home"]}`, "/tags must have at most 2 items"},
+		{"Enum item", `{"city": "Lima", "tags": ["gym"]}`, "/tags/0 must be one of the enum values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkJSONValue(json.RawMessage(addressSchema), tt.value)
+			if tt.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestJSONSchemaFieldOptions(t *testing.T) {
+	valid := FieldSchema{Name: "address", Type: "jsonb", JSONSchema: json.RawMessage(addressSchema)}
+	assert.NoError(t, validateFieldOptions(valid))
+
+	invalid := []FieldSchema{
+		{Name: "address", Type: "text", JSONSchema: json.RawMessage(`{"type": "object"}`)},
+		{Name: "address", Type: "jsonb", JSONSchema: json.RawMessage(`{"type": "map"}`)},
+		{Name: "address", Type: "jsonb", JSONSchema: json.RawMessage(`{"properties": {"zip": {"pattern": "("}}}`)},
+		{Name: "address", Type: "jsonb", JSONSchema: json.RawMessage(`[]`)},
+	}
+	for _, field := range invalid {
+		assert.True(t, errors.Is(validateFieldOptions(field), ErrInvalidSchema), "%s", field.JSONSchema)
+	}
+}
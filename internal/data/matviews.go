@@ -0,0 +1,172 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robfig/cron/v3"
+)
+
+// Refresh policies of a materialized view collection
+const (
+	RefreshManual   = "manual"    // only through the refresh endpoint
+	RefreshCron     = "cron"      // on a cron schedule run by realtime.CronManager
+	RefreshOnChange = "on_change" // after writes to the tables the view selects from
+)
+
+// RefreshStatus describes how a materialized view is refreshed and how its
+// last refresh went
+type RefreshStatus struct {
+	Policy   string `json:"policy"`
+	Schedule string `json:"schedule,omitempty"`
+	// Concurrent is true when a unique index lets refreshes run without
+	// blocking reads (REFRESH MATERIALIZED VIEW CONCURRENTLY)
+	Concurrent    bool       `json:"concurrent"`
+	LastRefreshAt *time.Time `json:"last_refresh_at"`
+	DurationMs    *int       `json:"duration_ms"`
+	Status        *string    `json:"status"` // "success" or "error", nil before the first refresh
+	Error         *string    `json:"error,omitempty"`
+}
+
+// ValidateRefreshPolicy checks a refresh policy and, for RefreshCron, its
+// standard five-field cron schedule
+func ValidateRefreshPolicy(policy, schedule string) error {
+	switch policy {
+	case RefreshManual, RefreshOnChange:
+		return nil
+	case RefreshCron:
+		if schedule == "" {
+			return fmt.Errorf("%w: refresh_schedule is required for the cron policy", ErrInvalidQuery)
+		}
+		if _, err := cron.ParseStandard(schedule); err != nil {
+			return fmt.Errorf("%w: invalid refresh_schedule: %v", ErrInvalidQuery, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: refresh_policy must be 'manual', 'cron' or 'on_change'", ErrInvalidQuery)
+	}
+}
+
+// RefreshJobName is the _v_cron_jobs entry that refreshes a view on the cron policy
+func RefreshJobName(viewName string) string {
+	return "refresh_" + viewName
+}
+
+// UniqueIDIndexSQL returns the statement indexing the id of a materialized view.
+// Records are addressed by id, and the index allows concurrent refreshes.
+func UniqueIDIndexSQL(viewName string) string {
+	return fmt.Sprintf("CREATE UNIQUE INDEX %s_id_key ON %s (id)", relName(viewName), QuoteTable(viewName))
+}
+
+// SetRefreshPolicy stores the refresh policy of a materialized view inside tx
+// and schedules or unschedules its cron job. Callers refresh the CronManager
+// once tx is committed.
+func SetRefreshPolicy(ctx context.Context, tx pgx.Tx, viewName, policy, schedule string) error {
+	if err := ValidateRefreshPolicy(policy, schedule); err != nil {
+		return err
+	}
+	if policy != RefreshCron {
+		schedule = ""
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE _v_collections SET refresh_policy = $2, refresh_schedule = NULLIF($3, ''), updated_at = NOW()
+		WHERE name = $1 AND kind = 'materialized_view'
+	`, viewName, policy, schedule)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s is not a materialized view collection", ErrInvalidQuery, viewName)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM _v_cron_jobs WHERE name = $1", RefreshJobName(viewName)); err != nil {
+		return err
+	}
+	if policy == RefreshCron {
+		_, err = tx.Exec(ctx, "INSERT INTO _v_cron_jobs (name, schedule, command) VALUES ($1, $2, $3)",
+			RefreshJobName(viewName), schedule, fmt.Sprintf("SELECT refresh_collection_view('%s')", viewName))
+	}
+	return err
+}
+
+// UpdateRefreshPolicy changes the refresh policy of a materialized view
+func (db *DB) UpdateRefreshPolicy(ctx context.Context, viewName, policy, schedule string) error {
+	return pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		return SetRefreshPolicy(ctx, tx, viewName, policy, schedule)
+	})
+}
+
+// RefreshMaterializedView refreshes a materialized view now, concurrently when
+// a unique index allows it, and returns the recorded outcome. A failed refresh
+// is reported through the status, not the error.
+func (db *DB) RefreshMaterializedView(ctx context.Context, viewName string) (*RefreshStatus, error) {
+	if !ValidCollectionName(viewName) {
+		return nil, fmt.Errorf("invalid collection name: %s", viewName)
+	}
+	if _, err := db.Pool.Exec(ctx, "SELECT refresh_collection_view($1)", viewName); err != nil {
+		return nil, err
+	}
+	return db.GetRefreshStatus(ctx, viewName)
+}
+
+// GetRefreshStatus returns the refresh policy and last outcome of a materialized view
+func (db *DB) GetRefreshStatus(ctx context.Context, viewName string) (*RefreshStatus, error) {
+	var st RefreshStatus
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(c.refresh_policy, 'manual'), COALESCE(c.refresh_schedule, ''),
+			EXISTS (
+				SELECT 1 FROM pg_index i
+				JOIN pg_class v ON v.oid = i.indrelid
+				JOIN pg_namespace n ON n.oid = v.relnamespace
+				WHERE collection_name(n.nspname, v.relname) = c.name
+				  AND i.indisunique AND i.indisvalid AND i.indpred IS NULL AND i.indexprs IS NULL
+			),
+			c.last_refresh_at, c.last_refresh_ms, c.last_refresh_status, c.last_refresh_error
+		FROM _v_collections c
+		WHERE c.name = $1 AND c.kind = 'materialized_view'
+	`, viewName).Scan(&st.Policy, &st.Schedule, &st.Concurrent, &st.LastRefreshAt, &st.DurationMs, &st.Status, &st.Error)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s is not a materialized view collection", ErrInvalidQuery, viewName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// ViewsRefreshedBy returns the materialized views on the on_change policy that
+// select from any of the given tables, read from the views' rewrite rule dependencies
+func (db *DB) ViewsRefreshedBy(ctx context.Context, tables []string) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT c.name
+		FROM _v_collections c
+		JOIN pg_class v ON v.relkind = 'm'
+		JOIN pg_namespace vn ON vn.oid = v.relnamespace AND collection_name(vn.nspname, v.relname) = c.name
+		JOIN pg_rewrite r ON r.ev_class = v.oid
+		JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid
+		JOIN pg_class src ON src.oid = d.refobjid AND src.oid <> v.oid
+		JOIN pg_namespace sn ON sn.oid = src.relnamespace
+		WHERE c.kind = 'materialized_view'
+		  AND c.refresh_policy = 'on_change'
+		  AND collection_name(sn.nspname, src.relname) = ANY($1)
+		ORDER BY c.name
+	`, tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		views = append(views, name)
+	}
+	return views, rows.Err()
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRefreshPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		schedule string
+		wantErr  bool
+	}{
+		{"Manual", RefreshManual, "", false},
+		{"On change", RefreshOnChange, "", false},
+		{"Cron", RefreshCron, "*/15 * * * *", false},
+		{"Cron descriptor", RefreshCron, "@hourly", false},
+		{"Cron without schedule", RefreshCron, "", true},
+		{"Bad schedule", RefreshCron, "every minute", true},
+		{"Unknown policy", "hourly", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRefreshPolicy(tt.policy, tt.schedule)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuery), "expected ErrInvalidQuery, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
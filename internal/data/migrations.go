@@ -180,13 +180,18 @@ func (db *DB) RunMigrations(ctx context.Context) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_ip_rules_ip ON _v_ip_rules(ip_address)`,
 
+		// Collection name of a relation: bare in public, schema.table elsewhere
+		`CREATE OR REPLACE FUNCTION collection_name(schema_name TEXT, rel_name TEXT) RETURNS TEXT AS $$
+			SELECT CASE WHEN schema_name = 'public' THEN rel_name ELSE schema_name || '.' || rel_name END
+		$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;`,
+
 		// Realtime & Hooks Trigger Function
 		`CREATE OR REPLACE FUNCTION notify_event() RETURNS TRIGGER AS $$
 		DECLARE
 			payload JSON;
 		BEGIN
 			payload = json_build_object(
-				'table', TG_TABLE_NAME,
+				'table', collection_name(TG_TABLE_SCHEMA, TG_TABLE_NAME),
 				'action', TG_OP,
 				'record', CASE WHEN TG_OP = 'DELETE' THEN row_to_json(OLD) ELSE row_to_json(NEW) END,
 				'old', CASE WHEN TG_OP = 'UPDATE' THEN row_to_json(OLD) ELSE NULL END
@@ -226,6 +231,160 @@ func (db *DB) RunMigrations(ctx context.Context) error {
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		)`,
 
+		// Record payload policy for keys missing from schema_def
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS unknown_fields VARCHAR(10) DEFAULT 'strip'`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS retention_days INTEGER`,
+
+		// Record History (opt-in per collection via history_enabled)
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS history_enabled BOOLEAN DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS _v_record_history (
+			id BIGSERIAL PRIMARY KEY,
+			collection VARCHAR(255) NOT NULL,
+			record_id TEXT NOT NULL,
+			action VARCHAR(10) NOT NULL,
+			data JSONB NOT NULL,
+			actor TEXT,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_record_history_record ON _v_record_history(collection, record_id, changed_at)`,
+		`CREATE OR REPLACE FUNCTION record_history() RETURNS TRIGGER AS $$
+		DECLARE
+			row_data JSONB;
+		BEGIN
+			row_data = CASE WHEN TG_OP = 'DELETE' THEN to_jsonb(OLD) ELSE to_jsonb(NEW) END;
+			INSERT INTO _v_record_history (collection, record_id, action, data, actor)
+			VALUES (collection_name(TG_TABLE_SCHEMA, TG_TABLE_NAME), row_data->>'id', TG_OP, row_data,
+				NULLIF(current_setting('request.jwt.claim.sub', true), ''));
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+
+		// View Collections (read-only, backed by a SQL query)
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS kind VARCHAR(20) DEFAULT 'table'`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS view_query TEXT`,
+		`CREATE OR REPLACE FUNCTION reject_view_write() RETURNS TRIGGER AS $$
+		BEGIN
+			RAISE EXCEPTION 'collection % is read-only', collection_name(TG_TABLE_SCHEMA, TG_TABLE_NAME)
+				USING ERRCODE = 'read_only_sql_transaction';
+		END;
+		$$ LANGUAGE plpgsql;`,
+
+		// Materialized View Collections (refresh policy and last refresh outcome)
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS refresh_policy VARCHAR(20)`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS refresh_schedule VARCHAR(100)`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS last_refresh_at TIMESTAMPTZ`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS last_refresh_ms INTEGER`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS last_refresh_status VARCHAR(20)`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS last_refresh_error TEXT`,
+		`CREATE OR REPLACE FUNCTION refresh_collection_view(view_name TEXT) RETURNS VOID AS $$
+		DECLARE
+			started TIMESTAMPTZ := clock_timestamp();
+			concurrent BOOLEAN;
+			view_schema TEXT;
+			view_rel TEXT;
+		BEGIN
+			-- CONCURRENTLY needs a plain unique index and an already populated view
+			SELECT v.relispopulated AND EXISTS (
+				SELECT 1 FROM pg_index i
+				WHERE i.indrelid = v.oid AND i.indisunique AND i.indisvalid
+				  AND i.indpred IS NULL AND i.indexprs IS NULL
+			), n.nspname, v.relname INTO concurrent, view_schema, view_rel
+			FROM pg_class v
+			JOIN pg_namespace n ON n.oid = v.relnamespace
+			WHERE collection_name(n.nspname, v.relname) = view_name AND v.relkind = 'm';
+
+			IF concurrent IS NULL THEN
+				RAISE EXCEPTION 'materialized view % does not exist', view_name USING ERRCODE = 'undefined_table';
+			END IF;
+
+			BEGIN
+				IF concurrent THEN
+					EXECUTE format('REFRESH MATERIALIZED VIEW CONCURRENTLY %I.%I', view_schema, view_rel);
+				ELSE
+					EXECUTE format('REFRESH MATERIALIZED VIEW %I.%I', view_schema, view_rel);
+				END IF;
+				UPDATE _v_collections SET last_refresh_at = started, last_refresh_status = 'success', last_refresh_error = NULL,
+					last_refresh_ms = (EXTRACT(EPOCH FROM clock_timestamp() - started) * 1000)::INTEGER
+				WHERE name = view_name;
+			EXCEPTION WHEN OTHERS THEN
+				UPDATE _v_collections SET last_refresh_at = started, last_refresh_status = 'error', last_refresh_error = SQLERRM,
+					last_refresh_ms = (EXTRACT(EPOCH FROM clock_timestamp() - started) * 1000)::INTEGER
+				WHERE name = view_name;
+			END;
+		END;
+		$$ LANGUAGE plpgsql;`,
+
+		// Full-Text Search (fields behind the generated search_vector column)
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS search_fields JSONB DEFAULT '[]'`,
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS search_language VARCHAR(64)`,
+
+		// Vector Fields (distance functions for float4[] vectors when pgvector is missing)
+		`CREATE OR REPLACE FUNCTION array_cosine_distance(a FLOAT4[], b FLOAT4[]) RETURNS FLOAT8 AS $$
+			SELECT 1 - SUM(x::float8 * y) / NULLIF(SQRT(SUM(x::float8 * x)) * SQRT(SUM(y::float8 * y)), 0)
+			FROM unnest(a, b) AS v(x, y)
+		$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;`,
+		`CREATE OR REPLACE FUNCTION array_l2_distance(a FLOAT4[], b FLOAT4[]) RETURNS FLOAT8 AS $$
+			SELECT SQRT(SUM((x::float8 - y) ^ 2)) FROM unnest(a, b) AS v(x, y)
+		$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;`,
+		`CREATE OR REPLACE FUNCTION array_negative_inner_product(a FLOAT4[], b FLOAT4[]) RETURNS FLOAT8 AS $$
+			SELECT -SUM(x::float8 * y) FROM unnest(a, b) AS v(x, y)
+		$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;`,
+
+		// Primary Key Strategies (time-ordered ids for collections that don't use random UUIDs)
+		`ALTER TABLE _v_collections ADD COLUMN IF NOT EXISTS id_strategy VARCHAR(20) DEFAULT 'uuidv4'`,
+		`CREATE OR REPLACE FUNCTION gen_uuid_v7() RETURNS UUID AS $$
+			-- A random UUID with its first 48 bits replaced by the Unix time in ms and version 7
+			SELECT encode(set_bit(set_bit(
+				overlay(uuid_send(gen_random_uuid())
+					PLACING substring(int8send(floor(extract(epoch FROM clock_timestamp()) * 1000)::BIGINT) FROM 3)
+					FROM 1 FOR 6),
+				52, 1), 53, 1), 'hex')::UUID
+		$$ LANGUAGE sql VOLATILE;`,
+		`CREATE OR REPLACE FUNCTION gen_ulid() RETURNS TEXT AS $$
+		DECLARE
+			-- 48 bits of Unix time in ms and 80 random bits, in 26 Crockford base32 digits
+			random_hex TEXT := encode(uuid_send(gen_random_uuid()), 'hex');
+			bits BIT(130) := B'00' || ('x' || lpad(to_hex(floor(extract(epoch FROM clock_timestamp()) * 1000)::BIGINT), 12, '0')
+				|| substr(random_hex, 1, 12) || substr(random_hex, 19, 8))::BIT(128);
+			ulid TEXT := '';
+		BEGIN
+			FOR i IN 0..25 LOOP
+				ulid := ulid || substr('0123456789ABCDEFGHJKMNPQRSTVWXYZ', substring(bits FROM i * 5 + 1 FOR 5)::INTEGER + 1, 1);
+			END LOOP;
+			RETURN ulid;
+		END;
+		$$ LANGUAGE plpgsql VOLATILE;`,
+
+		// Schema Rules (access rules shared by the collections of a Postgres schema)
+		`CREATE TABLE IF NOT EXISTS _v_schemas (
+			name VARCHAR(63) PRIMARY KEY,
+			list_rule VARCHAR(50),
+			create_rule VARCHAR(50),
+			update_rule VARCHAR(50),
+			delete_rule VARCHAR(50),
+			rls_enabled BOOLEAN DEFAULT FALSE,
+			rls_rule TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		)`,
+
+		// Collection Changes (realtime streams drop their cached field access rules)
+		`CREATE OR REPLACE FUNCTION notify_collection_change() RETURNS TRIGGER AS $$
+		BEGIN
+			IF TG_OP <> 'INSERT' THEN
+				PERFORM pg_notify('ozy_collections', OLD.name);
+			END IF;
+			IF TG_OP <> 'DELETE' THEN
+				PERFORM pg_notify('ozy_collections', NEW.name);
+			END IF;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS tr_collections_changed ON _v_collections`,
+		`CREATE TRIGGER tr_collections_changed
+			AFTER INSERT OR UPDATE OR DELETE ON _v_collections
+			FOR EACH ROW EXECUTE FUNCTION notify_collection_change()`,
+
 		// Migrations History
 		`CREATE TABLE IF NOT EXISTS _v_migrations_history (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
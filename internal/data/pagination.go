@@ -0,0 +1,192 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInvalidQuery is returned when a records query cannot be built from the
+// client supplied parameters. Handlers map it to 400 Bad Request.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Count modes accepted by ListOptions.Count
+const (
+	CountNone      = ""
+	CountExact     = "exact"
+	CountEstimated = "estimated"
+)
+
+// DefaultMaxPageSize caps the number of rows a single list request may return
+const DefaultMaxPageSize = 1000
+
+// ListOptions controls filtering, ordering and pagination for ListRecords
+type ListOptions struct {
+	Filters map[string][]string
+	OrderBy string // "column" or "column.asc" / "column.desc"
+	Limit   int
+	Offset  int
+	Cursor  string // opaque keyset cursor returned as NextCursor by a previous page
+	Count   string // CountNone, CountExact or CountEstimated
+	Trashed string // TrashedExclude, TrashedOnly or TrashedWith
+	Select  Selection
+	// OwnerField and OwnerID restrict rows to ownerID's, as the owner of
+	// UpdateRecord does. Unlike Filters they apply whether or not Select.Role
+	// can read OwnerField.
+	OwnerField string
+	OwnerID    string
+}
+
+// ListResult is a single page of records plus pagination metadata
+type ListResult struct {
+	Records    []map[string]any
+	Total      int64 // -1 when no count was requested
+	NextCursor string
+}
+
+// sortOrder is a validated ORDER BY column and direction
+type sortOrder struct {
+	Column string
+	Desc   bool
+}
+
+func (o sortOrder) direction() string {
+	if o.Desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// parseSortOrder parses the "order" query param (e.g. "created_at.desc").
+// An empty value falls back to created_at DESC.
+func parseSortOrder(orderBy string) (sortOrder, error) {
+	if orderBy == "" {
+		return sortOrder{Column: "created_at", Desc: true}, nil
+	}
+
+	col, dir, _ := strings.Cut(orderBy, ".")
+	if !IsValidIdentifier(col) {
+		return sortOrder{}, fmt.Errorf("%w: invalid order column %q", ErrInvalidQuery, col)
+	}
+
+	switch strings.ToLower(dir) {
+	case "", "asc":
+		return sortOrder{Column: col}, nil
+	case "desc":
+		return sortOrder{Column: col, Desc: true}, nil
+	default:
+		return sortOrder{}, fmt.Errorf("%w: invalid order direction %q", ErrInvalidQuery, dir)
+	}
+}
+
+// orderFor resolves the sort order of a list over a relation with the given
+// columns. Relations without created_at (e.g. views) default to id DESC.
+func orderFor(orderBy string, columns map[string]string) (sortOrder, error) {
+	if _, ok := columns["created_at"]; !ok && orderBy == "" {
+		return sortOrder{Column: "id", Desc: true}, nil
+	}
+
+	order, err := parseSortOrder(orderBy)
+	if err != nil {
+		return sortOrder{}, err
+	}
+	if _, ok := columns[order.Column]; !ok {
+		return sortOrder{}, fmt.Errorf("%w: unknown order column %q", ErrInvalidQuery, order.Column)
+	}
+	return order, nil
+}
+
+// keysetCursor is the decoded form of an opaque pagination cursor. It pins the
+// sort order it was issued for so it can't be replayed against another one.
+type keysetCursor struct {
+	Column string  `json:"c"`
+	Desc   bool    `json:"d,omitempty"`
+	Value  *string `json:"v"`
+	ID     string  `json:"id"`
+}
+
+func encodeCursor(order sortOrder, value, id any) string {
+	cur := keysetCursor{
+		Column: order.Column,
+		Desc:   order.Desc,
+		Value:  cursorText(value),
+	}
+	if id := cursorText(id); id != nil {
+		cur.ID = *id
+	}
+
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(token string, order sortOrder) (keysetCursor, error) {
+	var cur keysetCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cur, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if err := json.Unmarshal(raw, &cur); err != nil || cur.ID == "" {
+		return cur, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if cur.Column != order.Column || cur.Desc != order.Desc {
+		return cur, fmt.Errorf("%w: cursor was issued for a different order", ErrInvalidQuery)
+	}
+
+	return cur, nil
+}
+
+// cursorText renders a column value in Postgres text input format so it can be
+// sent back as a query parameter regardless of the column type.
+func cursorText(v any) *string {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		s = val
+	case [16]byte:
+		s = fmt.Sprintf("%x-%x-%x-%x-%x", val[0:4], val[4:6], val[6:8], val[8:10], val[10:16])
+	case time.Time:
+		s = val.Format(time.RFC3339Nano)
+	case pgtype.Numeric:
+		b, err := val.MarshalJSON()
+		if err != nil {
+			return nil
+		}
+		s = string(b)
+	default:
+		s = fmt.Sprint(val)
+	}
+	return &s
+}
+
+// keysetClause builds the WHERE fragment that starts a page right after the
+// cursor row. Postgres sorts NULLs last for ASC and first for DESC, so NULL
+// cursor values need their own branch.
+func keysetClause(order sortOrder, cur keysetCursor, argIdx int) (string, []any) {
+	col := order.Column
+	op := ">"
+	if order.Desc {
+		op = "<"
+	}
+
+	if cur.Value == nil {
+		clause := fmt.Sprintf("(%s IS NULL AND id %s $%d)", col, op, argIdx)
+		if order.Desc {
+			clause = fmt.Sprintf("(%s OR %s IS NOT NULL)", clause, col)
+		}
+		return clause, []any{cur.ID}
+	}
+
+	clause := fmt.Sprintf("(%s, id) %s ($%d, $%d)", col, op, argIdx, argIdx+1)
+	if !order.Desc {
+		clause = fmt.Sprintf("(%s OR %s IS NULL)", clause, col)
+	}
+	return clause, []any{*cur.Value, cur.ID}
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    sortOrder
+		wantErr bool
+	}{
+		{"Default", "", sortOrder{Column: "created_at", Desc: true}, false},
+		{"Bare column", "title", sortOrder{Column: "title"}, false},
+		{"Ascending", "title.asc", sortOrder{Column: "title"}, false},
+		{"Descending", "title.desc", sortOrder{Column: "title", Desc: true}, false},
+		{"Bad direction", "title.sideways", sortOrder{}, true},
+		{"Injection", "title;drop table x", sortOrder{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSortOrder(tt.input)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuery))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	order := sortOrder{Column: "created_at", Desc: true}
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	id := [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+
+	token := encodeCursor(order, ts, id)
+
+	cur, err := decodeCursor(token, order)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678-9abc-def0-1234-56789abcdef0", cur.ID)
+	if assert.NotNil(t, cur.Value) {
+		assert.Equal(t, "2026-01-02T03:04:05.123456Z", *cur.Value)
+	}
+
+	t.Run("Rejects different order", func(t *testing.T) {
+		_, err := decodeCursor(token, sortOrder{Column: "created_at"})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+	})
+
+	t.Run("Rejects garbage", func(t *testing.T) {
+		_, err := decodeCursor("not-a-cursor!", order)
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+	})
+}
+
+func TestCursorIDTypes(t *testing.T) {
+	order := sortOrder{Column: "id", Desc: true}
+	for id, want := range map[any]string{int64(42): "42", "01J9Z3R4X7K2M8N5P6Q7R8S9T0": "01J9Z3R4X7K2M8N5P6Q7R8S9T0"} {
+		cur, err := decodeCursor(encodeCursor(order, id, id), order)
+		assert.NoError(t, err)
+		assert.Equal(t, want, cur.ID)
+	}
+}
+
+func TestOrderFor(t *testing.T) {
+	table := map[string]string{"id": "uuid", "title": "text", "created_at": "timestamp with time zone"}
+	view := map[string]string{"id": "uuid", "total": "numeric"}
+
+	order, err := orderFor("", table)
+	assert.NoError(t, err)
+	assert.Equal(t, sortOrder{Column: "created_at", Desc: true}, order)
+
+	// Views without created_at fall back to id
+	order, err = orderFor("", view)
+	assert.NoError(t, err)
+	assert.Equal(t, sortOrder{Column: "id", Desc: true}, order)
+
+	order, err = orderFor("total.asc", view)
+	assert.NoError(t, err)
+	assert.Equal(t, sortOrder{Column: "total"}, order)
+
+	_, err = orderFor("created_at", view)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestKeysetClause(t *testing.T) {
+	v := "b"
+
+	clause, args := keysetClause(sortOrder{Column: "title"}, keysetCursor{Value: &v, ID: "x"}, 3)
+	assert.Equal(t, "((title, id) > ($3, $4) OR title IS NULL)", clause)
+	assert.Equal(t, []any{"b", "x"}, args)
+
+	clause, args = keysetClause(sortOrder{Column: "title", Desc: true}, keysetCursor{Value: &v, ID: "x"}, 1)
+	assert.Equal(t, "(title, id) < ($1, $2)", clause)
+	assert.Equal(t, []any{"b", "x"}, args)
+
+	clause, args = keysetClause(sortOrder{Column: "title", Desc: true}, keysetCursor{ID: "x"}, 1)
+	assert.Equal(t, "((title IS NULL AND id < $1) OR title IS NOT NULL)", clause)
+	assert.Equal(t, []any{"x"}, args)
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Primary key strategies of a table collection
+const (
+	IDUUIDv4    = "uuidv4"    // random UUID, the default
+	IDUUIDv7    = "uuidv7"    // time-ordered UUID, so inserts append to the index
+	IDULID      = "ulid"      // time-ordered, lexicographically sortable text
+	IDBigserial = "bigserial" // 64-bit identity sequence
+	IDCustom    = "custom"    // text supplied by the client on insert
+)
+
+// idStrategies maps each strategy to the field type of its ids and the id
+// column it creates
+var idStrategies = map[string]struct{ fieldType, column string }{
+	IDUUIDv4:    {"uuid", "id UUID PRIMARY KEY DEFAULT gen_random_uuid()"},
+	IDUUIDv7:    {"uuid", "id UUID PRIMARY KEY DEFAULT gen_uuid_v7()"},
+	IDULID:      {"text", "id TEXT PRIMARY KEY DEFAULT gen_ulid()"},
+	IDBigserial: {"int8", "id INT8 GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY"},
+	IDCustom:    {"text", "id TEXT PRIMARY KEY CHECK (id <> '')"},
+}
+
+// ValidIDStrategy reports whether strategy names a primary key strategy; ""
+// picks the default
+func ValidIDStrategy(strategy string) bool {
+	_, ok := idStrategies[strategy]
+	return ok || strategy == ""
+}
+
+// IDFieldType returns the field type of the ids a strategy generates, e.g.
+// "uuid" or "int8"
+func IDFieldType(strategy string) string {
+	if s, ok := idStrategies[strategy]; ok {
+		return s.fieldType
+	}
+	return "uuid"
+}
+
+// idTypeSQL returns the column type of ids of the given field type
+func idTypeSQL(fieldType string) (string, bool) {
+	switch fieldType {
+	case "uuid", "int2", "int4", "int8", "text", "varchar":
+		return TypeMapping[fieldType], true
+	}
+	return "", false
+}
+
+// primaryKeySQL renders the id column of a strategy
+func primaryKeySQL(strategy string) (string, error) {
+	if strategy == "" {
+		strategy = IDUUIDv4
+	}
+	s, ok := idStrategies[strategy]
+	if !ok {
+		return "", fmt.Errorf("%w: id_strategy must be 'uuidv4', 'uuidv7', 'ulid', 'bigserial' or 'custom'", ErrInvalidSchema)
+	}
+	return s.column, nil
+}
+
+// rowQuerier runs single-row queries on a pool or in a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// idColumnType returns the field type of a table's id column, or "" when the
+// table doesn't exist (yet)
+func idColumnType(ctx context.Context, q rowQuerier, tableName string) (string, error) {
+	var pgType string
+	err := q.QueryRow(ctx, `
+		SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attname = 'id' AND NOT attisdropped
+	`, QuoteTable(tableName)).Scan(&pgType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return mapPostgresTypeToOzy(pgType), nil
+}
+
+// ResolveRelationFields records the id type of the collection each relation
+// points to, which its column or join table is created with. Relations of
+// tableName to itself use the ids of idStrategy until the table exists.
+func (db *DB) ResolveRelationFields(ctx context.Context, tableName, idStrategy string, fields []FieldSchema) error {
+	for i, field := range fields {
+		if strings.ToLower(field.Type) != FieldRelation || !ValidCollectionName(field.Target) {
+			continue
+		}
+		idType, err := idColumnType(ctx, db.Pool, field.Target)
+		if err != nil {
+			return err
+		}
+		if idType == "" && field.Target == tableName {
+			idType = IDFieldType(idStrategy)
+		}
+		fields[i].IDType = idType
+	}
+	return nil
+}
+
+// clientIDs reports whether a collection takes the ids of its records from the
+// client instead of generating them
+func clientIDs(ctx context.Context, tx pgx.Tx, collectionName string) (bool, error) {
+	var custom bool
+	err := tx.QueryRow(ctx, "SELECT COALESCE(id_strategy, '') = $2 FROM _v_collections WHERE name = $1", collectionName, IDCustom).Scan(&custom)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return custom, err
+}
+
+// syncIDSequence moves the identity sequence of a bigserial collection past
+// ids that were written explicitly, e.g. by an import. Other collections have
+// no sequence and are left alone.
+func syncIDSequence(ctx context.Context, tx pgx.Tx, collectionName string) error {
+	var seq *string
+	err := tx.QueryRow(ctx, "SELECT pg_get_serial_sequence($1, 'id')", QuoteTable(collectionName)).Scan(&seq)
+	if err != nil || seq == nil {
+		return err
+	}
+	// #nosec G201
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		SELECT setval($1, GREATEST(max(id), COALESCE(pg_sequence_last_value($1), 0)))
+		FROM %s HAVING max(id) > 0
+	`, QuoteTable(collectionName)), *seq)
+	return err
+}
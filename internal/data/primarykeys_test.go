@@ -0,0 +1,59 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCreateTableSQLIDStrategy(t *testing.T) {
+	tests := map[string]string{
+		"":          "id UUID PRIMARY KEY DEFAULT gen_random_uuid()",
+		IDUUIDv4:    "id UUID PRIMARY KEY DEFAULT gen_random_uuid()",
+		IDUUIDv7:    "id UUID PRIMARY KEY DEFAULT gen_uuid_v7()",
+		IDULID:      "id TEXT PRIMARY KEY DEFAULT gen_ulid()",
+		IDBigserial: "id INT8 GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY",
+		IDCustom:    "id TEXT PRIMARY KEY CHECK (id <> '')",
+	}
+	for strategy, want := range tests {
+		sql, err := BuildCreateTableSQL("events", strategy, []FieldSchema{{Name: "kind", Type: "text"}})
+		assert.NoError(t, err, strategy)
+		assert.Contains(t, sql, want, strategy)
+	}
+
+	_, err := BuildCreateTableSQL("events", "serial", []FieldSchema{{Name: "kind", Type: "text"}})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+	assert.False(t, ValidIDStrategy("serial"))
+	assert.True(t, ValidIDStrategy(""))
+}
+
+func TestRelationIDTypes(t *testing.T) {
+	got, err := columnTypeSQL(FieldSchema{Name: "event", Type: "relation", Target: "events", IDType: "int8"})
+	assert.NoError(t, err)
+	assert.Equal(t, "INT8 REFERENCES \"public\".\"events\"(id) ON DELETE SET NULL", got)
+
+	// Files always point at storage objects, which have UUIDs
+	got, err = columnTypeSQL(FieldSchema{Name: "cover", Type: "file", IDType: "int8"})
+	assert.NoError(t, err)
+	assert.Equal(t, "UUID REFERENCES \"public\".\"_v_storage_objects\"(id) ON DELETE SET NULL", got)
+
+	_, err = columnTypeSQL(FieldSchema{Name: "event", Type: "relation", Target: "events", IDType: "jsonb"})
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+
+	sql, err := BuildCreateTableSQL("events", IDBigserial, []FieldSchema{
+		{Name: "labels", Type: "relation", Target: "labels", Multiple: true, IDType: "text"},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "source_id INT8 NOT NULL REFERENCES \"public\".\"events\"(id) ON DELETE CASCADE")
+	assert.Contains(t, sql, "target_id TEXT NOT NULL REFERENCES \"public\".\"labels\"(id) ON DELETE CASCADE")
+}
+
+func TestLinkIDsNumbers(t *testing.T) {
+	ids, err := linkIDs("events", []any{42.0, int64(7), "9"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"42", "7", "9"}, ids)
+
+	_, err = linkIDs("events", []any{1.5})
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
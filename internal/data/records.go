@@ -2,111 +2,423 @@ package data
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
 // InsertRecord inserts a record into a dynamic collection table
 func (db *DB) InsertRecord(ctx context.Context, collectionName string, data map[string]any) (string, error) {
-	if !IsValidIdentifier(collectionName) {
+	if !ValidCollectionName(collectionName) {
 		return "", fmt.Errorf("invalid collection name: %s", collectionName)
 	}
 
 	var id string
 	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		var columns []string
-		var placeholders []string
-		var values []any
-		i := 1
+		var err error
+		id, err = insertRecord(ctx, tx, collectionName, data)
+		return err
+	})
 
-		for col, val := range data {
-			if !IsValidIdentifier(col) {
-				continue
-			}
+	return id, err
+}
+
+func insertRecord(ctx context.Context, tx pgx.Tx, collectionName string, data map[string]any) (string, error) {
+	links, err := linkFields(ctx, tx, collectionName)
+	if err != nil {
+		return "", err
+	}
+	data, linkValues := splitLinks(data, links)
+	// Only collections with custom ids take the one the caller supplies
+	keepID, err := clientIDs(ctx, tx, collectionName)
+	if err != nil {
+		return "", err
+	}
+
+	var columns []string
+	var placeholders []string
+	var values []any
+	i := 1
+
+	for col, val := range data {
+		if !IsValidIdentifier(col) {
+			continue
+		}
+		if (col == "id" && !keepID) || col == "created_at" || col == "updated_at" || col == "deleted_at" {
+			continue
+		}
+
+		columns = append(columns, col)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
+		values = append(values, val)
+		i++
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id::text",
+		QuoteTable(collectionName), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if len(columns) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING id::text", QuoteTable(collectionName))
+	}
+
+	var id string
+	if err := tx.QueryRow(ctx, query, values...).Scan(&id); err != nil {
+		return "", err
+	}
+	return id, writeLinks(ctx, tx, collectionName, id, linkValues)
+}
+
+// Internal aliases used to carry keyset values through custom projections
+const (
+	cursorValueKey = "__cursor_value"
+	cursorIDKey    = "__cursor_id"
+)
+
+// UpsertResult reports what an upsert did to the conflicting row
+type UpsertResult struct {
+	ID       string
+	Inserted bool // false when an existing row was updated
+	Ignored  bool // true when a duplicate was skipped
+}
+
+// recordOwner is the RLS owner a single-record write is restricted to. The
+// zero value doesn't restrict writes.
+type recordOwner struct {
+	Field, ID string
+}
+
+// clause returns the owner condition for rows of alias ("" for the bare table)
+func (o recordOwner) clause(alias string, argIdx int) (string, []any) {
+	if o.Field == "" || o.ID == "" {
+		return "", nil
+	}
+	if !IsValidIdentifier(o.Field) {
+		// An owner column we can't name matches nothing rather than everything
+		return " AND FALSE", nil
+	}
+	column := o.Field
+	if alias != "" {
+		column = alias + "." + o.Field
+	}
+	return fmt.Sprintf(" AND %s = $%d", column, argIdx), []any{o.ID}
+}
+
+// UpsertRecord inserts a record, resolving conflicts on conflictCols with
+// ON CONFLICT DO UPDATE (merge) or DO NOTHING when ignoreDuplicates is set.
+// Unlike InsertRecord, a caller supplied id is kept so rows can be matched by primary key.
+// A merge only overwrites live rows owned by ownerID when ownerField is set;
+// ErrRecordNotFound is returned when the conflicting row is any other.
+// Conflict columns hidden from role are unknown, see Selection.Role.
+func (db *DB) UpsertRecord(ctx context.Context, collectionName string, data map[string]any, conflictCols []string, ignoreDuplicates bool, ownerField, ownerID, role string) (UpsertResult, error) {
+	var res UpsertResult
+	if !ValidCollectionName(collectionName) {
+		return res, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		var err error
+		res, err = upsertRecord(ctx, tx, collectionName, data, conflictCols, ignoreDuplicates, recordOwner{ownerField, ownerID}, role)
+		return err
+	})
+
+	return res, err
+}
+
+func upsertRecord(ctx context.Context, tx pgx.Tx, collectionName string, data map[string]any, conflictCols []string, ignoreDuplicates bool, owner recordOwner, role string) (UpsertResult, error) {
+	var res UpsertResult
+	if len(conflictCols) == 0 {
+		conflictCols = []string{"id"}
+	}
+
+	columns, err := tableColumns(ctx, tx, collectionName)
+	if err != nil {
+		return res, err
+	}
+	links, err := linkFields(ctx, tx, collectionName)
+	if err != nil {
+		return res, err
+	}
+	data, linkValues := splitLinks(data, links)
+	// Whether a row conflicts would reveal the values of hidden columns
+	readable, err := readableColumns(ctx, tx, collectionName, role, columns)
+	if err != nil {
+		return res, err
+	}
+
+	conflict := make(map[string]bool, len(conflictCols))
+	for _, col := range conflictCols {
+		if _, ok := readable[col]; !ok {
+			return res, fmt.Errorf("%w: unknown on_conflict column %q", ErrInvalidQuery, col)
+		}
+		conflict[col] = true
+	}
+
+	var cols, placeholders, updates []string
+	var values []any
+	for _, col := range sortedKeys(data) {
+		if col == "created_at" || col == "updated_at" || col == "deleted_at" {
+			continue
+		}
+		if _, ok := columns[col]; !ok {
+			return res, fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, col)
+		}
+		values = append(values, data[col])
+		cols = append(cols, col)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(values)))
+		if !conflict[col] {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", QuoteIdent(col), QuoteIdent(col)))
+		}
+	}
+	if len(cols) == 0 {
+		return res, fmt.Errorf("%w: no columns to write", ErrInvalidQuery)
+	}
+
+	action := "DO NOTHING"
+	if !ignoreDuplicates {
+		updates = append(updates, "updated_at = NOW()")
+		// Trashed rows and rows of other owners are never overwritten
+		where := "TRUE"
+		if _, ok := columns["deleted_at"]; ok {
+			where = "t0.deleted_at IS NULL"
+		}
+		ownerWhere, ownerArgs := owner.clause("t0", len(values)+1)
+		values = append(values, ownerArgs...)
+		action = fmt.Sprintf("DO UPDATE SET %s WHERE %s%s", strings.Join(updates, ", "), where, ownerWhere)
+	}
+
+	// xmax is 0 only for freshly inserted tuples
+	query := fmt.Sprintf("INSERT INTO %s AS t0 (%s) VALUES (%s) ON CONFLICT (%s) %s RETURNING t0.id::text, (t0.xmax = 0)",
+		QuoteTable(collectionName), quoteIdentList(cols), strings.Join(placeholders, ", "),
+		quoteIdentList(conflictCols), action)
+
+	err = tx.QueryRow(ctx, query, values...).Scan(&res.ID, &res.Inserted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if !ignoreDuplicates {
+			return res, ErrRecordNotFound
+		}
+		res.Ignored = true
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	if _, ok := data["id"]; ok {
+		if err := syncIDSequence(ctx, tx, collectionName); err != nil {
+			return res, err
+		}
+	}
+	return res, writeLinks(ctx, tx, collectionName, res.ID, linkValues)
+}
+
+// UpdateRecords applies the same changes to every live row matching filters and
+// returns the number of rows affected. At least one filter is required. Rows
+// are restricted to ownerID's as in UpdateRecord, and filters may only name
+// the columns role can read.
+func (db *DB) UpdateRecords(ctx context.Context, collectionName string, filters map[string][]string, data map[string]any, ownerField, ownerID, role string) (int64, error) {
+	if !ValidCollectionName(collectionName) {
+		return 0, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	var affected int64
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+
+		var updates []string
+		var values []any
+		for _, col := range sortedKeys(data) {
 			if col == "id" || col == "created_at" || col == "updated_at" || col == "deleted_at" {
 				continue
 			}
+			if _, ok := columns[col]; !ok {
+				return fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, col)
+			}
+			values = append(values, data[col])
+			updates = append(updates, fmt.Sprintf("%s = $%d", QuoteIdent(col), len(values)))
+		}
+		if len(updates) == 0 {
+			return fmt.Errorf("%w: no columns to update", ErrInvalidQuery)
+		}
 
-			columns = append(columns, col)
-			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-			values = append(values, val)
-			i++
+		readable, err := readableColumns(ctx, tx, collectionName, role, columns)
+		if err != nil {
+			return err
 		}
+		where, args, err := requiredFilterWhere(filters, readable, len(values)+1)
+		if err != nil {
+			return err
+		}
+		values = append(values, args...)
+		ownerWhere, ownerArgs := recordOwner{ownerField, ownerID}.clause("", len(values)+1)
+		where += ownerWhere
+		values = append(values, ownerArgs...)
 
-		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id",
-			collectionName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+		query := fmt.Sprintf("UPDATE %s SET %s, updated_at = NOW() WHERE %s",
+			QuoteTable(collectionName), strings.Join(updates, ", "), where)
 
-		return tx.QueryRow(ctx, query, values...).Scan(&id)
+		tag, err := tx.Exec(ctx, query, values...)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
 	})
 
-	return id, err
+	return affected, err
 }
 
-// ListRecords fetches all records with filters and sorting, respecting RLS if configured in DB
-func (db *DB) ListRecords(ctx context.Context, collectionName string, filters map[string][]string, orderBy string) ([]map[string]any, error) {
-	if !IsValidIdentifier(collectionName) {
+// DeleteRecords soft-deletes every live row matching filters and returns the
+// number of rows affected. Filters and owner work as in UpdateRecords.
+func (db *DB) DeleteRecords(ctx context.Context, collectionName string, filters map[string][]string, ownerField, ownerID, role string) (int64, error) {
+	if !ValidCollectionName(collectionName) {
+		return 0, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	var affected int64
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+
+		columns, err = readableColumns(ctx, tx, collectionName, role, columns)
+		if err != nil {
+			return err
+		}
+		where, args, err := requiredFilterWhere(filters, columns, 1)
+		if err != nil {
+			return err
+		}
+		ownerWhere, ownerArgs := recordOwner{ownerField, ownerID}.clause("", len(args)+1)
+		where += ownerWhere
+		args = append(args, ownerArgs...)
+
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = NOW() WHERE %s", QuoteTable(collectionName), where)
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+
+	return affected, err
+}
+
+// requiredFilterWhere builds the WHERE clause for bulk writes, refusing to
+// produce one that would match the whole table.
+func requiredFilterWhere(filters map[string][]string, columns map[string]string, argIdx int) (string, []any, error) {
+	clauses, args, err := buildFilterClauses(filters, columns, argIdx)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(clauses) == 0 {
+		return "", nil, fmt.Errorf("%w: a filter is required for bulk writes", ErrInvalidQuery)
+	}
+
+	clauses = append([]string{"deleted_at IS NULL"}, clauses...)
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ListRecords fetches a page of records with filters and sorting, respecting RLS if configured in DB.
+// Pages are addressed either by Limit/Offset or by an opaque keyset Cursor over (order column, id).
+func (db *DB) ListRecords(ctx context.Context, collectionName string, opts ListOptions) (*ListResult, error) {
+	if !ValidCollectionName(collectionName) {
 		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
 	}
 
-	var results []map[string]any
+	var order sortOrder
+	result := &ListResult{Total: -1}
 	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		whereClauses := []string{"deleted_at IS NULL"}
-		var queryArgs []any
-		argIdx := 1
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns, err = readableColumns(ctx, tx, collectionName, opts.Select.Role, columns)
+		if err != nil {
+			return err
+		}
+		order, err = orderFor(opts.OrderBy, columns)
+		if err != nil {
+			return err
+		}
 
-		for col, values := range filters {
-			if col == "order" || col == "select" || col == "limit" || col == "offset" {
-				continue
-			}
-			if !IsValidIdentifier(col) {
-				continue
+		var cursor *keysetCursor
+		if opts.Cursor != "" {
+			cur, err := decodeCursor(opts.Cursor, order)
+			if err != nil {
+				return err
 			}
+			cursor = &cur
+		}
 
-			for _, valStr := range values {
-				parts := strings.SplitN(valStr, ".", 2)
-				op := "eq"
-				val := valStr
-				if len(parts) == 2 {
-					op = parts[0]
-					val = parts[1]
-				}
+		whereClauses, queryArgs, err := buildFilterClauses(opts.Filters, columns, 1)
+		if err != nil {
+			return err
+		}
+		trashed, err := trashedClause(opts.Trashed, columns)
+		if err != nil {
+			return err
+		}
+		where := strings.Join(append([]string{trashed}, whereClauses...), " AND ")
+		ownerWhere, ownerArgs := recordOwner{opts.OwnerField, opts.OwnerID}.clause("", len(queryArgs)+1)
+		where += ownerWhere
+		queryArgs = append(queryArgs, ownerArgs...)
+
+		// Totals ignore the cursor so they describe the whole filtered set
+		switch opts.Count {
+		case CountExact:
+			countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", QuoteTable(collectionName), where)
+			if err := tx.QueryRow(ctx, countQuery, queryArgs...).Scan(&result.Total); err != nil {
+				return err
+			}
+		case CountEstimated:
+			total, err := estimateRowCount(ctx, tx, fmt.Sprintf("SELECT 1 FROM %s WHERE %s", QuoteTable(collectionName), where), queryArgs)
+			if err != nil {
+				return err
+			}
+			result.Total = total
+		}
 
-				sqlOp := "="
-				switch op {
-				case "gt":
-					sqlOp = ">"
-				case "gte":
-					sqlOp = ">="
-				case "lt":
-					sqlOp = "<"
-				case "lte":
-					sqlOp = "<="
-				case "neq":
-					sqlOp = "!="
-				case "like":
-					sqlOp = "ILIKE"
-					val = "%" + val + "%"
-				}
+		if cursor != nil {
+			clause, args := keysetClause(order, *cursor, len(queryArgs)+1)
+			where += " AND " + clause
+			queryArgs = append(queryArgs, args...)
+		}
 
-				whereClauses = append(whereClauses, fmt.Sprintf("%s %s $%d", col, sqlOp, argIdx))
-				queryArgs = append(queryArgs, val)
-				argIdx++
-			}
+		projection, err := buildProjection(ctx, tx, collectionName, opts.Select)
+		if err != nil {
+			return err
+		}
+		// Custom projections may drop the keyset columns, so carry them separately
+		if opts.Select.Expr != "" {
+			projection += fmt.Sprintf(", t0.%s AS %s, t0.id AS %s", order.Column, cursorValueKey, cursorIDKey)
 		}
 
-		query := fmt.Sprintf("SELECT * FROM %s WHERE %s", collectionName, strings.Join(whereClauses, " AND "))
+		query := fmt.Sprintf("SELECT %s FROM %s t0 WHERE %s ORDER BY t0.%s %s, t0.id %s",
+			projection, QuoteTable(collectionName), where, order.Column, order.direction(), order.direction())
 
-		if orderBy != "" {
-			// Basic sanitization: only allow alphanumeric and underscores
-			if IsValidIdentifier(strings.ReplaceAll(orderBy, ".", "")) {
-				query += " ORDER BY " + strings.ReplaceAll(orderBy, ".", " ")
-			} else {
-				query += " ORDER BY created_at DESC"
-			}
-		} else {
-			query += " ORDER BY created_at DESC"
+		// Fetch one extra row to know whether a next page exists
+		if opts.Limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", opts.Limit+1)
+		}
+		if opts.Offset > 0 && cursor == nil {
+			query += fmt.Sprintf(" OFFSET %d", opts.Offset)
 		}
 
 		rows, err := tx.Query(ctx, query, queryArgs...)
@@ -115,18 +427,79 @@ func (db *DB) ListRecords(ctx context.Context, collectionName string, filters ma
 		}
 		defer rows.Close()
 
-		results, err = rowsToMaps(rows)
+		result.Records, err = rowsToMaps(rows)
 		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Limit > 0 && len(result.Records) > opts.Limit {
+		result.Records = result.Records[:opts.Limit]
+		last := result.Records[opts.Limit-1]
+		if opts.Select.Expr != "" {
+			result.NextCursor = encodeCursor(order, last[cursorValueKey], last[cursorIDKey])
+		} else {
+			result.NextCursor = encodeCursor(order, last[order.Column], last["id"])
+		}
+	}
+
+	if opts.Select.Expr != "" {
+		for _, rec := range result.Records {
+			delete(rec, cursorValueKey)
+			delete(rec, cursorIDKey)
+		}
+	}
+
+	return result, nil
+}
+
+// estimateRowCount asks the planner for its row estimate instead of scanning the table
+func estimateRowCount(ctx context.Context, tx pgx.Tx, query string, args []any) (int64, error) {
+	var plan []byte
+	if err := tx.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explain); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(explain) == 0 {
+		return 0, fmt.Errorf("empty query plan")
+	}
 
-	return results, err
+	return int64(explain[0].Plan.Rows), nil
 }
 
-// GetRecord fetches a single record, respecting RLS
-func (db *DB) GetRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string) (map[string]any, error) {
+// GetRecord fetches a single record, respecting RLS. Embedded relations in sel
+// are resolved inside the same transaction so related rows obey RLS as well.
+func (db *DB) GetRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string, sel Selection) (map[string]any, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
 	var record map[string]any
 	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1 AND deleted_at IS NULL", collectionName)
+		projection, err := buildProjection(ctx, tx, collectionName, sel)
+		if err != nil {
+			return err
+		}
+
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		trashed, err := trashedClause(TrashedExclude, columns)
+		if err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("SELECT %s FROM %s t0 WHERE t0.id = $1 AND %s", projection, QuoteTable(collectionName), trashed)
 		rows, err := tx.Query(ctx, query, id)
 		if err != nil {
 			return err
@@ -138,7 +511,7 @@ func (db *DB) GetRecord(ctx context.Context, collectionName, id string, ownerFie
 			return err
 		}
 		if len(records) == 0 {
-			return fmt.Errorf("record not found")
+			return ErrRecordNotFound
 		}
 		record = records[0]
 		return nil
@@ -146,51 +519,115 @@ func (db *DB) GetRecord(ctx context.Context, collectionName, id string, ownerFie
 	return record, err
 }
 
-// UpdateRecord updates a record, respecting RLS
-func (db *DB) UpdateRecord(ctx context.Context, collectionName, id string, data map[string]any, ownerField, ownerID string) error {
+// UpdateRecord updates a record, respecting RLS. When versions is non-empty the
+// update only applies if the record's updated_at matches one of them.
+func (db *DB) UpdateRecord(ctx context.Context, collectionName, id string, data map[string]any, ownerField, ownerID string, versions []time.Time) error {
 	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		var updates []string
-		var values []any
-		i := 1
+		return updateRecord(ctx, tx, collectionName, id, data, recordOwner{ownerField, ownerID}, versions)
+	})
+}
 
-		for col, val := range data {
-			if !IsValidIdentifier(col) {
-				continue
-			}
-			if col == "id" || col == "created_at" || col == "updated_at" {
-				continue
-			}
-			updates = append(updates, fmt.Sprintf("%s = $%d", col, i))
-			values = append(values, val)
-			i++
+func updateRecord(ctx context.Context, tx pgx.Tx, collectionName, id string, data map[string]any, owner recordOwner, versions []time.Time) error {
+	links, err := linkFields(ctx, tx, collectionName)
+	if err != nil {
+		return err
+	}
+	data, linkValues := splitLinks(data, links)
+
+	var updates []string
+	var values []any
+	i := 1
+
+	for col, val := range data {
+		if !IsValidIdentifier(col) {
+			continue
+		}
+		if col == "id" || col == "created_at" || col == "updated_at" {
+			continue
 		}
+		updates = append(updates, fmt.Sprintf("%s = $%d", col, i))
+		values = append(values, val)
+		i++
+	}
 
-		if len(updates) == 0 {
-			return nil
+	versionWhere, versionArgs, err := versionClause(ctx, tx, collectionName, versions, i+1)
+	if err != nil {
+		return err
+	}
+	ownerWhere, ownerArgs := owner.clause("", i+1+len(versionArgs))
+	where := fmt.Sprintf("id = $%d AND deleted_at IS NULL%s%s", i, versionWhere, ownerWhere)
+	values = append(values, id)
+	values = append(values, versionArgs...)
+	values = append(values, ownerArgs...)
+
+	// An empty update still has to find the record in the expected version
+	if len(updates) == 0 && len(linkValues) == 0 {
+		var found bool
+		query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s)", QuoteTable(collectionName), where)
+		if err := tx.QueryRow(ctx, query, values...).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return missedWriteError(ctx, tx, collectionName, id, "deleted_at IS NULL", owner, versions)
 		}
+		return nil
+	}
 
-		query := fmt.Sprintf("UPDATE %s SET %s, updated_at = NOW() WHERE id = $%d",
-			collectionName, strings.Join(updates, ", "), i)
-		values = append(values, id)
+	// Trashed rows must be restored before they can be edited. Link-only
+	// updates still bump updated_at so versions see them.
+	updates = append(updates, "updated_at = NOW()")
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", QuoteTable(collectionName), strings.Join(updates, ", "), where)
 
-		_, err := tx.Exec(ctx, query, values...)
+	tag, err := tx.Exec(ctx, query, values...)
+	if err != nil {
 		return err
-	})
+	}
+	if tag.RowsAffected() == 0 {
+		return missedWriteError(ctx, tx, collectionName, id, "deleted_at IS NULL", owner, versions)
+	}
+	return writeLinks(ctx, tx, collectionName, id, linkValues)
 }
 
-// DeleteRecord soft-deletes a record, respecting RLS
-func (db *DB) DeleteRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string) error {
+// DeleteRecord soft-deletes a record, respecting RLS. Versions work as in UpdateRecord.
+func (db *DB) DeleteRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string, versions []time.Time) error {
 	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		query := fmt.Sprintf("UPDATE %s SET deleted_at = NOW() WHERE id = $1", collectionName)
-		_, err := tx.Exec(ctx, query, id)
-		return err
+		return deleteRecord(ctx, tx, collectionName, id, recordOwner{ownerField, ownerID}, versions)
 	})
 }
 
+func deleteRecord(ctx context.Context, tx pgx.Tx, collectionName, id string, owner recordOwner, versions []time.Time) error {
+	versionWhere, versionArgs, err := versionClause(ctx, tx, collectionName, versions, 2)
+	if err != nil {
+		return err
+	}
+	ownerWhere, ownerArgs := owner.clause("", 2+len(versionArgs))
+
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL%s%s", QuoteTable(collectionName), versionWhere, ownerWhere)
+	args := append(append([]any{id}, versionArgs...), ownerArgs...)
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return missedWriteError(ctx, tx, collectionName, id, "deleted_at IS NULL", owner, versions)
+	}
+	return nil
+}
+
 func rowsToMaps(rows pgx.Rows) ([]map[string]any, error) {
 	fieldDescriptions := rows.FieldDescriptions()
 	var results []map[string]any
 
+	// pgx has no codec for extension types such as pgvector's vector and
+	// returns their text form
+	unknown := make([]bool, len(fieldDescriptions))
+	if conn := rows.Conn(); conn != nil {
+		for i, fd := range fieldDescriptions {
+			_, known := conn.TypeMap().TypeForOID(fd.DataTypeOID)
+			unknown[i] = !known
+		}
+	}
+
 	for rows.Next() {
 		values, err := rows.Values()
 		if err != nil {
@@ -199,46 +636,16 @@ func rowsToMaps(rows pgx.Rows) ([]map[string]any, error) {
 
 		record := make(map[string]any)
 		for i, fd := range fieldDescriptions {
+			if s, ok := values[i].(string); ok && unknown[i] {
+				if vector, ok := parseVector(s); ok {
+					values[i] = vector
+				}
+			}
 			record[string(fd.Name)] = values[i]
 		}
+		delete(record, SearchVectorColumn)
 		results = append(results, record)
 	}
 
 	return results, rows.Err()
 }
-
-// BulkInsertRecord inserts multiple records
-func (db *DB) BulkInsertRecord(ctx context.Context, collectionName string, records []map[string]any) error {
-	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
-		for _, data := range records {
-			var columns []string
-			var placeholders []string
-			var values []any
-			i := 1
-
-			for col, val := range data {
-				if !IsValidIdentifier(col) {
-					continue
-				}
-				if col == "id" || col == "created_at" || col == "updated_at" {
-					continue
-				}
-				columns = append(columns, col)
-				placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-				values = append(values, val)
-				i++
-			}
-
-			if len(columns) == 0 {
-				continue
-			}
-			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-				collectionName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
-			_, err := tx.Exec(ctx, query, values...)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-}
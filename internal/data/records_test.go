@@ -0,0 +1,29 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordOwnerClause(t *testing.T) {
+	where, args := recordOwner{}.clause("t0", 3)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	where, args = recordOwner{Field: "user_id"}.clause("t0", 3)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	where, args = recordOwner{Field: "user_id", ID: "u1"}.clause("t0", 3)
+	assert.Equal(t, " AND t0.user_id = $3", where)
+	assert.Equal(t, []any{"u1"}, args)
+
+	where, args = recordOwner{Field: "user_id", ID: "u1"}.clause("", 2)
+	assert.Equal(t, " AND user_id = $2", where)
+	assert.Equal(t, []any{"u1"}, args)
+
+	where, args = recordOwner{Field: "user_id; --", ID: "u1"}.clause("", 2)
+	assert.Equal(t, " AND FALSE", where)
+	assert.Empty(t, args)
+}
@@ -0,0 +1,423 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// collectionTriggers prefix the triggers named after the collection they are on
+var collectionTriggers = []string{"tr_notify_", "tr_history_"}
+
+// renamedObject returns the name of an index, constraint or trigger once the
+// table it is named after moves from one name to another. ok is false for
+// names that don't follow <table>_..., which are kept.
+func renamedObject(name, prefix, from, to string) (string, bool, error) {
+	if !strings.HasPrefix(name, prefix+from+"_") && name != prefix+from {
+		return "", false, nil
+	}
+	renamed := prefix + to + strings.TrimPrefix(name, prefix+from)
+	if !IsValidIdentifier(renamed) {
+		return "", false, fmt.Errorf("%w: %s would be renamed to %s, which is too long", ErrInvalidSchema, name, renamed)
+	}
+	return renamed, true, nil
+}
+
+// rewriteWebhookEvents points the events of a webhook ("table" or "table:action",
+// comma separated) at a renamed collection
+func rewriteWebhookEvents(events, from, to string) string {
+	parts := strings.Split(events, ",")
+	for i, part := range parts {
+		table, action, hasAction := strings.Cut(strings.TrimSpace(part), ":")
+		if table != from {
+			continue
+		}
+		parts[i] = to
+		if hasAction {
+			parts[i] += ":" + action
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkCollectionMove verifies that from is a table collection and to is free
+// in the same schema
+func checkCollectionMove(ctx context.Context, tx pgx.Tx, from, to string) error {
+	fromRef, err := ParseTableRef(from)
+	if err != nil {
+		return err
+	}
+	toRef, err := ParseTableRef(to)
+	if err != nil {
+		return err
+	}
+	if fromRef.Schema != toRef.Schema {
+		return fmt.Errorf("%w: collections can only be renamed or cloned within their schema", ErrInvalidSchema)
+	}
+	if strings.HasPrefix(fromRef.Name, "_v_") || strings.HasPrefix(toRef.Name, "_v_") {
+		return fmt.Errorf("%w: system collections can't be renamed or cloned", ErrInvalidSchema)
+	}
+
+	var exists, taken bool
+	var kind string
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE((SELECT relkind IN ('r', 'p') FROM pg_class WHERE oid = to_regclass($2)), FALSE),
+			to_regclass($3) IS NOT NULL,
+			COALESCE((SELECT kind FROM _v_collections WHERE name = $1), 'table')
+	`, from, fromRef.SQL(), toRef.SQL()).Scan(&exists, &taken, &kind)
+	switch {
+	case err != nil:
+		return err
+	case kind != CollectionTable:
+		return fmt.Errorf("%w: only table collections can be renamed or cloned", ErrInvalidSchema)
+	case !exists:
+		return fmt.Errorf("%w: collection %s not found", ErrInvalidSchema, from)
+	case taken:
+		return fmt.Errorf("%w: %s already exists", ErrInvalidSchema, to)
+	}
+	return nil
+}
+
+// renameTableSQL renders the statements that rename a table and the indexes
+// and constraints named after it
+func renameTableSQL(ctx context.Context, tx pgx.Tx, from, to string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT i.relname, TRUE FROM pg_index ix JOIN pg_class i ON i.oid = ix.indexrelid
+		WHERE ix.indrelid = to_regclass($1)
+		UNION ALL
+		SELECT conname, FALSE FROM pg_constraint
+		WHERE conrelid = to_regclass($1) AND contype IN ('c', 'f')
+		ORDER BY 1
+	`, QuoteTable(from))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statements := []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", QuoteTable(from), QuoteIdent(relName(to)))}
+	for rows.Next() {
+		var name string
+		var index bool
+		if err := rows.Scan(&name, &index); err != nil {
+			return nil, err
+		}
+		renamed, ok, err := renamedObject(name, "", relName(from), relName(to))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		// Renaming the index of a primary key or unique constraint renames the constraint too
+		if index {
+			statements = append(statements, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", siblingSQL(from, name), renamed))
+		} else {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME CONSTRAINT %s TO %s", QuoteTable(to), name, renamed))
+		}
+	}
+	return statements, rows.Err()
+}
+
+// RenameCollection renames a table collection in one transaction, along with
+// its join tables, triggers, indexes, constraints, metadata, recorded history,
+// the relations pointing at it and webhook events. It returns the DDL it ran.
+func (db *DB) RenameCollection(ctx context.Context, from, to string) (string, error) {
+	var statements []string
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		if err := checkCollectionMove(ctx, tx, from, to); err != nil {
+			return err
+		}
+
+		joinTables, err := JoinTables(ctx, tx, from)
+		if err != nil {
+			return err
+		}
+		tables := map[string]string{from: to}
+		for _, join := range joinTables {
+			renamed := to + strings.TrimPrefix(join, from)
+			if !ValidCollectionName(renamed) {
+				return fmt.Errorf("%w: %s would be renamed to %s, which is too long", ErrInvalidSchema, join, renamed)
+			}
+			tables[join] = renamed
+		}
+
+		for _, table := range append([]string{from}, joinTables...) {
+			stmts, err := renameTableSQL(ctx, tx, table, tables[table])
+			if err != nil {
+				return err
+			}
+			statements = append(statements, stmts...)
+		}
+
+		for _, prefix := range collectionTriggers {
+			var exists bool
+			trigger := prefix + relName(from)
+			err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = to_regclass($1) AND tgname = $2)",
+				QuoteTable(from), trigger).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			renamed, _, err := renamedObject(trigger, prefix, relName(from), relName(to))
+			if err != nil {
+				return err
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TRIGGER %s ON %s RENAME TO %s", trigger, QuoteTable(to), renamed))
+		}
+
+		for _, stmt := range statements {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+
+		// Metadata refers to collections by name
+		metadata := []string{
+			"UPDATE _v_collections SET name = $2, updated_at = NOW() WHERE name = $1",
+			"UPDATE _v_record_history SET collection = $2 WHERE collection = $1",
+			`UPDATE _v_collections SET schema_def = (
+				SELECT jsonb_agg(CASE WHEN f->>'target' = $1 THEN jsonb_set(f, '{target}', to_jsonb($2::text)) ELSE f END)
+				FROM jsonb_array_elements(schema_def) f
+			), updated_at = NOW()
+			WHERE schema_def @> jsonb_build_array(jsonb_build_object('target', $1::text))`,
+		}
+		for _, sql := range metadata {
+			if _, err := tx.Exec(ctx, sql, from, to); err != nil {
+				return err
+			}
+		}
+		return renameWebhookEvents(ctx, tx, from, to)
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(statements, ";\n") + ";", nil
+}
+
+func renameWebhookEvents(ctx context.Context, tx pgx.Tx, from, to string) error {
+	rows, err := tx.Query(ctx, "SELECT id, events FROM _v_webhooks WHERE events LIKE '%' || $1 || '%'", from)
+	if err != nil {
+		return err
+	}
+	updated, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) ([2]string, error) {
+		var id, events string
+		err := row.Scan(&id, &events)
+		return [2]string{id, rewriteWebhookEvents(events, from, to)}, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, hook := range updated {
+		if _, err := tx.Exec(ctx, "UPDATE _v_webhooks SET events = $2, updated_at = NOW() WHERE id = $1", hook[0], hook[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneIndexSQL rewrites the definition of an index of from into one on to
+func cloneIndexSQL(def, name, renamed, from, to string) (string, error) {
+	ref, err := ParseTableRef(from)
+	if err != nil {
+		return "", err
+	}
+	for _, table := range []string{ref.Schema + "." + ref.Name, ref.Name, ref.SQL()} {
+		old := fmt.Sprintf("INDEX %s ON %s ", name, table)
+		if strings.Contains(def, old) {
+			return strings.Replace(def, old, fmt.Sprintf("INDEX %s ON %s ", renamed, QuoteTable(to)), 1), nil
+		}
+	}
+	return "", fmt.Errorf("unexpected definition of index %s: %s", name, def)
+}
+
+// cloneTableSQL renders the statements that give to the columns, constraints
+// and indexes of from. Objects named after from are named after to, and
+// foreign keys of from to itself point at to.
+func cloneTableSQL(ctx context.Context, tx pgx.Tx, from, to string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT conname, pg_get_constraintdef(oid), '' FROM pg_constraint
+		WHERE conrelid = to_regclass($1) AND contype IN ('p', 'u', 'x', 'c', 'f')
+		UNION ALL
+		SELECT i.relname, '', pg_get_indexdef(ix.indexrelid) FROM pg_index ix JOIN pg_class i ON i.oid = ix.indexrelid
+		WHERE ix.indrelid = to_regclass($1) AND NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conindid = ix.indexrelid)
+		ORDER BY 1
+	`, QuoteTable(from))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// NOT NULL is always copied
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING GENERATED INCLUDING IDENTITY INCLUDING STORAGE INCLUDING COMMENTS)", QuoteTable(to), QuoteTable(from))}
+	for rows.Next() {
+		var name, constraintDef, indexDef string
+		if err := rows.Scan(&name, &constraintDef, &indexDef); err != nil {
+			return nil, err
+		}
+		renamed, ok, err := renamedObject(name, "", relName(from), relName(to))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// Names must be unique per schema, so the clone can't keep them
+			if renamed = relName(to) + "_" + name; !IsValidIdentifier(renamed) {
+				return nil, fmt.Errorf("%w: %s can't be cloned under a valid name", ErrInvalidSchema, name)
+			}
+		}
+
+		if indexDef != "" {
+			sql, err := cloneIndexSQL(indexDef, name, renamed, from, to)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, sql)
+			continue
+		}
+		// Postgres qualifies referenced tables outside the search path, as collection names do
+		constraintDef = strings.ReplaceAll(constraintDef, " REFERENCES "+from+"(", " REFERENCES "+QuoteTable(to)+"(")
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", QuoteTable(to), renamed, constraintDef))
+	}
+	return statements, rows.Err()
+}
+
+// CloneCollection copies the structure, rules, triggers and search setup of a
+// table collection into a new one, and its records (including links) when
+// withData is set. It returns the DDL it ran; copied records aren't part of it.
+func (db *DB) CloneCollection(ctx context.Context, from, to string, withData bool) (string, error) {
+	var statements []string
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		if err := checkCollectionMove(ctx, tx, from, to); err != nil {
+			return err
+		}
+
+		var fields []FieldSchema
+		var managed, historyEnabled, rowSecurity bool
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE(c.schema_def, '[]'::jsonb), c.name IS NOT NULL, COALESCE(c.history_enabled, FALSE), t.relrowsecurity
+			FROM pg_class t LEFT JOIN _v_collections c ON c.name = $2
+			WHERE t.oid = to_regclass($1)
+		`, QuoteTable(from), from).Scan(&fields, &managed, &historyEnabled, &rowSecurity)
+		if err != nil {
+			return err
+		}
+
+		create, err := cloneTableSQL(ctx, tx, from, to)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, create[0]); err != nil {
+			return err
+		}
+		statements = append(statements, create[0])
+
+		// Copy before constraints and indexes are added, which is faster and
+		// lets self-references resolve
+		if withData {
+			if err := copyRows(ctx, tx, from, to); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range create[1:] {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+			statements = append(statements, stmt)
+		}
+
+		// Join tables are rebuilt from the schema so their links point at the clone
+		idType, err := idColumnType(ctx, tx, to)
+		if err != nil {
+			return err
+		}
+		for i, f := range fields {
+			if f.Target == from {
+				fields[i].Target = to
+			}
+			if !f.IsLinkField() {
+				continue
+			}
+			joinSQL, err := JoinTableSQL(to, idType, fields[i])
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, joinSQL); err != nil {
+				return err
+			}
+			statements = append(statements, joinSQL)
+			if withData {
+				join := QuoteTable(JoinTableName(to, f.Name))
+				if _, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", join, QuoteTable(JoinTableName(from, f.Name)))); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Triggers come last so the copied records aren't announced or recorded as changes
+		tail := []string{NotifyTriggerSQL(to)}
+		if historyEnabled {
+			tail = append(tail, HistoryTriggerSQL(to))
+		}
+		if rowSecurity {
+			tail = append(tail, fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", QuoteTable(to)))
+		}
+		for _, stmt := range tail {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+			statements = append(statements, strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";")))
+		}
+
+		if !managed {
+			return nil
+		}
+		schemaJSON, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO _v_collections (name, schema_def, list_rule, create_rule, update_rule, delete_rule, rls_enabled, rls_rule,
+				unknown_fields, retention_days, history_enabled, kind, search_fields, search_language, id_strategy)
+			SELECT $2, $3, list_rule, create_rule, update_rule, delete_rule, rls_enabled, rls_rule,
+				unknown_fields, retention_days, history_enabled, kind, search_fields, search_language, id_strategy
+			FROM _v_collections WHERE name = $1
+		`, from, to, schemaJSON)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(statements, ";\n") + ";", nil
+}
+
+// copyRows copies the records of from into to; generated columns recompute themselves
+func copyRows(ctx context.Context, tx pgx.Tx, from, to string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT attname FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped AND attgenerated = ''
+		ORDER BY attnum
+	`, QuoteTable(from))
+	if err != nil {
+		return err
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return errors.New("no columns to copy")
+	}
+
+	list := strings.Join(columns, ", ")
+	// #nosec G201
+	_, err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", QuoteTable(to), list, list, QuoteTable(from)))
+	if err != nil {
+		return err
+	}
+	return syncIDSequence(ctx, tx, to)
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenamedObject(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		want         string
+		wantOK       bool
+	}{
+		{name: "posts_pkey", want: "articles_pkey", wantOK: true},
+		{name: "posts_title_check", want: "articles_title_check", wantOK: true},
+		{name: "posts_search_idx", want: "articles_search_idx", wantOK: true},
+		{name: "posts__tags_pkey", want: "articles__tags_pkey", wantOK: true},
+		{name: "tr_notify_posts", prefix: "tr_notify_", want: "tr_notify_articles", wantOK: true},
+		{name: "postscript_idx"},
+		{name: "idx_posts_title"},
+		{name: "tr_notify_posts_old", prefix: "tr_notify_", want: "tr_notify_articles_old", wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := renamedObject(tt.name, tt.prefix, "posts", "articles")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, _, err := renamedObject("posts_title_check", "", "posts", strings.Repeat("a", 60))
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+}
+
+func TestRewriteWebhookEvents(t *testing.T) {
+	assert.Equal(t, "articles,articles:insert, users:update", rewriteWebhookEvents("posts,posts:insert, users:update", "posts", "articles"))
+	assert.Equal(t, "postscript,*", rewriteWebhookEvents("postscript,*", "posts", "articles"))
+}
+
+func TestCloneIndexSQL(t *testing.T) {
+	sql, err := cloneIndexSQL("CREATE INDEX posts_status_idx ON public.posts USING btree (status) WHERE (deleted_at IS NULL)",
+		"posts_status_idx", "drafts_status_idx", "posts", "drafts")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE INDEX drafts_status_idx ON \"public\".\"drafts\" USING btree (status) WHERE (deleted_at IS NULL)", sql)
+
+	sql, err = cloneIndexSQL("CREATE UNIQUE INDEX lower_email ON posts USING btree (lower(email))",
+		"lower_email", "drafts_lower_email", "posts", "drafts")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE UNIQUE INDEX drafts_lower_email ON \"public\".\"drafts\" USING btree (lower(email))", sql)
+
+	_, err = cloneIndexSQL("CREATE INDEX x ON other USING btree (a)", "x", "y", "posts", "drafts")
+	assert.Error(t, err)
+}
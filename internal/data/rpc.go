@@ -0,0 +1,265 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrFunctionNotFound is returned for functions that don't exist or aren't exposed
+var ErrFunctionNotFound = errors.New("function not found")
+
+// RPCAnnotation marks a function as callable over the API. It goes on its own
+// line of the function comment:
+//
+//	COMMENT ON FUNCTION place_order(uuid, int) IS '@rpc';
+const RPCAnnotation = "@rpc"
+
+var rpcAnnotationPattern = regexp.MustCompile(`(?m)^\s*` + RPCAnnotation + `\s*$`)
+
+// internalFunctions are the helpers RunMigrations creates; they stay private
+// even when annotated. TestInternalFunctionsCoverMigrations keeps the list in
+// sync with the migrations.
+var internalFunctions = map[string]bool{
+	"collection_name":              true,
+	"notify_event":                 true,
+	"record_history":               true,
+	"reject_view_write":            true,
+	"refresh_collection_view":      true,
+	"array_cosine_distance":        true,
+	"array_l2_distance":            true,
+	"array_negative_inner_product": true,
+	"gen_uuid_v7":                  true,
+	"gen_ulid":                     true,
+	"notify_collection_change":     true,
+}
+
+// How a function's result is returned
+const (
+	RPCReturnsVoid   = "void"   // nothing
+	RPCReturnsScalar = "scalar" // a single value, or a list of them for setof
+	RPCReturnsRow    = "row"    // an object, or a list of them for setof and table functions
+)
+
+// RPCArg is an input argument of an exposed function
+type RPCArg struct {
+	Name string
+	// Type is the base type name (int4, text, jsonb...) or "array" with the
+	// element type in Items, matching the scalar and array field types
+	Type       string
+	Items      string
+	SQLType    string // as written in a cast, e.g. "timestamp with time zone"
+	HasDefault bool
+}
+
+// RPCFunction is a Postgres function callable through the RPC endpoint
+type RPCFunction struct {
+	Name    string
+	Args    []RPCArg
+	Returns string // RPCReturnsVoid, RPCReturnsScalar or RPCReturnsRow
+	Set     bool
+}
+
+// isExposedFunction reports whether a function may be called over the API
+func isExposedFunction(name, comment string) bool {
+	if strings.HasPrefix(name, "_v_") || internalFunctions[name] {
+		return false
+	}
+	return rpcAnnotationPattern.MatchString(comment)
+}
+
+// rpcProc is one pg_proc row of a candidate function
+type rpcProc struct {
+	comment     string
+	argNames    []string
+	argModes    []string
+	argTypes    []string // format_type of every argument, OUT ones included
+	argTypNames []string
+	argElems    []string // element type name of array arguments
+	numDefaults int
+	retSet      bool
+	retTypName  string
+	retTypType  string
+}
+
+// GetRPCFunction introspects an exposed function of the public schema.
+// Overloaded functions can't be exposed since named JSON arguments can't tell
+// the overloads apart.
+func (db *DB) GetRPCFunction(ctx context.Context, name string) (*RPCFunction, error) {
+	if !IsValidIdentifier(name) {
+		return nil, ErrFunctionNotFound
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT COALESCE(obj_description(p.oid, 'pg_proc'), ''),
+		       COALESCE(p.proargnames, '{}'),
+		       COALESCE(p.proargmodes::text[], '{}'),
+		       ARRAY(SELECT format_type(a.t, NULL) FROM unnest(COALESCE(p.proallargtypes, p.proargtypes::oid[])) WITH ORDINALITY a(t, n) ORDER BY a.n),
+		       ARRAY(SELECT ty.typname::text FROM unnest(COALESCE(p.proallargtypes, p.proargtypes::oid[])) WITH ORDINALITY a(t, n) JOIN pg_type ty ON ty.oid = a.t ORDER BY a.n),
+		       ARRAY(SELECT COALESCE(el.typname::text, '') FROM unnest(COALESCE(p.proallargtypes, p.proargtypes::oid[])) WITH ORDINALITY a(t, n) JOIN pg_type ty ON ty.oid = a.t LEFT JOIN pg_type el ON el.oid = ty.typelem AND ty.typcategory = 'A' ORDER BY a.n),
+		       p.pronargdefaults, p.proretset, rt.typname::text, rt.typtype::text
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		JOIN pg_type rt ON rt.oid = p.prorettype
+		WHERE n.nspname = 'public' AND p.proname = $1 AND p.prokind = 'f'
+		  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var procs []rpcProc
+	for rows.Next() {
+		var p rpcProc
+		if err := rows.Scan(&p.comment, &p.argNames, &p.argModes, &p.argTypes, &p.argTypNames, &p.argElems,
+			&p.numDefaults, &p.retSet, &p.retTypName, &p.retTypType); err != nil {
+			return nil, err
+		}
+		if isExposedFunction(name, p.comment) {
+			procs = append(procs, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(procs) {
+	case 0:
+		return nil, ErrFunctionNotFound
+	case 1:
+		return procs[0].function(name)
+	}
+	return nil, fmt.Errorf("%w: %s is overloaded", ErrInvalidQuery, name)
+}
+
+// function builds the callable signature of a pg_proc row
+func (p rpcProc) function(name string) (*RPCFunction, error) {
+	fn := &RPCFunction{Name: name, Set: p.retSet}
+	hasOut := false
+
+	var inputs []RPCArg
+	for i, typ := range p.argTypes {
+		mode := "i"
+		if i < len(p.argModes) {
+			mode = p.argModes[i]
+		}
+		switch mode {
+		case "o", "t":
+			hasOut = true
+			continue
+		case "b":
+			hasOut = true
+		case "v":
+			return nil, fmt.Errorf("%w: variadic functions can't be called over RPC", ErrInvalidQuery)
+		}
+
+		var argName string
+		if i < len(p.argNames) {
+			argName = p.argNames[i]
+		}
+		if !IsValidIdentifier(argName) {
+			return nil, fmt.Errorf("%w: every argument of %s needs a name", ErrInvalidQuery, name)
+		}
+		arg := RPCArg{Name: argName, Type: p.argTypNames[i], SQLType: typ}
+		if p.argElems[i] != "" {
+			arg.Type, arg.Items = FieldArray, p.argElems[i]
+		}
+		inputs = append(inputs, arg)
+	}
+	// Defaults belong to the trailing input arguments
+	for i := len(inputs) - p.numDefaults; i < len(inputs); i++ {
+		inputs[i].HasDefault = true
+	}
+	fn.Args = inputs
+
+	switch {
+	case p.retTypName == "void":
+		fn.Returns = RPCReturnsVoid
+	case p.retTypName == "record" && !hasOut:
+		return nil, fmt.Errorf("%w: functions returning an untyped record can't be called over RPC", ErrInvalidQuery)
+	case p.retTypType == "c" || p.retTypName == "record":
+		fn.Returns = RPCReturnsRow
+	case p.retTypType == "p":
+		return nil, fmt.Errorf("%w: functions returning %s can't be called over RPC", ErrInvalidQuery, p.retTypName)
+	default:
+		fn.Returns = RPCReturnsScalar
+	}
+	return fn, nil
+}
+
+// BuildRPCCall renders the call of fn with the given arguments in named
+// notation, casting each to its declared type. Arguments are bound in the
+// order they are returned.
+func BuildRPCCall(fn *RPCFunction, args map[string]any) (string, []any) {
+	var (
+		params []string
+		values []any
+	)
+	for _, arg := range fn.Args {
+		val, ok := args[arg.Name]
+		if !ok {
+			continue
+		}
+		values = append(values, val)
+		params = append(params, fmt.Sprintf("%s => $%d::%s", arg.Name, len(values), arg.SQLType))
+	}
+	// Names and types come from the catalog, values are bound
+	// #nosec G201
+	call := fmt.Sprintf("%s(%s)", fn.Name, strings.Join(params, ", "))
+
+	if fn.Returns == RPCReturnsVoid {
+		return "SELECT " + call, values
+	}
+	// Scalar results come back as a column named value
+	return "SELECT * FROM " + call + " AS value", values
+}
+
+// CallRPC runs an exposed function in a transaction carrying the caller's RLS
+// context. Arguments must already be coerced to the types pgx expects. The
+// result is nil for void functions, a value or object for single results and
+// a list of them for set-returning functions.
+func (db *DB) CallRPC(ctx context.Context, fn *RPCFunction, args map[string]any) (any, error) {
+	query, values := BuildRPCCall(fn, args)
+
+	var result any
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		if fn.Returns == RPCReturnsVoid {
+			_, err := tx.Exec(ctx, query, values...)
+			return err
+		}
+
+		rows, err := tx.Query(ctx, query, values...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		records, err := rowsToMaps(rows)
+		if err != nil {
+			return err
+		}
+
+		items := make([]any, len(records))
+		for i, record := range records {
+			if fn.Returns == RPCReturnsScalar {
+				items[i] = record["value"]
+			} else {
+				items[i] = record
+			}
+		}
+
+		switch {
+		case fn.Set:
+			result = items
+		case len(items) > 0:
+			result = items[0]
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsExposedFunction(t *testing.T) {
+	assert.True(t, isExposedFunction("place_order", "@rpc"))
+	assert.True(t, isExposedFunction("place_order", "Places an order\n  @rpc\n"))
+	assert.False(t, isExposedFunction("place_order", ""))
+	assert.False(t, isExposedFunction("place_order", "see @rpcs"))
+	assert.False(t, isExposedFunction("_v_helper", "@rpc"))
+	assert.False(t, isExposedFunction("notify_event", "@rpc"))
+	assert.False(t, isExposedFunction("gen_ulid", "@rpc"))
+}
+
+func TestInternalFunctionsCoverMigrations(t *testing.T) {
+	src, err := os.ReadFile("migrations.go")
+	assert.NoError(t, err)
+
+	created := regexp.MustCompile(`CREATE (?:OR REPLACE )?FUNCTION (\w+)\(`).FindAllStringSubmatch(string(src), -1)
+	assert.NotEmpty(t, created)
+	for _, m := range created {
+		assert.True(t, internalFunctions[m[1]], "%s is created by the migrations but not listed in internalFunctions", m[1])
+	}
+}
+
+func TestRPCProcFunction(t *testing.T) {
+	// place_order(customer uuid, items text[], qty int4 DEFAULT 1, OUT id uuid, OUT total numeric)
+	proc := rpcProc{
+		argNames:    []string{"customer", "items", "qty", "id", "total"},
+		argModes:    []string{"i", "i", "i", "o", "o"},
+		argTypes:    []string{"uuid", "text[]", "integer", "uuid", "numeric"},
+		argTypNames: []string{"uuid", "_text", "int4", "uuid", "numeric"},
+		argElems:    []string{"", "text", "", "", ""},
+		numDefaults: 1,
+		retTypName:  "record",
+		retTypType:  "p",
+	}
+	fn, err := proc.function("place_order")
+	assert.NoError(t, err)
+	assert.Equal(t, RPCReturnsRow, fn.Returns)
+	assert.Equal(t, []RPCArg{
+		{Name: "customer", Type: "uuid", SQLType: "uuid"},
+		{Name: "items", Type: FieldArray, Items: "text", SQLType: "text[]"},
+		{Name: "qty", Type: "int4", SQLType: "integer", HasDefault: true},
+	}, fn.Args)
+
+	scalar := rpcProc{retTypName: "int8", retTypType: "b", retSet: true}
+	fn, err = scalar.function("counts")
+	assert.NoError(t, err)
+	assert.Equal(t, RPCReturnsScalar, fn.Returns)
+	assert.True(t, fn.Set)
+
+	invalid := []rpcProc{
+		{retTypName: "record", retTypType: "p"},
+		{retTypName: "trigger", retTypType: "p"},
+		{argNames: []string{""}, argTypes: []string{"integer"}, argTypNames: []string{"int4"}, argElems: []string{""}, retTypName: "int4", retTypType: "b"},
+		{argModes: []string{"v"}, argNames: []string{"xs"}, argTypes: []string{"integer[]"}, argTypNames: []string{"_int4"}, argElems: []string{"int4"}, retTypName: "int4", retTypType: "b"},
+	}
+	for _, p := range invalid {
+		_, err := p.function("f")
+		assert.True(t, errors.Is(err, ErrInvalidQuery), "%+v", p)
+	}
+}
+
+func TestBuildRPCCall(t *testing.T) {
+	fn := &RPCFunction{
+		Name: "place_order",
+		Args: []RPCArg{
+			{Name: "customer", SQLType: "uuid"},
+			{Name: "qty", SQLType: "integer", HasDefault: true},
+			{Name: "note", SQLType: "text", HasDefault: true},
+		},
+		Returns: RPCReturnsRow,
+	}
+	query, values := BuildRPCCall(fn, map[string]any{"customer": "c1", "note": "gift"})
+	assert.Equal(t, "SELECT * FROM place_order(customer => $1::uuid, note => $2::text) AS value", query)
+	assert.Equal(t, []any{"c1", "gift"}, values)
+
+	fn.Returns = RPCReturnsVoid
+	query, values = BuildRPCCall(fn, map[string]any{})
+	assert.Equal(t, "SELECT place_order()", query)
+	assert.Empty(t, values)
+}
@@ -2,8 +2,14 @@ package data
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // FieldSchema represents a single field in a collection schema
@@ -12,6 +18,28 @@ type FieldSchema struct {
 	Type     string `json:"type"`
 	Required bool   `json:"required,omitempty"`
 	Default  any    `json:"default,omitempty"`
+
+	// Validators, enforced as CHECK/UNIQUE constraints on the column.
+	// Min/Max bound the value of numeric fields and the length of text fields.
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Pattern string   `json:"pattern,omitempty"` // regular expression text values must match
+	Enum    []string `json:"enum,omitempty"`    // allowed values
+	Unique  bool     `json:"unique,omitempty"`
+
+	// Options of higher-level field types, see FieldRelation and friends
+	Target     string          `json:"target,omitempty"`      // collection a relation points to
+	Multiple   bool            `json:"multiple,omitempty"`    // relation holds many ids, stored in a join table
+	OnDelete   string          `json:"on_delete,omitempty"`   // OnDeleteSetNull, OnDeleteCascade or OnDeleteRestrict
+	IDType     string          `json:"id_type,omitempty"`     // field type of the Target's ids, uuid unless resolved otherwise
+	Options    []string        `json:"options,omitempty"`     // choices of a select field
+	Items      string          `json:"items,omitempty"`       // element type of an array field
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"` // JSON Schema values of a json field must satisfy
+
+	Dimensions    int    `json:"dimensions,omitempty"`     // size of a vector field
+	VectorStorage string `json:"vector_storage,omitempty"` // VectorStoragePGVector or VectorStorageArray
+
+	FieldAccess // hidden_from and write
 }
 
 // TypeMapping maps OzyBase types to PostgreSQL types
@@ -41,8 +69,9 @@ var TypeMapping = map[string]string{
 	"string":  "TEXT",
 }
 
-// BuildCreateTableSQL generates a CREATE TABLE statement from a schema definition
-func BuildCreateTableSQL(tableName string, schema []FieldSchema) (string, error) {
+// BuildCreateTableSQL generates a CREATE TABLE statement from a schema definition.
+// The id column follows idStrategy, see IDUUIDv4 and friends.
+func BuildCreateTableSQL(tableName, idStrategy string, schema []FieldSchema) (string, error) {
 	if tableName == "" {
 		return "", fmt.Errorf("table name cannot be empty")
 	}
@@ -51,24 +80,41 @@ func BuildCreateTableSQL(tableName string, schema []FieldSchema) (string, error)
 		return "", fmt.Errorf("schema cannot be empty")
 	}
 
-	// Validate table name (prevent SQL injection)
-	if !IsValidIdentifier(tableName) {
-		return "", fmt.Errorf("invalid table name: %s", tableName)
+	ref, err := ParseTableRef(tableName)
+	if err != nil {
+		return "", err
 	}
 
-	var columns []string
+	idColumn, err := primaryKeySQL(idStrategy)
+	if err != nil {
+		return "", err
+	}
+
+	var columns, joinTables []string
 
 	// Always add id as primary key
-	columns = append(columns, "id UUID PRIMARY KEY DEFAULT gen_random_uuid()")
+	columns = append(columns, idColumn)
 
 	for _, field := range schema {
 		if !IsValidIdentifier(field.Name) {
 			return "", fmt.Errorf("invalid field name: %s", field.Name)
 		}
+		if err := normalizeVectorType(&field); err != nil {
+			return "", err
+		}
 
-		pgType, ok := TypeMapping[strings.ToLower(field.Type)]
-		if !ok {
-			return "", fmt.Errorf("unknown type: %s", field.Type)
+		if field.IsLinkField() {
+			joinSQL, err := JoinTableSQL(tableName, IDFieldType(idStrategy), field)
+			if err != nil {
+				return "", err
+			}
+			joinTables = append(joinTables, joinSQL)
+			continue
+		}
+
+		pgType, err := columnTypeSQL(field)
+		if err != nil {
+			return "", err
 		}
 
 		col := fmt.Sprintf("%s %s", field.Name, pgType)
@@ -81,6 +127,12 @@ func BuildCreateTableSQL(tableName string, schema []FieldSchema) (string, error)
 			col += fmt.Sprintf(" DEFAULT %s", formatDefault(field.Default, field.Type))
 		}
 
+		constraints, err := fieldConstraintSQL(field)
+		if err != nil {
+			return "", err
+		}
+		col += constraints
+
 		columns = append(columns, col)
 	}
 
@@ -91,9 +143,19 @@ func BuildCreateTableSQL(tableName string, schema []FieldSchema) (string, error)
 
 	// #nosec G201
 	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)",
-		tableName,
+		ref.SQL(),
 		strings.Join(columns, ",\n\t"))
 
+	// Collections outside the default schema create theirs on first use
+	if ref.Schema != DefaultSchema {
+		sql = fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;\n\n%s", QuoteIdent(ref.Schema), sql)
+	}
+
+	// Join tables reference the new table, so they follow it
+	for _, joinSQL := range joinTables {
+		sql += ";\n\n" + joinSQL
+	}
+
 	return sql, nil
 }
 
@@ -136,21 +198,14 @@ func formatDefault(value any, fieldType string) string {
 	}
 }
 
-// GetTableSchema fetches the schema of a table from information_schema
+// GetTableSchema fetches the schema of a table from the catalog
 func (db *DB) GetTableSchema(ctx context.Context, tableName string) ([]FieldSchema, error) {
-	if !IsValidIdentifier(tableName) {
+	ref, err := ParseTableRef(tableName)
+	if err != nil {
 		return nil, fmt.Errorf("invalid table name: %s", tableName)
 	}
 
-	query := `
-		SELECT column_name, data_type, is_nullable
-		FROM information_schema.columns
-		WHERE table_name = $1
-		  AND table_schema = 'public'
-		ORDER BY ordinal_position
-	`
-
-	rows, err := db.Pool.Query(ctx, query, tableName)
+	rows, err := db.Pool.Query(ctx, columnsSQL, ref.Schema, ref.Name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query table schema: %w", err)
 	}
@@ -175,6 +230,26 @@ func (db *DB) GetTableSchema(ctx context.Context, tableName string) ([]FieldSche
 			Required: isNullable == "NO",
 		})
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The catalog only knows the storage type, e.g. uuid for a relation or
+	// text for a select, so prefer the definitions the collection was built from
+	defs, err := db.storedFields(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for i, field := range schema {
+		if def, ok := defs[field.Name]; ok {
+			schema[i] = def
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		if defs[name].IsLinkField() {
+			schema = append(schema, defs[name])
+		}
+	}
 
 	if len(schema) == 0 {
 		return nil, fmt.Errorf("table not found or has no columns: %s", tableName)
@@ -183,9 +258,28 @@ func (db *DB) GetTableSchema(ctx context.Context, tableName string) ([]FieldSche
 	return schema, nil
 }
 
+// storedFields returns the field definitions a managed collection was built from
+func (db *DB) storedFields(ctx context.Context, tableName string) (map[string]FieldSchema, error) {
+	var fields []FieldSchema
+	err := db.Pool.QueryRow(ctx, "SELECT schema_def FROM _v_collections WHERE name = $1", tableName).Scan(&fields)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	defs := make(map[string]FieldSchema, len(fields))
+	for _, f := range fields {
+		defs[f.Name] = f
+	}
+	return defs, nil
+}
+
 func mapPostgresTypeToOzy(pgType string) string {
 	pgType = strings.ToUpper(pgType)
 	switch {
+	case strings.HasPrefix(pgType, "VECTOR"):
+		return "vector"
+	case pgType == "ARRAY" || strings.HasSuffix(pgType, "[]"):
+		return "array"
 	case strings.Contains(pgType, "INT2"):
 		return "int2"
 	case strings.Contains(pgType, "INT4") || pgType == "INTEGER":
@@ -235,6 +329,7 @@ type DatabaseSchema struct {
 
 type TableDefinition struct {
 	Name    string        `json:"name"`
+	Type    string        `json:"type"` // CollectionTable or CollectionView
 	Columns []FieldSchema `json:"columns"`
 }
 
@@ -247,8 +342,8 @@ type TableRelationship struct {
 
 // GetDatabaseSchema fetches the full schema for visualization
 func (db *DB) GetDatabaseSchema(ctx context.Context) (*DatabaseSchema, error) {
-	// 1. Get all tables
-	tables, err := db.ListTables(ctx)
+	// 1. Get all tables and views
+	kinds, err := tableKinds(ctx, db.Pool)
 	if err != nil {
 		return nil, err
 	}
@@ -256,16 +351,13 @@ func (db *DB) GetDatabaseSchema(ctx context.Context) (*DatabaseSchema, error) {
 	var schema DatabaseSchema
 
 	// 2. Get columns for each table
-	for _, tableName := range tables {
+	for _, tableName := range slices.Sorted(maps.Keys(kinds)) {
 		// reuse GetTableSchema logic but include system cols for visualization
-		query := `
-			SELECT column_name, data_type, is_nullable
-			FROM information_schema.columns
-			WHERE table_name = $1
-			  AND table_schema = 'public'
-			ORDER BY ordinal_position
-		`
-		rows, err := db.Pool.Query(ctx, query, tableName)
+		ref, err := ParseTableRef(tableName)
+		if err != nil {
+			continue // names the API can't address are left out
+		}
+		rows, err := db.Pool.Query(ctx, columnsSQL, ref.Schema, ref.Name)
 		if err != nil {
 			continue // skip table on error
 		}
@@ -285,16 +377,83 @@ func (db *DB) GetDatabaseSchema(ctx context.Context) (*DatabaseSchema, error) {
 
 		schema.Tables = append(schema.Tables, TableDefinition{
 			Name:    tableName,
+			Type:    kinds[tableName],
 			Columns: cols,
 		})
 	}
 
 	// 3. Get relationships (Foreign Keys)
+	schema.Relationships, err = listRelationships(ctx, db.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch relationships: %w", err)
+	}
+
+	return &schema, nil
+}
+
+// columnsSQL lists the name, data type and nullability of the columns of the
+// relation named $2 in schema $1 in order. information_schema leaves
+// materialized views out, so their columns are read from pg_attribute with
+// the same type names.
+const columnsSQL = `
+	SELECT name, data_type, is_nullable FROM (
+		SELECT column_name::text AS name, data_type::text, is_nullable::text, ordinal_position::int AS position
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		  AND table_name = $2
+		UNION ALL
+		SELECT a.attname::text, format_type(a.atttypid, NULL), CASE WHEN a.attnotnull THEN 'NO' ELSE 'YES' END, a.attnum::int
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+		  AND c.relname = $2
+		  AND c.relkind = 'm'
+		  AND a.attnum > 0 AND NOT a.attisdropped
+	) cols
+	ORDER BY position
+`
+
+// tableKinds maps every table, view and materialized view in a user schema
+// to its collection kind, keyed by collection name
+func tableKinds(ctx context.Context, q querier) (map[string]string, error) {
+	rows, err := q.Query(ctx, `
+		SELECT collection_name(n.nspname, c.relname),
+			CASE c.relkind WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized_view' ELSE 'table' END
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'v', 'm')
+		  AND `+userSchemasSQL+`
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kinds := make(map[string]string)
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return nil, err
+		}
+		kinds[name] = kind
+	}
+	return kinds, rows.Err()
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// listRelationships returns every foreign key between tables in user schemas,
+// naming both ends by collection name
+func listRelationships(ctx context.Context, q querier) ([]TableRelationship, error) {
 	relQuery := `
 		SELECT
-			tc.table_name,
+			collection_name(tc.table_schema, tc.table_name),
 			kcu.column_name,
-			ccu.table_name AS foreign_table_name,
+			collection_name(ccu.table_schema, ccu.table_name) AS foreign_table_name,
 			ccu.column_name AS foreign_column_name
 		FROM
 			information_schema.table_constraints AS tc
@@ -303,20 +462,22 @@ func (db *DB) GetDatabaseSchema(ctx context.Context) (*DatabaseSchema, error) {
 			  AND tc.table_schema = kcu.table_schema
 			JOIN information_schema.constraint_column_usage AS ccu
 			  ON ccu.constraint_name = tc.constraint_name
-			  AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = 'public';
+			  AND ccu.constraint_schema = tc.constraint_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY'
+		  AND tc.table_schema NOT LIKE 'pg\_%' AND tc.table_schema <> 'information_schema';
 	`
 
-	rows, err := db.Pool.Query(ctx, relQuery)
+	rows, err := q.Query(ctx, relQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch relationships: %w", err)
+		return nil, err
 	}
 	defer rows.Close()
 
+	var rels []TableRelationship
 	for rows.Next() {
 		var fromTable, fromCol, toTable, toCol string
 		if err := rows.Scan(&fromTable, &fromCol, &toTable, &toCol); err == nil {
-			schema.Relationships = append(schema.Relationships, TableRelationship{
+			rels = append(rels, TableRelationship{
 				FromTable: fromTable,
 				FromCol:   fromCol,
 				ToTable:   toTable,
@@ -325,53 +486,144 @@ func (db *DB) GetDatabaseSchema(ctx context.Context) (*DatabaseSchema, error) {
 		}
 	}
 
-	return &schema, nil
+	return rels, rows.Err()
 }
 
-// AddColumn adds a new column to an existing table
-func (db *DB) AddColumn(ctx context.Context, tableName string, field FieldSchema) (string, error) {
-	if !IsValidIdentifier(tableName) || !IsValidIdentifier(field.Name) {
-		return "", fmt.Errorf("invalid table or column name")
+// tableColumns returns the column names and data types of a collection's table
+func tableColumns(ctx context.Context, q querier, tableName string) (map[string]string, error) {
+	ref, err := ParseTableRef(tableName)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query(ctx, columnsSQL, ref.Schema, ref.Name)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	pgType, ok := TypeMapping[strings.ToLower(field.Type)]
-	if !ok {
-		return "", fmt.Errorf("unknown type: %s", field.Type)
+	cols := make(map[string]string)
+	for rows.Next() {
+		var name, dataType, isNullable string
+		if err := rows.Scan(&name, &dataType, &isNullable); err != nil {
+			return nil, err
+		}
+		cols[name] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// #nosec G201
-	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, field.Name, pgType)
-	if field.Required {
-		sql += " NOT NULL"
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table not found: %s", tableName)
 	}
-	if field.Default != nil {
-		sql += fmt.Sprintf(" DEFAULT %s", formatDefault(field.Default, field.Type))
+	return cols, nil
+}
+
+// AddColumn adds a new column to an existing table and records it in the collection schema
+func (db *DB) AddColumn(ctx context.Context, tableName string, field FieldSchema) (string, error) {
+	if !ValidCollectionName(tableName) || !IsValidIdentifier(field.Name) {
+		return "", fmt.Errorf("%w: invalid table or column name", ErrInvalidSchema)
 	}
+	fields := []FieldSchema{field}
+	if err := db.ResolveVectorFields(ctx, fields); err != nil {
+		return "", err
+	}
+	if err := db.ResolveRelationFields(ctx, tableName, "", fields); err != nil {
+		return "", err
+	}
+	field = fields[0]
 
-	_, err := db.Pool.Exec(ctx, sql)
+	var sql string
+	if field.IsLinkField() {
+		idType, err := idColumnType(ctx, db.Pool, tableName)
+		if err != nil {
+			return "", err
+		}
+		joinSQL, err := JoinTableSQL(tableName, idType, field)
+		if err != nil {
+			return "", err
+		}
+		sql = joinSQL
+	} else {
+		pgType, err := columnTypeSQL(field)
+		if err != nil {
+			return "", err
+		}
+
+		// #nosec G201
+		sql = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", QuoteTable(tableName), field.Name, pgType)
+		if field.Required {
+			sql += " NOT NULL"
+		}
+		if field.Default != nil {
+			sql += fmt.Sprintf(" DEFAULT %s", formatDefault(field.Default, field.Type))
+		}
+
+		constraints, err := fieldConstraintSQL(field)
+		if err != nil {
+			return "", err
+		}
+		sql += constraints
+	}
+
+	fieldJSON, _ := json.Marshal([]FieldSchema{field})
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		// Collections registered without a schema stay unmanaged rather than gaining a partial one
+		_, err := tx.Exec(ctx, `
+			UPDATE _v_collections
+			SET schema_def = schema_def || $2::jsonb, updated_at = NOW()
+			WHERE name = $1 AND jsonb_array_length(schema_def) > 0
+		`, tableName, string(fieldJSON))
+		return err
+	})
 	return sql, err
 }
 
-// DeleteColumn removes a column from an existing table
+// DeleteColumn removes a column from an existing table and from the collection schema
 func (db *DB) DeleteColumn(ctx context.Context, tableName string, columnName string) (string, error) {
-	if !IsValidIdentifier(tableName) || !IsValidIdentifier(columnName) {
+	if !ValidCollectionName(tableName) || !IsValidIdentifier(columnName) {
 		return "", fmt.Errorf("invalid table or column name")
 	}
 
 	// #nosec G201
-	sql := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, columnName)
-	_, err := db.Pool.Exec(ctx, sql)
+	sql := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", QuoteTable(tableName), columnName)
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		links, err := linkFields(ctx, tx, tableName)
+		if err != nil {
+			return err
+		}
+		if _, ok := links[columnName]; ok {
+			sql = fmt.Sprintf("DROP TABLE IF EXISTS %s", QuoteTable(JoinTableName(tableName, columnName)))
+		}
+
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE _v_collections
+			SET schema_def = (
+				SELECT COALESCE(jsonb_agg(f), '[]'::jsonb)
+				FROM jsonb_array_elements(schema_def) f
+				WHERE f->>'name' <> $2
+			), updated_at = NOW()
+			WHERE name = $1
+		`, tableName, columnName)
+		return err
+	})
 	return sql, err
 }
 
 // DeleteTable drops an existing table
 func (db *DB) DeleteTable(ctx context.Context, tableName string) error {
-	if !IsValidIdentifier(tableName) {
+	if !ValidCollectionName(tableName) {
 		return fmt.Errorf("invalid table name: %s", tableName)
 	}
 
 	// #nosec G201
-	sql := fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", tableName)
+	sql := fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", QuoteTable(tableName))
 	_, err := db.Pool.Exec(ctx, sql)
 	return err
 }
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SchemaRules are the access rules shared by the collections of a Postgres
+// schema. They gate requests on top of each collection's own rules; an empty
+// rule doesn't restrict anything. The RLS rule applies to collections that
+// don't enable RLS themselves.
+type SchemaRules struct {
+	Name       string `json:"name"`
+	ListRule   string `json:"list_rule"`
+	CreateRule string `json:"create_rule"`
+	UpdateRule string `json:"update_rule"`
+	DeleteRule string `json:"delete_rule"`
+	RlsEnabled bool   `json:"rls_enabled"`
+	RlsRule    string `json:"rls_rule"`
+}
+
+// SchemaRulesUpdate changes the rules of a schema; nil fields are kept and
+// empty rules are cleared
+type SchemaRulesUpdate struct {
+	ListRule   *string `json:"list_rule,omitempty"`
+	CreateRule *string `json:"create_rule,omitempty"`
+	UpdateRule *string `json:"update_rule,omitempty"`
+	DeleteRule *string `json:"delete_rule,omitempty"`
+	RlsEnabled *bool   `json:"rls_enabled,omitempty"`
+	RlsRule    *string `json:"rls_rule,omitempty"`
+}
+
+// UpdateSchemaRules stores the rules of a schema, creating the schema if it
+// doesn't exist yet. It returns the stored rules and the DDL it ran, which is
+// empty when the schema already existed.
+func (db *DB) UpdateSchemaRules(ctx context.Context, schema string, update SchemaRulesUpdate) (*SchemaRules, string, error) {
+	if !ValidSchemaName(schema) {
+		return nil, "", fmt.Errorf("%w: invalid schema name %q", ErrInvalidSchema, schema)
+	}
+
+	rules := &SchemaRules{Name: schema}
+	var sql string
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", schema).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			sql = fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", QuoteIdent(schema))
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+			sql += ";"
+		}
+
+		return tx.QueryRow(ctx, `
+			INSERT INTO _v_schemas (name, list_rule, create_rule, update_rule, delete_rule, rls_enabled, rls_rule)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), COALESCE($6, FALSE), NULLIF($7, ''))
+			ON CONFLICT (name) DO UPDATE SET
+				list_rule = CASE WHEN $2::text IS NULL THEN _v_schemas.list_rule ELSE NULLIF($2, '') END,
+				create_rule = CASE WHEN $3::text IS NULL THEN _v_schemas.create_rule ELSE NULLIF($3, '') END,
+				update_rule = CASE WHEN $4::text IS NULL THEN _v_schemas.update_rule ELSE NULLIF($4, '') END,
+				delete_rule = CASE WHEN $5::text IS NULL THEN _v_schemas.delete_rule ELSE NULLIF($5, '') END,
+				rls_enabled = COALESCE($6, _v_schemas.rls_enabled),
+				rls_rule = CASE WHEN $7::text IS NULL THEN _v_schemas.rls_rule ELSE NULLIF($7, '') END,
+				updated_at = NOW()
+			RETURNING COALESCE(list_rule, ''), COALESCE(create_rule, ''), COALESCE(update_rule, ''), COALESCE(delete_rule, ''),
+				COALESCE(rls_enabled, FALSE), COALESCE(rls_rule, '')
+		`, schema, update.ListRule, update.CreateRule, update.UpdateRule, update.DeleteRule, update.RlsEnabled, update.RlsRule).Scan(
+			&rules.ListRule, &rules.CreateRule, &rules.UpdateRule, &rules.DeleteRule, &rules.RlsEnabled, &rules.RlsRule)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return rules, sql, nil
+}
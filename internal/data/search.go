@@ -0,0 +1,286 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SearchVectorColumn is the generated tsvector column of a searchable collection.
+// It is internal and left out of record reads.
+const SearchVectorColumn = "search_vector"
+
+// DefaultSearchLanguage is the text search configuration used when none is set
+const DefaultSearchLanguage = "simple"
+
+// headlineOptions mark matches in ts_headline snippets. Snippets are not HTML
+// escaped, so clients must escape the record text before rendering the marks.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5"
+
+// searchWeights rank matches in earlier search fields higher; fields past the
+// fourth share the lowest weight
+var searchWeights = []string{"A", "B", "C", "D"}
+
+// SearchConfig lists the fields a collection is searched by, in order of
+// importance, and the Postgres text search configuration (e.g. "english") used
+// to stem them
+type SearchConfig struct {
+	Fields   []string `json:"fields"`
+	Language string   `json:"language"`
+}
+
+// SearchOptions describes a full-text query over a searchable collection
+type SearchOptions struct {
+	// Query uses web search syntax: words, "quoted phrases", OR and -excluded words
+	Query   string
+	Filters map[string][]string
+	Trashed string
+	Limit   int
+	Offset  int
+	Select  Selection
+	// OwnerField and OwnerID work as in ListOptions
+	OwnerField string
+	OwnerID    string
+}
+
+// searchIndexName is the GIN index over a collection's search vector
+func searchIndexName(table string) string {
+	return relName(table) + "_search_idx"
+}
+
+// searchDocument renders the weighted tsvector expression over fields
+func searchDocument(fields []string, language string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		weight := searchWeights[min(i, len(searchWeights)-1)]
+		parts[i] = fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s::text, '')), '%s')", language, field, weight)
+	}
+	return strings.Join(parts, " || ")
+}
+
+// BuildSearchSQL renders the statements that add the generated search column
+// and its GIN index. An empty field list renders nothing.
+func BuildSearchSQL(table string, cfg SearchConfig) ([]string, error) {
+	if !ValidCollectionName(table) {
+		return nil, fmt.Errorf("invalid table name: %s", table)
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, nil
+	}
+	if !IsValidIdentifier(cfg.Language) {
+		return nil, fmt.Errorf("%w: invalid search language %q", ErrInvalidQuery, cfg.Language)
+	}
+	for _, field := range cfg.Fields {
+		if !IsValidIdentifier(field) {
+			return nil, fmt.Errorf("%w: invalid search field %q", ErrInvalidQuery, field)
+		}
+	}
+
+	// #nosec G201
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s tsvector GENERATED ALWAYS AS (%s) STORED",
+			QuoteTable(table), SearchVectorColumn, searchDocument(cfg.Fields, cfg.Language)),
+		fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s)", searchIndexName(table), QuoteTable(table), SearchVectorColumn),
+	}, nil
+}
+
+// DropSearchSQL renders the statements that remove a collection's search column and index
+func DropSearchSQL(table string) []string {
+	return []string{
+		fmt.Sprintf("DROP INDEX IF EXISTS %s", siblingSQL(table, searchIndexName(table))),
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", QuoteTable(table), SearchVectorColumn),
+	}
+}
+
+// SetSearchConfig replaces the search column of a collection inside tx and
+// stores the configuration. Fields must be text columns. It returns the DDL it
+// ran so callers can record it as a migration.
+func SetSearchConfig(ctx context.Context, tx pgx.Tx, table string, cfg SearchConfig) (string, error) {
+	if cfg.Language == "" {
+		cfg.Language = DefaultSearchLanguage
+	}
+
+	columns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range cfg.Fields {
+		dataType, ok := columns[field]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown search field %q", ErrInvalidQuery, field)
+		}
+		if dataType != "text" && dataType != "character varying" && dataType != "character" {
+			return "", fmt.Errorf("%w: search field %q must be text, not %s", ErrInvalidQuery, field, dataType)
+		}
+	}
+
+	if len(cfg.Fields) > 0 {
+		var known bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = $1)", cfg.Language).Scan(&known); err != nil {
+			return "", err
+		}
+		if !known {
+			return "", fmt.Errorf("%w: unknown search language %q", ErrInvalidQuery, cfg.Language)
+		}
+	}
+
+	addSQL, err := BuildSearchSQL(table, cfg)
+	if err != nil {
+		return "", err
+	}
+	statements := append(DropSearchSQL(table), addSQL...)
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return "", err
+		}
+	}
+
+	language := cfg.Language
+	if len(cfg.Fields) == 0 {
+		language = ""
+	}
+	fields := cfg.Fields
+	if fields == nil {
+		fields = []string{}
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE _v_collections SET search_fields = $2, search_language = NULLIF($3, ''), updated_at = NOW()
+		WHERE name = $1
+	`, table, fields, language)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(statements, ";\n") + ";", nil
+}
+
+// UpdateSearchConfig changes the search fields and language of a collection
+func (db *DB) UpdateSearchConfig(ctx context.Context, table string, cfg SearchConfig) (string, error) {
+	if !ValidCollectionName(table) {
+		return "", fmt.Errorf("invalid table name: %s", table)
+	}
+
+	var sql string
+	err := pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		var err error
+		sql, err = SetSearchConfig(ctx, tx, table, cfg)
+		return err
+	})
+	return sql, err
+}
+
+// pageClause renders LIMIT/OFFSET, leaving out the parts that are unset
+func pageClause(limit, offset int) string {
+	var sql string
+	if limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return sql
+}
+
+// searchConfig loads the search setup of a collection; Fields is empty when
+// the collection isn't searchable
+func searchConfig(ctx context.Context, tx pgx.Tx, table string) (SearchConfig, error) {
+	var cfg SearchConfig
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(search_fields, '[]'::jsonb), COALESCE(search_language, $2)
+		FROM _v_collections WHERE name = $1
+	`, table, DefaultSearchLanguage).Scan(&cfg.Fields, &cfg.Language)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cfg, nil
+	}
+	return cfg, err
+}
+
+// SearchRecords runs a full-text query over a searchable collection, respecting
+// RLS. Rows are ranked by ts_rank and carry the rank as _rank and highlighted
+// snippets of every search field the caller can read as _highlights.
+func (db *DB) SearchRecords(ctx context.Context, collectionName string, opts SearchOptions) ([]map[string]any, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	if strings.TrimSpace(opts.Query) == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidQuery)
+	}
+
+	var results []map[string]any
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		cfg, err := searchConfig(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		if len(cfg.Fields) == 0 {
+			return fmt.Errorf("%w: collection %s has no search fields", ErrInvalidQuery, collectionName)
+		}
+
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns, err = readableColumns(ctx, tx, collectionName, opts.Select.Role, columns)
+		if err != nil {
+			return err
+		}
+		clauses, args, err := buildFilterClauses(opts.Filters, columns, 2)
+		if err != nil {
+			return err
+		}
+		trashed, err := trashedClause(opts.Trashed, columns)
+		if err != nil {
+			return err
+		}
+		ownerWhere, ownerArgs := recordOwner{opts.OwnerField, opts.OwnerID}.clause("", len(args)+2)
+		args = append(args, ownerArgs...)
+		projection, err := buildProjection(ctx, tx, collectionName, opts.Select)
+		if err != nil {
+			return err
+		}
+
+		hidden, err := hiddenFields(ctx, tx, collectionName, opts.Select.Role)
+		if err != nil {
+			return err
+		}
+		var highlights []string
+		for _, field := range cfg.Fields {
+			if hidden[field] {
+				continue
+			}
+			highlights = append(highlights, fmt.Sprintf("'%s', ts_headline('%s', coalesce(t0.%s::text, ''), s._q, '%s')",
+				field, cfg.Language, field, headlineOptions))
+		}
+
+		// Rank and page on ids first so snippets are only built for returned rows
+		// #nosec G201
+		query := fmt.Sprintf(`
+			SELECT %s, s._rank, json_build_object(%s) AS _highlights
+			FROM (
+				SELECT id, ts_rank(%s, _q) AS _rank, _q
+				FROM %s, websearch_to_tsquery('%s', $1) AS _q
+				WHERE %s @@ _q AND %s%s
+				ORDER BY _rank DESC, id%s
+			) s
+			JOIN %s t0 ON t0.id = s.id
+			ORDER BY s._rank DESC, s.id`,
+			projection, strings.Join(highlights, ", "),
+			SearchVectorColumn, QuoteTable(collectionName), cfg.Language,
+			SearchVectorColumn, strings.Join(append([]string{trashed}, clauses...), " AND "), ownerWhere,
+			pageClause(opts.Limit, opts.Offset),
+			QuoteTable(collectionName))
+
+		rows, err := tx.Query(ctx, query, append([]any{opts.Query}, args...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		results, err = rowsToMaps(rows)
+		return err
+	})
+	return results, err
+}
@@ -0,0 +1,40 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSearchSQL(t *testing.T) {
+	stmts, err := BuildSearchSQL("products", SearchConfig{
+		Fields:   []string{"name", "summary", "brand", "notes", "body"},
+		Language: "english",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE \"public\".\"products\" ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (" +
+			"setweight(to_tsvector('english', coalesce(name::text, '')), 'A') || " +
+			"setweight(to_tsvector('english', coalesce(summary::text, '')), 'B') || " +
+			"setweight(to_tsvector('english', coalesce(brand::text, '')), 'C') || " +
+			"setweight(to_tsvector('english', coalesce(notes::text, '')), 'D') || " +
+			"setweight(to_tsvector('english', coalesce(body::text, '')), 'D')) STORED",
+		"CREATE INDEX products_search_idx ON \"public\".\"products\" USING GIN (search_vector)",
+	}, stmts)
+
+	stmts, err = BuildSearchSQL("products", SearchConfig{})
+	assert.NoError(t, err)
+	assert.Empty(t, stmts)
+}
+
+func TestBuildSearchSQLErrors(t *testing.T) {
+	invalid := []SearchConfig{
+		{Fields: []string{"name"}, Language: "english'); DROP TABLE x; --"},
+		{Fields: []string{"name, id"}, Language: "english"},
+	}
+	for _, cfg := range invalid {
+		_, err := BuildSearchSQL("products", cfg)
+		assert.True(t, errors.Is(err, ErrInvalidQuery), "%+v", cfg)
+	}
+}
@@ -0,0 +1,364 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxEmbedDepth limits how deeply relations can be nested in a select list
+const maxEmbedDepth = 3
+
+// ErrEmbedDenied is returned when the caller may not read an embedded collection
+var ErrEmbedDenied = errors.New("embed denied")
+
+// Selection describes which columns and embedded relations a read returns
+type Selection struct {
+	// Expr is a PostgREST-style select list, e.g. "id,title,author:user_id(email,username)".
+	// An empty Expr selects every column.
+	Expr string
+	// CanEmbed optionally vets each related collection before it is embedded.
+	// It runs in the transaction of the read.
+	CanEmbed func(ctx context.Context, tx pgx.Tx, table string) bool
+	// Role is the caller's role for field access rules; fields hidden from it
+	// are left out. Empty reads every field.
+	Role string
+}
+
+// selectItem is one entry of a parsed select list
+type selectItem struct {
+	Alias    string
+	Name     string
+	Embed    bool
+	Children []selectItem
+}
+
+func (it selectItem) key() string {
+	if it.Alias != "" {
+		return it.Alias
+	}
+	return it.Name
+}
+
+// parseSelect parses a select list such as "id,author:user_id(email),comments(*)"
+func parseSelect(expr string) ([]selectItem, error) {
+	items, rest, err := parseSelectList(expr, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("%w: unexpected %q in select", ErrInvalidQuery, rest)
+	}
+	return items, nil
+}
+
+func parseSelectList(s string, depth int) ([]selectItem, string, error) {
+	if depth > maxEmbedDepth {
+		return nil, "", fmt.Errorf("%w: select nests relations deeper than %d levels", ErrInvalidQuery, maxEmbedDepth)
+	}
+
+	var items []selectItem
+	for {
+		s = strings.TrimSpace(s)
+		end := strings.IndexAny(s, ",()")
+		if end < 0 {
+			end = len(s)
+		}
+
+		token := strings.TrimSpace(s[:end])
+		s = s[end:]
+
+		var item selectItem
+		if alias, name, ok := strings.Cut(token, ":"); ok {
+			item.Alias, item.Name = strings.TrimSpace(alias), strings.TrimSpace(name)
+			if !IsValidIdentifier(item.Alias) {
+				return nil, "", fmt.Errorf("%w: invalid alias %q in select", ErrInvalidQuery, item.Alias)
+			}
+		} else {
+			item.Name = token
+		}
+
+		if item.Name != "*" && !IsValidIdentifier(item.Name) {
+			return nil, "", fmt.Errorf("%w: invalid column %q in select", ErrInvalidQuery, item.Name)
+		}
+
+		if strings.HasPrefix(s, "(") {
+			if item.Name == "*" {
+				return nil, "", fmt.Errorf("%w: cannot embed '*'", ErrInvalidQuery)
+			}
+			children, rest, err := parseSelectList(s[1:], depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			if !strings.HasPrefix(rest, ")") {
+				return nil, "", fmt.Errorf("%w: unbalanced parentheses in select", ErrInvalidQuery)
+			}
+			item.Embed = true
+			item.Children = children
+			s = rest[1:]
+		}
+
+		items = append(items, item)
+
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, ",") {
+			return items, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+// selectBuilder turns parsed select items into SQL, resolving embeds through foreign keys
+type selectBuilder struct {
+	ctx      context.Context
+	q        pgx.Tx
+	canEmbed func(ctx context.Context, tx pgx.Tx, table string) bool
+	role     string
+	rels     []TableRelationship
+	columns  map[string]map[string]string
+	links    map[string]map[string]FieldSchema
+	hidden   map[string]map[string]bool
+	aliasSeq int
+}
+
+func newSelectBuilder(ctx context.Context, q pgx.Tx, sel Selection) *selectBuilder {
+	return &selectBuilder{
+		ctx:      ctx,
+		q:        q,
+		canEmbed: sel.CanEmbed,
+		role:     sel.Role,
+		columns:  make(map[string]map[string]string),
+		links:    make(map[string]map[string]FieldSchema),
+		hidden:   make(map[string]map[string]bool),
+	}
+}
+
+// tableColumns returns the columns of table the caller can read
+func (b *selectBuilder) tableColumns(table string) (map[string]string, error) {
+	if cols, ok := b.columns[table]; ok {
+		return cols, nil
+	}
+	cols, err := tableColumns(b.ctx, b.q, table)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := b.hiddenFields(table)
+	if err != nil {
+		return nil, err
+	}
+	for name := range hidden {
+		delete(cols, name)
+	}
+	b.columns[table] = cols
+	return cols, nil
+}
+
+// linkFields returns the link fields of table the caller can read
+func (b *selectBuilder) linkFields(table string) (map[string]FieldSchema, error) {
+	if links, ok := b.links[table]; ok {
+		return links, nil
+	}
+	links, err := linkFields(b.ctx, b.q, table)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := b.hiddenFields(table)
+	if err != nil {
+		return nil, err
+	}
+	for name := range hidden {
+		delete(links, name)
+	}
+	b.links[table] = links
+	return links, nil
+}
+
+func (b *selectBuilder) hiddenFields(table string) (map[string]bool, error) {
+	if hidden, ok := b.hidden[table]; ok {
+		return hidden, nil
+	}
+	hidden, err := hiddenFields(b.ctx, b.q, table, b.role)
+	if err != nil {
+		return nil, err
+	}
+	b.hidden[table] = hidden
+	return hidden, nil
+}
+
+func (b *selectBuilder) relationships() ([]TableRelationship, error) {
+	if b.rels == nil {
+		rels, err := listRelationships(b.ctx, b.q)
+		if err != nil {
+			return nil, err
+		}
+		b.rels = rels
+	}
+	return b.rels, nil
+}
+
+func (b *selectBuilder) nextAlias() string {
+	b.aliasSeq++
+	return fmt.Sprintf("t%d", b.aliasSeq)
+}
+
+// projection renders the select list for table (referenced as alias) as SQL expressions
+func (b *selectBuilder) projection(table, alias string, items []selectItem) (string, error) {
+	cols, err := b.tableColumns(table)
+	if err != nil {
+		return "", err
+	}
+	links, err := b.linkFields(table)
+	if err != nil {
+		return "", err
+	}
+
+	var exprs []string
+	for _, it := range items {
+		switch {
+		case it.Name == "*":
+			hidden, err := b.hiddenFields(table)
+			if err != nil {
+				return "", err
+			}
+			if len(hidden) == 0 {
+				exprs = append(exprs, alias+".*")
+			} else {
+				// Spell out the readable columns
+				for _, name := range slices.Sorted(maps.Keys(cols)) {
+					exprs = append(exprs, alias+"."+name)
+				}
+			}
+			for _, name := range slices.Sorted(maps.Keys(links)) {
+				exprs = append(exprs, fmt.Sprintf("%s AS %s", linkProjection(table, alias, links[name]), name))
+			}
+		case !it.Embed && links[it.Name].Name != "":
+			exprs = append(exprs, fmt.Sprintf("%s AS %s", linkProjection(table, alias, links[it.Name]), it.key()))
+		case it.Embed:
+			sub, err := b.embed(table, alias, it)
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, fmt.Sprintf("%s AS %s", sub, it.key()))
+		default:
+			if _, ok := cols[it.Name]; !ok {
+				return "", fmt.Errorf("%w: unknown column %q on %s", ErrInvalidQuery, it.Name, table)
+			}
+			exprs = append(exprs, fmt.Sprintf("%s.%s AS %s", alias, it.Name, it.key()))
+		}
+	}
+
+	if len(exprs) == 0 {
+		return "", fmt.Errorf("%w: empty select", ErrInvalidQuery)
+	}
+	return strings.Join(exprs, ", "), nil
+}
+
+// embed renders a correlated subquery that returns the related row (many-to-one)
+// as a JSON object or the related rows (one-to-many) as a JSON array.
+func (b *selectBuilder) embed(table, alias string, it selectItem) (string, error) {
+	rels, err := b.relationships()
+	if err != nil {
+		return "", err
+	}
+
+	var matches []TableRelationship
+	var toMany []bool
+	for _, r := range rels {
+		switch {
+		case r.FromTable == table && r.FromCol == it.Name:
+			// Hint by FK column, e.g. author:user_id(...)
+			matches = append(matches, r)
+			toMany = append(toMany, false)
+		case r.FromTable == table && r.ToTable == it.Name:
+			matches = append(matches, r)
+			toMany = append(toMany, false)
+		case r.ToTable == table && r.FromTable == it.Name:
+			matches = append(matches, r)
+			toMany = append(toMany, true)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%w: no relationship between %s and %s", ErrInvalidQuery, table, it.Name)
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("%w: relationship between %s and %s is ambiguous, embed by foreign key column instead", ErrInvalidQuery, table, it.Name)
+	}
+
+	rel, many := matches[0], toMany[0]
+	target, targetCol, localCol := rel.ToTable, rel.ToCol, rel.FromCol
+	if many {
+		target, targetCol, localCol = rel.FromTable, rel.FromCol, rel.ToCol
+	}
+
+	// Embedding through a hidden foreign key would reveal it
+	hidden, err := b.hiddenFields(table)
+	if err != nil {
+		return "", err
+	}
+	if !many && hidden[localCol] {
+		return "", fmt.Errorf("%w: no relationship between %s and %s", ErrInvalidQuery, table, it.Name)
+	}
+
+	if strings.HasPrefix(target, "_v_") {
+		return "", fmt.Errorf("%w: cannot embed system table %s", ErrInvalidQuery, target)
+	}
+	if b.canEmbed != nil && !b.canEmbed(b.ctx, b.q, target) {
+		return "", fmt.Errorf("%w: access to %s denied", ErrEmbedDenied, target)
+	}
+
+	targetCols, err := b.tableColumns(target)
+	if err != nil {
+		return "", err
+	}
+
+	sub := b.nextAlias()
+	proj, err := b.projection(target, sub, it.Children)
+	if err != nil {
+		return "", err
+	}
+
+	where := fmt.Sprintf("%s.%s = %s.%s", sub, targetCol, alias, localCol)
+	if _, ok := targetCols["deleted_at"]; ok {
+		where += fmt.Sprintf(" AND %s.deleted_at IS NULL", sub)
+	}
+
+	row := "r" + sub
+	if many {
+		return fmt.Sprintf("(SELECT COALESCE(json_agg(%s), '[]'::json) FROM (SELECT %s FROM %s %s WHERE %s) %s)",
+			row, proj, QuoteTable(target), sub, where, row), nil
+	}
+	return fmt.Sprintf("(SELECT row_to_json(%s) FROM (SELECT %s FROM %s %s WHERE %s LIMIT 1) %s)",
+		row, proj, QuoteTable(target), sub, where, row), nil
+}
+
+// buildProjection returns the SELECT list for table aliased as t0
+func buildProjection(ctx context.Context, q pgx.Tx, table string, sel Selection) (string, error) {
+	if strings.TrimSpace(sel.Expr) == "" && sel.Role == "" {
+		// Link fields live in join tables, so t0.* misses them
+		links, err := linkFields(ctx, q, table)
+		if err != nil {
+			return "", err
+		}
+		proj := "t0.*"
+		for _, name := range slices.Sorted(maps.Keys(links)) {
+			proj += fmt.Sprintf(", %s AS %s", linkProjection(table, "t0", links[name]), name)
+		}
+		return proj, nil
+	}
+
+	items := []selectItem{{Name: "*"}}
+	if strings.TrimSpace(sel.Expr) != "" {
+		var err error
+		if items, err = parseSelect(sel.Expr); err != nil {
+			return "", err
+		}
+	}
+
+	return newSelectBuilder(ctx, q, sel).projection(table, "t0", items)
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelect(t *testing.T) {
+	items, err := parseSelect("id, title,author:user_id(email,username),comments(*,author:user_id(email))")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []selectItem{
+		{Name: "id"},
+		{Name: "title"},
+		{Alias: "author", Name: "user_id", Embed: true, Children: []selectItem{
+			{Name: "email"},
+			{Name: "username"},
+		}},
+		{Name: "comments", Embed: true, Children: []selectItem{
+			{Name: "*"},
+			{Alias: "author", Name: "user_id", Embed: true, Children: []selectItem{
+				{Name: "email"},
+			}},
+		}},
+	}, items)
+}
+
+func TestParseSelectErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"Unbalanced", "id,author(email"},
+		{"Trailing paren", "id)"},
+		{"Empty item", "id,,title"},
+		{"Injection", "id;drop table users"},
+		{"Star embed", "*(id)"},
+		{"Too deep", "a(b(c(d(e(id)))))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSelect(tt.expr)
+			assert.True(t, errors.Is(err, ErrInvalidQuery), "got %v", err)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRecordNotFound is returned when a record does not exist or is not in the
+// state an operation needs (e.g. restoring a record that isn't trashed)
+var ErrRecordNotFound = errors.New("record not found")
+
+// Trash visibility modes accepted by ListOptions.Trashed
+const (
+	TrashedExclude = ""
+	TrashedOnly    = "only"
+	TrashedWith    = "with"
+)
+
+// trashedClause returns the soft-delete condition for a trash visibility mode.
+// Relations without deleted_at (e.g. views) have no trash.
+func trashedClause(mode string, columns map[string]string) (string, error) {
+	if _, ok := columns["deleted_at"]; !ok {
+		switch mode {
+		case TrashedExclude, TrashedWith:
+			return "TRUE", nil
+		case TrashedOnly:
+			return "FALSE", nil
+		}
+	}
+
+	switch mode {
+	case TrashedExclude:
+		return "deleted_at IS NULL", nil
+	case TrashedOnly:
+		return "deleted_at IS NOT NULL", nil
+	case TrashedWith:
+		return "TRUE", nil
+	default:
+		return "", fmt.Errorf("%w: trashed must be 'only' or 'with'", ErrInvalidQuery)
+	}
+}
+
+// RestoreRecord brings a soft-deleted record back, respecting RLS
+func (db *DB) RestoreRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		ownerWhere, ownerArgs := recordOwner{ownerField, ownerID}.clause("", 2)
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL%s", QuoteTable(collectionName), ownerWhere)
+		tag, err := tx.Exec(ctx, query, append([]any{id}, ownerArgs...)...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// PurgeRecord permanently deletes a record whether or not it is trashed, respecting RLS.
+// Versions work as in UpdateRecord.
+func (db *DB) PurgeRecord(ctx context.Context, collectionName, id string, ownerField, ownerID string, versions []time.Time) error {
+	if !ValidCollectionName(collectionName) {
+		return fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+
+	owner := recordOwner{ownerField, ownerID}
+	return db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		versionWhere, versionArgs, err := versionClause(ctx, tx, collectionName, versions, 2)
+		if err != nil {
+			return err
+		}
+		ownerWhere, ownerArgs := owner.clause("", 2+len(versionArgs))
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE id = $1%s%s", QuoteTable(collectionName), versionWhere, ownerWhere)
+		args := append(append([]any{id}, versionArgs...), ownerArgs...)
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return missedWriteError(ctx, tx, collectionName, id, "TRUE", owner, versions)
+		}
+		return nil
+	})
+}
+
+// PurgeExpiredRecords permanently deletes rows that have been in the trash longer
+// than their collection's retention_days. It returns the rows purged per collection.
+func (db *DB) PurgeExpiredRecords(ctx context.Context) (map[string]int64, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.name, c.retention_days
+		FROM _v_collections c
+		JOIN information_schema.columns col
+		  ON collection_name(col.table_schema, col.table_name) = c.name AND col.column_name = 'deleted_at'
+		WHERE c.retention_days > 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var days int
+		if err := rows.Scan(&name, &days); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		policies[name] = days
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	purged := make(map[string]int64)
+	for name, days := range policies {
+		if !ValidCollectionName(name) {
+			continue
+		}
+		// #nosec G201
+		query := fmt.Sprintf("DELETE FROM %s WHERE deleted_at < NOW() - make_interval(days => $1)", QuoteTable(name))
+		tag, err := db.Pool.Exec(ctx, query, days)
+		if err != nil {
+			// Keep purging the remaining collections
+			log.Printf("⚠️ Retention purge failed for %s: %v", name, err)
+			continue
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			purged[name] = n
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashedClause(t *testing.T) {
+	tests := map[string]string{
+		TrashedExclude: "deleted_at IS NULL",
+		TrashedOnly:    "deleted_at IS NOT NULL",
+		TrashedWith:    "TRUE",
+	}
+	columns := map[string]string{"id": "uuid", "deleted_at": "timestamp with time zone"}
+	for mode, want := range tests {
+		got, err := trashedClause(mode, columns)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := trashedClause("all", columns)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+	// Without deleted_at nothing is ever trashed
+	noTrash := map[string]string{"id": "uuid"}
+	got, _ := trashedClause(TrashedExclude, noTrash)
+	assert.Equal(t, "TRUE", got)
+	got, _ = trashedClause(TrashedOnly, noTrash)
+	assert.Equal(t, "FALSE", got)
+}
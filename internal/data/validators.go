@@ -0,0 +1,232 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidSchema is returned when a field definition can't be turned into a column.
+// Handlers map it to 400 Bad Request.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// isNumericType reports whether min/max bound the value of a field type
+func isNumericType(fieldType string) bool {
+	switch strings.ToLower(fieldType) {
+	case "int2", "int4", "int8", "float4", "float8", "numeric", "number", "integer":
+		return true
+	}
+	return false
+}
+
+// isTextType reports whether min/max bound the length of a field type
+func isTextType(fieldType string) bool {
+	switch strings.ToLower(fieldType) {
+	case "text", "varchar", "string":
+		return true
+	}
+	return false
+}
+
+// validateFieldOptions checks that the validators set on a field fit its type
+func validateFieldOptions(field FieldSchema) error {
+	numeric, text := isNumericType(field.Type), isTextType(field.Type)
+
+	if (field.Min != nil || field.Max != nil) && !numeric && !text {
+		return fmt.Errorf("%w: min/max are not supported for %s field %s", ErrInvalidSchema, field.Type, field.Name)
+	}
+	if text && ((field.Min != nil && *field.Min < 0) || (field.Max != nil && *field.Max < 0)) {
+		return fmt.Errorf("%w: length bounds of %s cannot be negative", ErrInvalidSchema, field.Name)
+	}
+	if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+		return fmt.Errorf("%w: min of %s is greater than max", ErrInvalidSchema, field.Name)
+	}
+
+	if field.Pattern != "" {
+		if !text {
+			return fmt.Errorf("%w: pattern is only supported for text fields", ErrInvalidSchema)
+		}
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			return fmt.Errorf("%w: invalid pattern for %s: %v", ErrInvalidSchema, field.Name, err)
+		}
+	}
+
+	if len(field.Enum) > 0 && !numeric && !text {
+		return fmt.Errorf("%w: enum is not supported for %s field %s", ErrInvalidSchema, field.Type, field.Name)
+	}
+	if err := field.FieldAccess.validate(field.Name); err != nil {
+		return err
+	}
+
+	if len(field.Options) > 0 && strings.ToLower(field.Type) != FieldSelect {
+		return fmt.Errorf("%w: options are only supported for select fields", ErrInvalidSchema)
+	}
+
+	if len(field.JSONSchema) > 0 {
+		if t := strings.ToLower(field.Type); t != "json" && t != "jsonb" {
+			return fmt.Errorf("%w: json_schema is only supported for json fields", ErrInvalidSchema)
+		}
+		if _, err := parseJSONSchema(field.JSONSchema); err != nil {
+			return fmt.Errorf("%w: invalid json_schema for %s: %v", ErrInvalidSchema, field.Name, err)
+		}
+	}
+	if numeric {
+		for _, v := range field.Enum {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Errorf("%w: enum value %q of %s is not a number", ErrInvalidSchema, v, field.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldConstraintSQL renders the column constraints for a field's validators,
+// e.g. " UNIQUE CHECK (price >= 0)". Postgres names them <table>_<column>_key
+// and <table>_<column>_check, which ConstraintField relies on.
+func fieldConstraintSQL(field FieldSchema) (string, error) {
+	if err := validateFieldOptions(field); err != nil {
+		return "", err
+	}
+
+	var checks []string
+	target := field.Name
+	if isTextType(field.Type) {
+		target = fmt.Sprintf("char_length(%s)", field.Name)
+	}
+	if field.Min != nil {
+		checks = append(checks, fmt.Sprintf("%s >= %s", target, formatNumber(*field.Min)))
+	}
+	if field.Max != nil {
+		checks = append(checks, fmt.Sprintf("%s <= %s", target, formatNumber(*field.Max)))
+	}
+	if field.Pattern != "" {
+		checks = append(checks, fmt.Sprintf("%s ~ %s", field.Name, quoteLiteral(field.Pattern)))
+	}
+	if isVectorField(field) && field.VectorStorage != VectorStoragePGVector {
+		// vector(n) checks its size itself, FLOAT4[] needs telling
+		checks = append(checks, fmt.Sprintf("cardinality(%s) = %d", field.Name, field.Dimensions))
+	}
+	if allowed := field.allowedValues(); len(allowed) > 0 {
+		values := make([]string, len(allowed))
+		for i, v := range allowed {
+			values[i] = quoteLiteral(v)
+		}
+		checks = append(checks, fmt.Sprintf("%s IN (%s)", field.Name, strings.Join(values, ", ")))
+	}
+
+	var sql string
+	if field.Unique {
+		sql += " UNIQUE"
+	}
+	if len(checks) > 0 {
+		sql += fmt.Sprintf(" CHECK (%s)", strings.Join(checks, " AND "))
+	}
+	return sql, nil
+}
+
+// CheckValue applies a field's validators to an already coerced value, so
+// clients get a specific message before the CHECK constraint is hit.
+func (f FieldSchema) CheckValue(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	if isTextType(f.Type) {
+		s, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		n := float64(utf8.RuneCountInString(s))
+		if f.Min != nil && n < *f.Min {
+			return fmt.Errorf("must be at least %s characters", formatNumber(*f.Min))
+		}
+		if f.Max != nil && n > *f.Max {
+			return fmt.Errorf("must be at most %s characters", formatNumber(*f.Max))
+		}
+		if f.Pattern != "" {
+			if re, err := regexp.Compile(f.Pattern); err == nil && !re.MatchString(s) {
+				return fmt.Errorf("must match pattern %s", f.Pattern)
+			}
+		}
+	}
+
+	if isNumericType(f.Type) {
+		var n float64
+		switch v := value.(type) {
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		case string:
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil
+			}
+			n = parsed
+		default:
+			return nil
+		}
+		if f.Min != nil && n < *f.Min {
+			return fmt.Errorf("must be at least %s", formatNumber(*f.Min))
+		}
+		if f.Max != nil && n > *f.Max {
+			return fmt.Errorf("must be at most %s", formatNumber(*f.Max))
+		}
+	}
+
+	if allowed := f.allowedValues(); len(allowed) > 0 && !f.inEnum(value) {
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+
+	if len(f.JSONSchema) > 0 {
+		if err := checkJSONValue(f.JSONSchema, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f FieldSchema) inEnum(value any) bool {
+	for _, allowed := range f.allowedValues() {
+		if isNumericType(f.Type) {
+			a, errA := strconv.ParseFloat(allowed, 64)
+			b, errB := strconv.ParseFloat(fmt.Sprint(value), 64)
+			if errA == nil && errB == nil && a == b {
+				return true
+			}
+			continue
+		}
+		if fmt.Sprint(value) == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ConstraintField recovers the column behind a constraint named by Postgres'
+// default <table>_<column>_{key,check} convention. It returns "" if unknown.
+func ConstraintField(table, constraint string) string {
+	name, ok := strings.CutPrefix(constraint, table+"_")
+	if !ok {
+		return ""
+	}
+	for _, suffix := range []string{"_key", "_check", "_fkey"} {
+		if field, ok := strings.CutSuffix(name, suffix); ok {
+			return field
+		}
+	}
+	return ""
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
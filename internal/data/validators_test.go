@@ -0,0 +1,92 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+func TestFieldConstraintSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FieldSchema
+		want  string
+	}{
+		{"No validators", FieldSchema{Name: "title", Type: "text"}, ""},
+		{"Unique", FieldSchema{Name: "slug", Type: "text", Unique: true}, " UNIQUE"},
+		{"Numeric range", FieldSchema{Name: "price", Type: "numeric", Min: ptr(0), Max: ptr(99.5)}, " CHECK (price >= 0 AND price <= 99.5)"},
+		{"Text length", FieldSchema{Name: "code", Type: "varchar", Min: ptr(2)}, " CHECK (char_length(code) >= 2)"},
+		{"Pattern", FieldSchema{Name: "email", Type: "text", Pattern: `^[^@]+@[^@]+$`}, ` CHECK (email ~ '^[^@]+@[^@]+$')`},
+		{"Enum", FieldSchema{Name: "status", Type: "text", Enum: []string{"draft", "it's live"}}, " CHECK (status IN ('draft', 'it''s live'))"},
+		{"Unique and check", FieldSchema{Name: "rank", Type: "int4", Unique: true, Enum: []string{"1", "2"}}, " UNIQUE CHECK (rank IN ('1', '2'))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fieldConstraintSQL(tt.field)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFieldConstraintSQLErrors(t *testing.T) {
+	invalid := []FieldSchema{
+		{Name: "flag", Type: "bool", Min: ptr(1)},
+		{Name: "code", Type: "text", Min: ptr(-1)},
+		{Name: "price", Type: "numeric", Min: ptr(10), Max: ptr(1)},
+		{Name: "views", Type: "int4", Pattern: "^1"},
+		{Name: "email", Type: "text", Pattern: "("},
+		{Name: "meta", Type: "jsonb", Enum: []string{"a"}},
+		{Name: "views", Type: "int4", Enum: []string{"many"}},
+	}
+
+	for _, field := range invalid {
+		_, err := fieldConstraintSQL(field)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), "%+v", field)
+	}
+}
+
+func TestBuildCreateTableSQLConstraints(t *testing.T) {
+	sql, err := BuildCreateTableSQL("products", "", []FieldSchema{
+		{Name: "sku", Type: "text", Required: true, Unique: true},
+		{Name: "price", Type: "numeric", Min: ptr(0)},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "sku TEXT NOT NULL UNIQUE")
+	assert.Contains(t, sql, "price NUMERIC CHECK (price >= 0)")
+}
+
+func TestCheckValue(t *testing.T) {
+	status := FieldSchema{Name: "status", Type: "text", Enum: []string{"draft", "published"}}
+	assert.NoError(t, status.CheckValue("draft"))
+	assert.EqualError(t, status.CheckValue("archived"), "must be one of draft, published")
+
+	name := FieldSchema{Name: "name", Type: "text", Min: ptr(2), Max: ptr(4)}
+	assert.NoError(t, name.CheckValue("äöü"))
+	assert.EqualError(t, name.CheckValue("a"), "must be at least 2 characters")
+	assert.EqualError(t, name.CheckValue("abcde"), "must be at most 4 characters")
+
+	email := FieldSchema{Name: "email", Type: "text", Pattern: `^[^@]+@[^@]+$`}
+	assert.Error(t, email.CheckValue("nope"))
+
+	price := FieldSchema{Name: "price", Type: "numeric", Min: ptr(0)}
+	assert.NoError(t, price.CheckValue("0.00"))
+	assert.EqualError(t, price.CheckValue("-1.5"), "must be at least 0")
+
+	rank := FieldSchema{Name: "rank", Type: "int4", Enum: []string{"1", "2"}}
+	assert.NoError(t, rank.CheckValue(int64(2)))
+	assert.Error(t, rank.CheckValue(int64(3)))
+
+	assert.NoError(t, price.CheckValue(nil))
+}
+
+func TestConstraintField(t *testing.T) {
+	assert.Equal(t, "slug", ConstraintField("posts", "posts_slug_key"))
+	assert.Equal(t, "unit_price", ConstraintField("order_items", "order_items_unit_price_check"))
+	assert.Equal(t, "", ConstraintField("posts", "posts_pkey"))
+	assert.Equal(t, "", ConstraintField("posts", "custom_constraint"))
+}
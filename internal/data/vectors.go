@@ -0,0 +1,396 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FieldVector holds a fixed-size embedding; declare it as {"type": "vector",
+// "dimensions": n} or the shorthand {"type": "vector(n)"}
+const FieldVector = "vector"
+
+// maxVectorDimensions is pgvector's limit for the vector type
+const maxVectorDimensions = 16000
+
+// How vector fields are stored
+const (
+	VectorStoragePGVector = "pgvector" // pgvector's vector(n), required for ANN indexes
+	VectorStorageArray    = "array"    // FLOAT4[] with SQL distance functions
+)
+
+// Distance metrics of similarity search, smaller is closer for all of them
+const (
+	MetricCosine       = "cosine"
+	MetricL2           = "l2"
+	MetricInnerProduct = "inner_product" // reported negated, as pgvector does
+)
+
+// ANN index methods available with pgvector
+const (
+	IndexHNSW    = "hnsw"
+	IndexIVFFlat = "ivfflat"
+)
+
+// vectorMetrics maps a metric to its pgvector operator, operator class and
+// the fallback function for FLOAT4[] storage
+var vectorMetrics = map[string]struct{ op, opclass, fn string }{
+	MetricCosine:       {"<=>", "vector_cosine_ops", "array_cosine_distance"},
+	MetricL2:           {"<->", "vector_l2_ops", "array_l2_distance"},
+	MetricInnerProduct: {"<#>", "vector_ip_ops", "array_negative_inner_product"},
+}
+
+// isVectorField reports whether a field holds a vector
+func isVectorField(f FieldSchema) bool {
+	return strings.ToLower(f.Type) == FieldVector
+}
+
+// normalizeVectorType expands the vector(n) shorthand into Type and Dimensions
+func normalizeVectorType(f *FieldSchema) error {
+	inner, ok := strings.CutPrefix(strings.ToLower(f.Type), "vector(")
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(inner, ")"))
+	if err != nil || !strings.HasSuffix(inner, ")") {
+		return fmt.Errorf("%w: invalid vector type %q", ErrInvalidSchema, f.Type)
+	}
+	f.Type, f.Dimensions = FieldVector, n
+	return nil
+}
+
+// vectorColumnSQL renders the column type of a vector field. The dimension
+// check of FLOAT4[] storage is added with the other CHECK constraints.
+func vectorColumnSQL(f FieldSchema) (string, error) {
+	if f.Dimensions < 1 || f.Dimensions > maxVectorDimensions {
+		return "", fmt.Errorf("%w: vector field %s needs dimensions between 1 and %d", ErrInvalidSchema, f.Name, maxVectorDimensions)
+	}
+	switch f.VectorStorage {
+	case VectorStoragePGVector:
+		return fmt.Sprintf("vector(%d)", f.Dimensions), nil
+	case "", VectorStorageArray:
+		return "FLOAT4[]", nil
+	}
+	return "", fmt.Errorf("%w: vector_storage of %s must be 'pgvector' or 'array'", ErrInvalidSchema, f.Name)
+}
+
+// HasPGVector reports whether the pgvector extension is installed
+func (db *DB) HasPGVector(ctx context.Context) bool {
+	var installed bool
+	_ = db.Pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')").Scan(&installed)
+	return installed
+}
+
+// ResolveVectorFields expands vector(n) types and picks the storage of vector
+// fields that don't name one: pgvector when installed, FLOAT4[] otherwise
+func (db *DB) ResolveVectorFields(ctx context.Context, fields []FieldSchema) error {
+	var pgvector *bool
+	for i := range fields {
+		if err := normalizeVectorType(&fields[i]); err != nil {
+			return err
+		}
+		if !isVectorField(fields[i]) {
+			continue
+		}
+		if pgvector == nil {
+			installed := db.HasPGVector(ctx)
+			pgvector = &installed
+		}
+		switch fields[i].VectorStorage {
+		case "":
+			fields[i].VectorStorage = VectorStorageArray
+			if *pgvector {
+				fields[i].VectorStorage = VectorStoragePGVector
+			}
+		case VectorStoragePGVector:
+			if !*pgvector {
+				return fmt.Errorf("%w: vector_storage pgvector needs the vector extension", ErrInvalidSchema)
+			}
+		}
+	}
+	return nil
+}
+
+// FormatVector renders a vector in pgvector's text form, e.g. "[1,0.5,2]"
+func FormatVector(v []float64) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(x, 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// parseVector reads pgvector's text form back into numbers
+func parseVector(s string) ([]float64, bool) {
+	inner, ok := strings.CutPrefix(s, "[")
+	if !ok || !strings.HasSuffix(inner, "]") {
+		return nil, false
+	}
+	inner = strings.TrimSuffix(inner, "]")
+	if inner == "" {
+		return []float64{}, true
+	}
+	parts := strings.Split(inner, ",")
+	vector := make([]float64, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, false
+		}
+		vector[i] = f
+	}
+	return vector, true
+}
+
+// SimilarOptions describes a nearest-neighbor query over a vector field
+type SimilarOptions struct {
+	Field   string
+	Vector  []float64
+	K       int
+	Metric  string // MetricCosine (default), MetricL2 or MetricInnerProduct
+	Filters map[string][]string
+	Trashed string
+	Select  Selection
+	// OwnerField and OwnerID work as in ListOptions
+	OwnerField string
+	OwnerID    string
+}
+
+// storedField returns the definition of one field of a managed collection
+func storedField(ctx context.Context, q querier, collectionName, field string) (FieldSchema, error) {
+	rows, err := q.Query(ctx, `
+		SELECT f FROM _v_collections c, jsonb_array_elements(c.schema_def) f
+		WHERE c.name = $1 AND f->>'name' = $2
+	`, collectionName, field)
+	if err != nil {
+		return FieldSchema{}, err
+	}
+	defer rows.Close()
+
+	var def FieldSchema
+	if rows.Next() {
+		if err := rows.Scan(&def); err != nil {
+			return def, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return def, err
+	}
+	if def.Name == "" {
+		return def, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+	}
+	return def, nil
+}
+
+// distanceSQL renders the distance between a vector field of t0 and the query
+// vector $1, along with the value to bind to $1
+func distanceSQL(field FieldSchema, metric string, vector []float64) (string, any, error) {
+	m, ok := vectorMetrics[metric]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: metric must be 'cosine', 'l2' or 'inner_product'", ErrInvalidQuery)
+	}
+	if len(vector) != field.Dimensions {
+		return "", nil, fmt.Errorf("%w: %s holds %d dimensions, the query vector has %d", ErrInvalidQuery, field.Name, field.Dimensions, len(vector))
+	}
+
+	if field.VectorStorage == VectorStoragePGVector {
+		return fmt.Sprintf("(t0.%s %s $1::vector)", field.Name, m.op), FormatVector(vector), nil
+	}
+	return fmt.Sprintf("%s(t0.%s, $1::float4[])", m.fn, field.Name), vector, nil
+}
+
+// SimilarRecords returns the K records whose vector field is closest to
+// opts.Vector, respecting RLS. Each record carries its distance as _distance.
+func (db *DB) SimilarRecords(ctx context.Context, collectionName string, opts SimilarOptions) ([]map[string]any, error) {
+	if !ValidCollectionName(collectionName) {
+		return nil, fmt.Errorf("invalid collection name: %s", collectionName)
+	}
+	if opts.Metric == "" {
+		opts.Metric = MetricCosine
+	}
+	if opts.K < 1 {
+		return nil, fmt.Errorf("%w: k must be a positive integer", ErrInvalidQuery)
+	}
+
+	var results []map[string]any
+	err := db.WithTransactionAndRLS(ctx, func(tx pgx.Tx) error {
+		field, err := storedField(ctx, tx, collectionName, opts.Field)
+		if err != nil {
+			return err
+		}
+		// Ordering by a hidden vector would reveal how close it is to the query
+		hidden, err := hiddenFields(ctx, tx, collectionName, opts.Select.Role)
+		if err != nil {
+			return err
+		}
+		if hidden[field.Name] {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, opts.Field)
+		}
+		if !isVectorField(field) {
+			return fmt.Errorf("%w: %s is not a vector field", ErrInvalidQuery, opts.Field)
+		}
+		distance, vectorArg, err := distanceSQL(field, opts.Metric, opts.Vector)
+		if err != nil {
+			return err
+		}
+
+		columns, err := tableColumns(ctx, tx, collectionName)
+		if err != nil {
+			return err
+		}
+		columns, err = readableColumns(ctx, tx, collectionName, opts.Select.Role, columns)
+		if err != nil {
+			return err
+		}
+		clauses, args, err := buildFilterClauses(opts.Filters, columns, 2)
+		if err != nil {
+			return err
+		}
+		trashed, err := trashedClause(opts.Trashed, columns)
+		if err != nil {
+			return err
+		}
+		projection, err := buildProjection(ctx, tx, collectionName, opts.Select)
+		if err != nil {
+			return err
+		}
+
+		where := append([]string{fmt.Sprintf("t0.%s IS NOT NULL", field.Name), trashed}, clauses...)
+		ownerWhere, ownerArgs := recordOwner{opts.OwnerField, opts.OwnerID}.clause("t0", len(args)+2)
+		args = append(args, ownerArgs...)
+		// #nosec G201
+		query := fmt.Sprintf("SELECT %s, %s AS _distance FROM %s t0 WHERE %s%s ORDER BY %s LIMIT %d",
+			projection, distance, QuoteTable(collectionName), strings.Join(where, " AND "), ownerWhere, distance, opts.K)
+
+		rows, err := tx.Query(ctx, query, append([]any{vectorArg}, args...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		results, err = rowsToMaps(rows)
+		return err
+	})
+	return results, err
+}
+
+// VectorIndex describes an approximate nearest-neighbor index on a vector field
+type VectorIndex struct {
+	Field  string `json:"field"`
+	Method string `json:"method"` // IndexHNSW (default) or IndexIVFFlat
+	Metric string `json:"metric"` // the metric queries must use to hit the index
+	// Build parameters; zero keeps pgvector's defaults
+	M              int `json:"m,omitempty"`               // hnsw
+	EFConstruction int `json:"ef_construction,omitempty"` // hnsw
+	Lists          int `json:"lists,omitempty"`           // ivfflat
+}
+
+// VectorIndexName is the ANN index of a vector field; a field has at most one
+func VectorIndexName(table, field string) string {
+	return relName(table) + "_" + field + "_ann_idx"
+}
+
+// BuildVectorIndexSQL renders the CREATE INDEX statement of an ANN index
+func BuildVectorIndexSQL(table string, idx VectorIndex) (string, error) {
+	name := VectorIndexName(table, idx.Field)
+	if !ValidCollectionName(table) || !IsValidIdentifier(idx.Field) || !IsValidIdentifier(name) {
+		return "", fmt.Errorf("%w: invalid table or field name", ErrInvalidQuery)
+	}
+	m, ok := vectorMetrics[idx.Metric]
+	if !ok {
+		return "", fmt.Errorf("%w: metric must be 'cosine', 'l2' or 'inner_product'", ErrInvalidQuery)
+	}
+
+	var params []string
+	switch idx.Method {
+	case IndexHNSW:
+		if idx.M > 0 {
+			params = append(params, fmt.Sprintf("m = %d", idx.M))
+		}
+		if idx.EFConstruction > 0 {
+			params = append(params, fmt.Sprintf("ef_construction = %d", idx.EFConstruction))
+		}
+	case IndexIVFFlat:
+		if idx.Lists > 0 {
+			params = append(params, fmt.Sprintf("lists = %d", idx.Lists))
+		}
+	default:
+		return "", fmt.Errorf("%w: method must be 'hnsw' or 'ivfflat'", ErrInvalidQuery)
+	}
+
+	sql := fmt.Sprintf("CREATE INDEX %s ON %s USING %s (%s %s)", name, QuoteTable(table), idx.Method, idx.Field, m.opclass)
+	if len(params) > 0 {
+		sql += " WITH (" + strings.Join(params, ", ") + ")"
+	}
+	return sql, nil
+}
+
+// CreateVectorIndex replaces the ANN index of a pgvector field and returns the
+// DDL it ran
+func (db *DB) CreateVectorIndex(ctx context.Context, table string, idx VectorIndex) (string, error) {
+	if idx.Method == "" {
+		idx.Method = IndexHNSW
+	}
+	if idx.Metric == "" {
+		idx.Metric = MetricCosine
+	}
+	createSQL, err := BuildVectorIndexSQL(table, idx)
+	if err != nil {
+		return "", err
+	}
+	sql := fmt.Sprintf("DROP INDEX IF EXISTS %s;\n%s;", siblingSQL(table, VectorIndexName(table, idx.Field)), createSQL)
+
+	err = pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		field, err := storedField(ctx, tx, table, idx.Field)
+		if err != nil {
+			return err
+		}
+		if !isVectorField(field) || field.VectorStorage != VectorStoragePGVector {
+			return fmt.Errorf("%w: ANN indexes need a vector field stored with pgvector", ErrInvalidQuery)
+		}
+		_, err = tx.Exec(ctx, sql)
+		return err
+	})
+	return sql, err
+}
+
+// DropVectorIndex removes the ANN index of a vector field and returns the DDL it ran
+func (db *DB) DropVectorIndex(ctx context.Context, table, field string) (string, error) {
+	name := VectorIndexName(table, field)
+	if !ValidCollectionName(table) || !IsValidIdentifier(field) || !IsValidIdentifier(name) {
+		return "", fmt.Errorf("%w: invalid table or field name", ErrInvalidQuery)
+	}
+	sql := fmt.Sprintf("DROP INDEX IF EXISTS %s;", siblingSQL(table, name))
+	_, err := db.Pool.Exec(ctx, sql)
+	return sql, err
+}
+
+// ListVectorIndexes returns the ANN index definitions on a collection
+func (db *DB) ListVectorIndexes(ctx context.Context, table string) ([]string, error) {
+	ref, err := ParseTableRef(table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT indexdef FROM pg_indexes
+		WHERE schemaname = $1 AND tablename = $2 AND indexname LIKE '%\_ann\_idx'
+		ORDER BY indexname
+	`, ref.Schema, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []string
+	for rows.Next() {
+		var def string
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, rows.Err()
+}
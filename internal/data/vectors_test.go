@@ -0,0 +1,112 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeVectorType(t *testing.T) {
+	f := FieldSchema{Name: "embedding", Type: "vector(384)"}
+	assert.NoError(t, normalizeVectorType(&f))
+	assert.Equal(t, FieldVector, f.Type)
+	assert.Equal(t, 384, f.Dimensions)
+
+	f = FieldSchema{Name: "embedding", Type: "vector", Dimensions: 3}
+	assert.NoError(t, normalizeVectorType(&f))
+	assert.Equal(t, 3, f.Dimensions)
+
+	for _, typ := range []string{"vector(", "vector(abc)", "vector(3"} {
+		f := FieldSchema{Name: "embedding", Type: typ}
+		assert.True(t, errors.Is(normalizeVectorType(&f), ErrInvalidSchema), typ)
+	}
+}
+
+func TestBuildCreateTableSQLVector(t *testing.T) {
+	sql, err := BuildCreateTableSQL("docs", "", []FieldSchema{
+		{Name: "embedding", Type: "vector(3)"},
+		{Name: "fast", Type: "vector", Dimensions: 3, VectorStorage: VectorStoragePGVector},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "embedding FLOAT4[] CHECK (cardinality(embedding) = 3)")
+	assert.Contains(t, sql, "fast vector(3)")
+	assert.NotContains(t, sql, "cardinality(fast)")
+
+	invalid := [][]FieldSchema{
+		{{Name: "embedding", Type: "vector"}},
+		{{Name: "embedding", Type: "vector", Dimensions: maxVectorDimensions + 1}},
+		{{Name: "embedding", Type: "vector", Dimensions: 3, VectorStorage: "blob"}},
+	}
+	for _, fields := range invalid {
+		_, err := BuildCreateTableSQL("docs", "", fields)
+		assert.Error(t, err, "%+v", fields)
+	}
+}
+
+func TestDistanceSQL(t *testing.T) {
+	pgvector := FieldSchema{Name: "embedding", Type: FieldVector, Dimensions: 2, VectorStorage: VectorStoragePGVector}
+	array := FieldSchema{Name: "embedding", Type: FieldVector, Dimensions: 2, VectorStorage: VectorStorageArray}
+
+	sql, arg, err := distanceSQL(pgvector, MetricL2, []float64{1, 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, "(t0.embedding <-> $1::vector)", sql)
+	assert.Equal(t, "[1,0.5]", arg)
+
+	sql, arg, err = distanceSQL(array, MetricCosine, []float64{1, 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, "array_cosine_distance(t0.embedding, $1::float4[])", sql)
+	assert.Equal(t, []float64{1, 0.5}, arg)
+
+	_, _, err = distanceSQL(array, MetricCosine, []float64{1, 0.5, 2})
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+	_, _, err = distanceSQL(array, "manhattan", []float64{1, 0.5})
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestBuildVectorIndexSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		idx  VectorIndex
+		want string
+	}{
+		{"hnsw defaults", VectorIndex{Field: "embedding", Method: IndexHNSW, Metric: MetricCosine},
+			"CREATE INDEX docs_embedding_ann_idx ON \"public\".\"docs\" USING hnsw (embedding vector_cosine_ops)"},
+		{"hnsw params", VectorIndex{Field: "embedding", Method: IndexHNSW, Metric: MetricL2, M: 16, EFConstruction: 64},
+			"CREATE INDEX docs_embedding_ann_idx ON \"public\".\"docs\" USING hnsw (embedding vector_l2_ops) WITH (m = 16, ef_construction = 64)"},
+		{"ivfflat lists", VectorIndex{Field: "embedding", Method: IndexIVFFlat, Metric: MetricInnerProduct, Lists: 100},
+			"CREATE INDEX docs_embedding_ann_idx ON \"public\".\"docs\" USING ivfflat (embedding vector_ip_ops) WITH (lists = 100)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := BuildVectorIndexSQL("docs", tt.idx)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, sql)
+		})
+	}
+
+	invalid := []VectorIndex{
+		{Field: "embedding", Method: "btree", Metric: MetricCosine},
+		{Field: "embedding", Method: IndexHNSW, Metric: "hamming"},
+		{Field: "embedding; DROP TABLE docs", Method: IndexHNSW, Metric: MetricCosine},
+	}
+	for _, idx := range invalid {
+		_, err := BuildVectorIndexSQL("docs", idx)
+		assert.True(t, errors.Is(err, ErrInvalidQuery), "%+v", idx)
+	}
+}
+
+func TestVectorTextRoundTrip(t *testing.T) {
+	v := []float64{1, -0.25, 3.5}
+	text := FormatVector(v)
+	assert.Equal(t, "[1,-0.25,3.5]", text)
+
+	parsed, ok := parseVector(text)
+	assert.True(t, ok)
+	assert.Equal(t, v, parsed)
+
+	_, ok = parseVector("{1,2}")
+	assert.False(t, ok)
+	_, ok = parseVector("[1,x]")
+	assert.False(t, ok)
+}
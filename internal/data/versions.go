@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPreconditionFailed is returned when a conditional write targets a record
+// whose version (updated_at) no longer matches what the client last read
+var ErrPreconditionFailed = errors.New("record was modified by someone else")
+
+// versionClause restricts a write to rows whose updated_at equals one of versions.
+// An empty versions slice means the write is unconditional.
+func versionClause(ctx context.Context, q querier, collectionName string, versions []time.Time, argIdx int) (string, []any, error) {
+	if len(versions) == 0 {
+		return "", nil, nil
+	}
+
+	columns, err := tableColumns(ctx, q, collectionName)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, ok := columns["updated_at"]; !ok {
+		return "", nil, fmt.Errorf("%w: %s has no updated_at column to compare versions", ErrInvalidQuery, collectionName)
+	}
+
+	return fmt.Sprintf(" AND updated_at = ANY($%d)", argIdx), []any{versions}, nil
+}
+
+// missedWriteError explains why a conditional write matched no rows: the record
+// either doesn't exist (in the expected state, for its owner) or exists with
+// another version
+func missedWriteError(ctx context.Context, tx pgx.Tx, collectionName, id, state string, owner recordOwner, versions []time.Time) error {
+	if len(versions) == 0 {
+		return ErrRecordNotFound
+	}
+
+	var exists bool
+	ownerWhere, ownerArgs := owner.clause("", 2)
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND %s%s)", QuoteTable(collectionName), state, ownerWhere)
+	if err := tx.QueryRow(ctx, query, append([]any{id}, ownerArgs...)...).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrPreconditionFailed
+	}
+	return ErrRecordNotFound
+}
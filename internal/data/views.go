@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Collection kinds stored in _v_collections.kind
+const (
+	CollectionTable            = "table"
+	CollectionView             = "view"
+	CollectionMaterializedView = "materialized_view"
+)
+
+// IsViewKind reports whether a collection kind is a (materialized) view
+func IsViewKind(kind string) bool {
+	return kind == CollectionView || kind == CollectionMaterializedView
+}
+
+// BuildCreateViewSQL generates the CREATE [MATERIALIZED] VIEW statement of a
+// view collection of the given kind. The query must be a single SELECT (or
+// WITH ... SELECT); run the statement with pgx.QueryExecModeExec so Postgres
+// rejects anything stacked after it. Plain views run with the rights of the
+// reader so the RLS policies of their base tables apply; materialized views
+// are filled by their owner and only readable as a whole.
+func BuildCreateViewSQL(viewName, kind, query string) (string, error) {
+	ref, err := ParseTableRef(viewName)
+	if err != nil {
+		return "", fmt.Errorf("invalid view name: %s", viewName)
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	if query == "" {
+		return "", fmt.Errorf("query cannot be empty")
+	}
+	if keyword := strings.ToUpper(strings.Fields(query)[0]); keyword != "SELECT" && keyword != "WITH" {
+		return "", fmt.Errorf("query must be a SELECT statement")
+	}
+
+	if kind == CollectionMaterializedView {
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s", ref.SQL(), query), nil
+	}
+	return fmt.Sprintf("CREATE VIEW %s WITH (security_invoker = true) AS %s", ref.SQL(), query), nil
+}
+
+// CheckViewSources rejects a view created in tx that reads from OzyBase's
+// internal _v_ tables or from system schemas
+func CheckViewSources(ctx context.Context, tx pgx.Tx, viewName string) error {
+	var source string
+	err := tx.QueryRow(ctx, `
+		SELECT n.nspname || '.' || c.relname
+		FROM pg_rewrite r
+		JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid AND d.refclassid = 'pg_class'::regclass
+		JOIN pg_class c ON c.oid = d.refobjid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE r.ev_class = to_regclass($1) AND c.oid <> r.ev_class
+		  AND (NOT (`+userSchemasSQL+`) OR c.relname LIKE '\_v\_%')
+		LIMIT 1
+	`, QuoteTable(viewName)).Scan(&source)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: views cannot read from %s", ErrInvalidQuery, source)
+}
+
+// ViewTriggerSQL returns the statement that makes a view collection reject writes
+func ViewTriggerSQL(viewName string) string {
+	return fmt.Sprintf(`CREATE TRIGGER tr_readonly_%s
+		INSTEAD OF INSERT OR UPDATE OR DELETE ON %s
+		FOR EACH ROW EXECUTE FUNCTION reject_view_write();`, relName(viewName), QuoteTable(viewName))
+}
+
+// DropCollectionSQL returns the statement that drops a collection of the given kind
+func DropCollectionSQL(name, kind string) string {
+	switch kind {
+	case CollectionView:
+		return fmt.Sprintf("DROP VIEW IF EXISTS %s CASCADE", QuoteTable(name))
+	case CollectionMaterializedView:
+		return fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s CASCADE", QuoteTable(name))
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", QuoteTable(name))
+}
+
+// ViewSchema introspects the columns of a view created in tx. Records are
+// addressed by id, so the view must expose an id column.
+func ViewSchema(ctx context.Context, tx pgx.Tx, viewName string) ([]FieldSchema, error) {
+	ref, err := ParseTableRef(viewName)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, columnsSQL, ref.Schema, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schema []FieldSchema
+	hasID := false
+	for rows.Next() {
+		var colName, dataType, isNullable string
+		if err := rows.Scan(&colName, &dataType, &isNullable); err != nil {
+			return nil, err
+		}
+		hasID = hasID || colName == "id"
+		schema = append(schema, FieldSchema{Name: colName, Type: mapPostgresTypeToOzy(dataType)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !hasID {
+		return nil, fmt.Errorf("%w: the view query must return an id column", ErrInvalidQuery)
+	}
+	return schema, nil
+}
+
+// CollectionKind returns the kind of a managed collection, defaulting to
+// CollectionTable for tables OzyBase doesn't manage
+func (db *DB) CollectionKind(ctx context.Context, name string) string {
+	kind := CollectionTable
+	_ = db.Pool.QueryRow(ctx, "SELECT COALESCE(kind, 'table') FROM _v_collections WHERE name = $1", name).Scan(&kind)
+	return kind
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCreateViewSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		view    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"Select", "order_totals", "SELECT id, total FROM orders", "CREATE VIEW \"public\".\"order_totals\" WITH (security_invoker = true) AS SELECT id, total FROM orders", false},
+		{"Trailing semicolon", "order_totals", "select id from orders;\n", "CREATE VIEW \"public\".\"order_totals\" WITH (security_invoker = true) AS select id from orders", false},
+		{"CTE", "recent", "WITH r AS (SELECT id FROM orders) SELECT id FROM r", "CREATE VIEW \"public\".\"recent\" WITH (security_invoker = true) AS WITH r AS (SELECT id FROM orders) SELECT id FROM r", false},
+		{"Empty query", "order_totals", " ; ", "", true},
+		{"Not a select", "order_totals", "DELETE FROM orders", "", true},
+		{"Invalid name", "order totals", "SELECT 1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildCreateViewSQL(tt.view, CollectionView, tt.query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildCreateMaterializedViewSQL(t *testing.T) {
+	got, err := BuildCreateViewSQL("daily_sales", CollectionMaterializedView, "SELECT day AS id, SUM(total) FROM orders GROUP BY day")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE MATERIALIZED VIEW \"public\".\"daily_sales\" AS SELECT day AS id, SUM(total) FROM orders GROUP BY day", got)
+}
+
+func TestDropCollectionSQL(t *testing.T) {
+	assert.Equal(t, "DROP TABLE IF EXISTS \"public\".\"orders\" CASCADE", DropCollectionSQL("orders", CollectionTable))
+	assert.Equal(t, "DROP VIEW IF EXISTS \"public\".\"order_totals\" CASCADE", DropCollectionSQL("order_totals", CollectionView))
+	assert.Equal(t, "DROP MATERIALIZED VIEW IF EXISTS \"public\".\"daily_sales\" CASCADE", DropCollectionSQL("daily_sales", CollectionMaterializedView))
+}
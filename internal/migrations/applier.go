@@ -62,7 +62,7 @@ func (a *Applier) ApplyPendingMigrations(ctx context.Context) error {
 
 	var pending []string
 	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".sql") {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".sql") && !strings.HasSuffix(f.Name(), DownSuffix) {
 			if !applied[f.Name()] {
 				pending = append(pending, f.Name())
 			}
@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// DownSuffix marks the file undoing a migration. The applier skips these;
+// they are run by hand to roll a change back.
+const DownSuffix = ".down.sql"
+
 // Generator handles the creation of SQL migration files
 type Generator struct {
 	MigrationsPath string
@@ -25,20 +29,39 @@ func NewGenerator(path string) *Generator {
 func (g *Generator) CreateMigration(name string, sql string) (string, error) {
 	timestamp := time.Now().Format("20060102150405")
 	fileName := fmt.Sprintf("%s_%s.sql", timestamp, name)
+	return fileName, g.writeFile(fileName, name, sql)
+}
+
+// CreateReversibleMigration generates a migration along with a .down.sql file
+// of the same name that undoes it
+func (g *Generator) CreateReversibleMigration(name string, up string, down string) (string, error) {
+	timestamp := time.Now().Format("20060102150405")
+	fileName := fmt.Sprintf("%s_%s.sql", timestamp, name)
+	if err := g.writeFile(fileName, name, up); err != nil {
+		return "", err
+	}
+	downName := fmt.Sprintf("%s_%s%s", timestamp, name, DownSuffix)
+	if err := g.writeFile(downName, "revert "+name, down); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+func (g *Generator) writeFile(fileName, description, sql string) error {
 	filePath := filepath.Join(g.MigrationsPath, fileName)
 
 	// Create file
 	f, err := os.Create(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create migration file: %w", err)
+		return fmt.Errorf("failed to create migration file: %w", err)
 	}
 	defer f.Close()
 
 	// Write SQL content
-	_, err = f.WriteString(fmt.Sprintf("-- OzyBase Auto-Generated Migration\n-- Description: %s\n\n%s", name, sql))
+	_, err = f.WriteString(fmt.Sprintf("-- OzyBase Auto-Generated Migration\n-- Description: %s\n\n%s", description, sql))
 	if err != nil {
-		return "", fmt.Errorf("failed to write migration content: %w", err)
+		return fmt.Errorf("failed to write migration content: %w", err)
 	}
 
-	return fileName, nil
+	return nil
 }
@@ -18,6 +18,11 @@ type Broker struct {
 	clients        map[chan Event]bool
 	mu             sync.Mutex
 	Dispatcher     *WebhookDispatcher
+
+	// generations counts the metadata changes of each collection. It has its
+	// own lock because mu is held while events are handed to subscribers.
+	generations map[string]uint64
+	genMu       sync.Mutex
 }
 
 // NewBroker creates a new event broker
@@ -27,6 +32,7 @@ func NewBroker() *Broker {
 		newClients:     make(chan chan Event),
 		closingClients: make(chan chan Event),
 		clients:        make(map[chan Event]bool),
+		generations:    make(map[string]uint64),
 	}
 
 	go broker.listen()
@@ -73,3 +79,19 @@ func (b *Broker) Broadcast(event Event) {
 		b.Dispatcher.Dispatch(event)
 	}
 }
+
+// CollectionChanged records that the metadata of a collection, such as its
+// field access rules, changed
+func (b *Broker) CollectionChanged(table string) {
+	b.genMu.Lock()
+	b.generations[table]++
+	b.genMu.Unlock()
+}
+
+// CollectionGeneration returns a counter that moves whenever the metadata of a
+// collection changes, so subscribers know when to reload what they cached
+func (b *Broker) CollectionGeneration(table string) uint64 {
+	b.genMu.Lock()
+	defer b.genMu.Unlock()
+	return b.generations[table]
+}
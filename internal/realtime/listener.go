@@ -19,6 +19,10 @@ func ListenForEvents(ctx context.Context, pool *pgxpool.Pool, broker *Broker, di
 	if err != nil {
 		log.Fatalf("Unable to listen on ozy_events channel: %v", err)
 	}
+	_, err = conn.Exec(ctx, "LISTEN ozy_collections")
+	if err != nil {
+		log.Fatalf("Unable to listen on ozy_collections channel: %v", err)
+	}
 
 	log.Println("🔔 Listening for database events...")
 
@@ -29,6 +33,12 @@ func ListenForEvents(ctx context.Context, pool *pgxpool.Pool, broker *Broker, di
 			return
 		}
 
+		// Collection metadata changes only invalidate what subscribers cached
+		if notification.Channel == "ozy_collections" {
+			broker.CollectionChanged(notification.Payload)
+			continue
+		}
+
 		var event Event
 		err = json.Unmarshal([]byte(notification.Payload), &event)
 		if err != nil {
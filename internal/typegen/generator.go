@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -23,21 +25,33 @@ func NewGenerator(db *data.DB) *Generator {
 type TableMetadata struct {
 	Name   string
 	Fields []data.FieldSchema
+	IDType string // TypeScript type of the id column
+	// ClientIDs is set when inserts must supply the id
+	ClientIDs bool
+}
+
+// SchemaMetadata groups the collections of a Postgres schema
+type SchemaMetadata struct {
+	Name   string
+	Tables []TableMetadata
+	Views  []TableMetadata
 }
 
 func (g *Generator) Generate(outputPath string) error {
 	ctx := context.Background()
-	rows, err := g.db.Pool.Query(ctx, "SELECT name, schema_def FROM _v_collections ORDER BY name ASC")
+	rows, err := g.db.Pool.Query(ctx, "SELECT name, schema_def, COALESCE(kind, 'table'), COALESCE(id_strategy, '') FROM _v_collections ORDER BY name ASC")
 	if err != nil {
 		return fmt.Errorf("failed to query collections: %w", err)
 	}
 	defer rows.Close()
 
-	var tables []TableMetadata
+	// public always comes first, other schemas follow in name order
+	schemas := []*SchemaMetadata{{Name: data.DefaultSchema}}
+	bySchema := map[string]*SchemaMetadata{data.DefaultSchema: schemas[0]}
 	for rows.Next() {
-		var name string
+		var name, kind, idStrategy string
 		var schemaJSON []byte
-		if err := rows.Scan(&name, &schemaJSON); err != nil {
+		if err := rows.Scan(&name, &schemaJSON, &kind, &idStrategy); err != nil {
 			return err
 		}
 
@@ -46,11 +60,31 @@ func (g *Generator) Generate(outputPath string) error {
 			return err
 		}
 
-		tables = append(tables, TableMetadata{
-			Name:   name,
-			Fields: fields,
-		})
+		ref, err := data.ParseTableRef(name)
+		if err != nil {
+			continue
+		}
+		schema, ok := bySchema[ref.Schema]
+		if !ok {
+			schema = &SchemaMetadata{Name: ref.Schema}
+			bySchema[ref.Schema] = schema
+			schemas = append(schemas, schema)
+		}
+
+		meta := TableMetadata{
+			Name:      ref.Name,
+			Fields:    fields,
+			IDType:    mapType(data.FieldSchema{Type: data.IDFieldType(idStrategy)}),
+			ClientIDs: idStrategy == data.IDCustom,
+		}
+		// View schemas list every column, including id
+		if data.IsViewKind(kind) {
+			schema.Views = append(schema.Views, meta)
+		} else {
+			schema.Tables = append(schema.Tables, meta)
+		}
 	}
+	slices.SortFunc(schemas[1:], func(a, b *SchemaMetadata) int { return strings.Compare(a.Name, b.Name) })
 
 	f, err := os.Create(filepath.Clean(outputPath))
 	if err != nil {
@@ -66,19 +100,41 @@ func (g *Generator) Generate(outputPath string) error {
 	}
 
 	return tmpl.Execute(f, map[string]any{
-		"Tables": tables,
+		"Schemas": schemas,
 	})
 }
 
 func mapType(field data.FieldSchema) string {
 	switch strings.ToLower(field.Type) {
-	case "text", "uuid", "datetime":
+	case data.FieldRelation, data.FieldFile, data.FieldFiles:
+		idType := "string"
+		if strings.ToLower(field.Type) == data.FieldRelation && field.IDType != "" {
+			idType = mapType(data.FieldSchema{Type: field.IDType})
+		}
+		if field.IsLinkField() {
+			return idType + "[]"
+		}
+		return idType
+	case data.FieldSelect:
+		if len(field.Options) == 0 {
+			return "string"
+		}
+		literals := make([]string, len(field.Options))
+		for i, opt := range field.Options {
+			literals[i] = strconv.Quote(opt)
+		}
+		return strings.Join(literals, " | ")
+	case data.FieldArray:
+		return mapType(data.FieldSchema{Type: field.Items}) + "[]"
+	case data.FieldVector:
+		return "number[]"
+	case "text", "varchar", "uuid", "datetime", "date", "time", "timetz", "timestamp", "timestamptz":
 		return "string"
-	case "number":
+	case "number", "integer", "int2", "int4", "int8", "float4", "float8", "numeric":
 		return "number"
-	case "boolean":
+	case "boolean", "bool":
 		return "boolean"
-	case "json":
+	case "json", "jsonb":
 		return "Json"
 	default:
 		return "any"
@@ -98,12 +154,13 @@ export type Json =
   | Json[]
 
 export interface Database {
-  public: {
+  {{- range .Schemas}}
+  {{.Name}}: {
     Tables: {
       {{- range .Tables}}
       {{.Name}}: {
         Row: {
-          id: string
+          id: {{.IDType}}
           created_at: string
           updated_at: string
           {{- range .Fields}}
@@ -111,7 +168,7 @@ export interface Database {
           {{- end}}
         }
         Insert: {
-          id?: string
+          id{{if not .ClientIDs}}?{{end}}: {{.IDType}}
           created_at?: string
           updated_at?: string
           {{- range .Fields}}
@@ -119,7 +176,7 @@ export interface Database {
           {{- end}}
         }
         Update: {
-          id?: string
+          id?: {{.IDType}}
           created_at?: string
           updated_at?: string
           {{- range .Fields}}
@@ -129,6 +186,18 @@ export interface Database {
       }
       {{- end}}
     }
+    Views: {
+      {{- range .Views}}
+      {{.Name}}: {
+        Row: {
+          {{- range .Fields}}
+          {{.Name}}: {{MapType .}} | null
+          {{- end}}
+        }
+      }
+      {{- end}}
+    }
   }
+  {{- end}}
 }
 `